@@ -18,7 +18,7 @@ const docTemplate = `{
     "paths": {
         "/": {
             "post": {
-                "description": "sets key-value, where user is providing value, and gets key",
+                "description": "creates short link to url and returns its code, link is opened by GET /r/{code} and is stored under key link:{code} as json object.\nUrl must be absolute, with allowed scheme (links.schemes) and host, that is not blocked (links.blocked-hosts).\nCode is alias of caller, or it is generated by links.codes: random base62 code or base62 of persisted counter.\nUrl, that already has live generated link with the same status, gets code of that link, urls are compared normalized.\nWith links.refresh-ttl existing link gets ttl of request.\nwrite_concern chooses, when response is sent: applied (default) - after value is stored in memory,\ndurable - after value is written and synced to dump file, it is rejected with 400, when stores are not saved to file.\nasync is applied, because code must be checked to be free. Default is applied, earlier versions answered before write like async",
                 "consumes": [
                     "application/json"
                 ],
@@ -50,24 +50,1459 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {}
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/hotkeys": {
+            "get": {
+                "description": "the most read keys, the hottest first. Reads are counted by count-min sketch and halved every manager.hot-decay.\nKeys with at least manager.hot-reads reads are served from read replicas on every worker, write of key invalidates replicas",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Hot keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceHotKeysResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "description": "every api key with its scopes, secrets are never returned",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List api keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.authKeysResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "creates api key with scopes. Scope allows permission in bucket: read, write (includes read)\nor admin (includes write and allows /admin api). Scope with prefix allows only keys, or names of queues, channels and locks,\nwith prefix, and does not allow requests to many keys at once, for example batch.\nToken of key is returned only once, only its sha256 hash is stored",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create api key",
+                "parameters": [
+                    {
+                        "description": "id and scopes of key",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.authCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.authKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/admin/keys/{id}": {
+            "delete": {
+                "description": "deletes api key, its token stops working at once. Keys from config or keys file can not be revoked by api",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke api key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id of key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/admin/keys/{id}/rotate": {
+            "post": {
+                "description": "replaces secret of api key, old token stops working at once. New token is returned only once",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Rotate api key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id of key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.authKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/admin/reshard": {
+            "get": {
+                "description": "current number of workers and progress of keys migration",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reshard status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceReshardResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "changes number of workers without downtime. Keys, that changed owner, are moved in background,\nprogress is reported by GET /admin/reshard. New number of workers is not saved,\nmanager.worker-num from config is applied again on next start",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reshard",
+                "parameters": [
+                    {
+                        "description": "new number of workers",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceReshardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceReshardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/admin/workers": {
+            "get": {
+                "description": "liveness, restarts after panic and queue depth of every worker. Status is 503, when some worker is not healthy:\nit is not running, or processes one task longer than manager.stuck-after. Workers are added or removed by POST /admin/reshard",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Workers health",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceWorkersResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceWorkersResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/batch": {
+            "post": {
+                "description": "sets, then deletes, then gets many keys in one request. Operations of different keys are not atomic.\nValues, stored by PUT /{key}, are returned without Content-Type and Content-Encoding",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "Batch",
+                "parameters": [
+                    {
+                        "description": "keys to set, delete and get",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/channels/{name}": {
+            "post": {
+                "description": "publishes message to channel. Message is retained for retention time, so late subscribers can replay it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "channels"
+                ],
+                "summary": "Publish",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "channel name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "message",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.channelPublishRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.channelMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            },
+            "get": {
+                "description": "streams messages of channel as server-sent message events. If from is set, retained messages\nstarting from this offset are replayed first. Slow client gets overflow event and is disconnected,\nit can resubscribe from offset of next message",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "channels"
+                ],
+                "summary": "Subscribe",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "channel name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "replay retained messages starting from offset",
+                        "name": "from",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.channelMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/channels/{name}/ws": {
+            "get": {
+                "description": "same as Subscribe, but every message is sent as JSON text frame over WebSocket",
+                "tags": [
+                    "channels"
+                ],
+                "summary": "Subscribe over WebSocket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "channel name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "replay retained messages starting from offset",
+                        "name": "from",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/http.channelMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/keys": {
+            "get": {
+                "description": "lists keys with provided prefix in ascending order. Pass returned cursor to get next page, empty cursor means there is no more keys",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "List keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "cursor from previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, 100 by default, 1000 max",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceKeysResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/locks/{name}": {
+            "get": {
+                "description": "returns current lease of lock",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "locks"
+                ],
+                "summary": "Get lock",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "lock name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.lockLeaseResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    }
+                }
+            },
+            "post": {
+                "description": "acquires lock for ttl and returns lease with new fencing token. Tokens grow with every acquisition,\nalso after restart. If owner already holds the lock, lease is extended and token is kept.\nIf lock is held by other owner, 409 is returned with current lease",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "locks"
+                ],
+                "summary": "Acquire lock",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "lock name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "owner and ttl",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.lockAcquireRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.lockLeaseResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/http.lockLeaseResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            },
+            "put": {
+                "description": "extends lease for ttl, if lock is still held by owner with token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "locks"
+                ],
+                "summary": "Renew lock",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "lock name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "owner, token and ttl",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.lockRenewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.lockLeaseResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            },
+            "delete": {
+                "description": "releases lock, if it is still held by owner with token",
+                "tags": [
+                    "locks"
+                ],
+                "summary": "Release lock",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "lock name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "lock owner",
+                        "name": "owner",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "fencing token of lease",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/queues/{name}": {
+            "post": {
+                "description": "adds message to queue, message becomes visible for dequeue after delay",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queues"
+                ],
+                "summary": "Enqueue",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "queue name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "message",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.queueEnqueueRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.queueEnqueueResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/queues/{name}/ack/{id}": {
+            "post": {
+                "description": "removes delivered message from queue. Message, that is in other queue, is not found",
+                "tags": [
+                    "queues"
+                ],
+                "summary": "Ack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "queue name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "message id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/queues/{name}/dequeue": {
+            "post": {
+                "description": "waits for visible message up to wait duration. Message is hidden for visibility timeout,\nif it is not acknowledged in time, it will be delivered again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "queues"
+                ],
+                "summary": "Dequeue",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "queue name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "visibility timeout, for example 30s",
+                        "name": "visibility",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "how long to wait for message, for example 10s",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.queueMessageResponse"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/queues/{name}/nack/{id}": {
+            "post": {
+                "description": "makes delivered message visible right away, or moves it to dead letter queue \u003cname\u003e:dead,\nif it has been delivered too many times. Message, that has not been delivered yet, can not be nacked",
+                "tags": [
+                    "queues"
+                ],
+                "summary": "Nack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "queue name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "message id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/r/{code}": {
+            "get": {
+                "description": "redirects to url of short link with status of link. Missing code is answered with 404 page, expired one with 410 page.\nRoute is public and is served without /v1 prefix",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "Redirect",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "code of short link",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "301": {
+                        "description": "Moved Permanently",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "302": {
+                        "description": "Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "307": {
+                        "description": "Temporary Redirect",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "308": {
+                        "description": "Permanent Redirect",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedules": {
+            "post": {
+                "description": "schedules POST of payload to url after delay or at set time.\nFailed deliveries are retried with exponential backoff.\nUrls on blocked hosts (scheduler.blocked-hosts), loopback and private addresses are rejected",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Schedule",
+                "parameters": [
+                    {
+                        "description": "webhook",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.scheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.scheduleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/schedules/{id}": {
+            "get": {
+                "description": "returns job with its delivery log",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Get schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.jobResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    }
+                }
+            },
+            "delete": {
+                "description": "cancels job, that has not been delivered yet",
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Cancel schedule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {}
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/tx": {
+            "post": {
+                "description": "applies group of sets and deletes all-or-nothing. Every key MUST belong to one shard,\nkeys with the same hash tag, for example {user:1}:name and {user:1}:email, always do",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "Transaction",
+                "parameters": [
+                    {
+                        "description": "writes of transaction",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceTxRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceTxResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {}
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/watch": {
+            "get": {
+                "description": "streams changes of keys as server-sent events. Event name is one of set, refresh, delete, expire.\nWhen client is too slow, events are dropped and counted in dropped field (policy=drop),\nor stream ends with overflow event (policy=disconnect)",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "Watch keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "watch keys with prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "watch single key, prefix is ignored",
+                        "name": "key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "slow client policy: drop (default) or disconnect",
+                        "name": "policy",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceWatchEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    }
+                }
+            }
+        },
+        "/{key}": {
+            "get": {
+                "description": "returns raw value of key with Content-Type and Content-Encoding, it was stored with by PUT /{key}.\nContent-Type of value without it is detected from value. Reading value refreshes its ttl.\nValue is fresh in cache until its ttl ends, Cache-Control max-age and Expires are remaining ttl after read,\nvalue, that never expires, is sent with Cache-Control: no-cache. Stale value is revalidated with If-None-Match.\nETag is revision of value, it changes with every write. Value, that matches If-None-Match, is answered with 304,\nsuch read is not counted in stats",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "Get value",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags of cached value",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "value",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "revision of value"
+                            },
+                            "Cache-Control": {
+                                "type": "string",
+                                "description": "max-age of remaining ttl, or no-cache"
+                            },
+                            "Expires": {
+                                "type": "string",
+                                "description": "time, when ttl of value ends"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "same as GET /{key}, but without body. Hit is not counted in stats",
+                "tags": [
+                    "general"
+                ],
+                "summary": "Head value",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags of cached value",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "headers of value",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "revision of value"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "sets raw request body of any content type as value of key, chosen by client. Content-Type and Content-Encoding\nof request are stored with value and returned by GET /{key}. Body larger than manager.max-value-size is rejected with 413.\nValue expires after manager.val-ttl, if it is not read.\nwrite_concern chooses, when response is sent: async - after write is queued, it can still fail,\napplied (default) - after value is stored in memory, durable - after value is written and synced to dump file.\nDurable is rejected with 400, when stores are not saved to file.\nWith If-Match value is set, only if key is present and its ETag matches, otherwise 412 is returned.\nConditional write reports ETag of new value, async write concern is not allowed for it",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "general"
+                ],
+                "summary": "Put value",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "async, applied or durable",
+                        "name": "write_concern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags of current value or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "value",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "revision of new value, only for conditional write"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {}
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "deletes key, deleting missing key is not an error.\nWith If-Match key is deleted, only if it is present and its ETag matches, otherwise 412 is returned",
+                "tags": [
+                    "general"
+                ],
+                "summary": "Delete value",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags of current value or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/http.serviceErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "/{key}": {
+        "/{key}/stats": {
             "get": {
-                "description": "by known key, user can get an url",
+                "description": "hits of key by GET /{key} and GET /r/{code}, that is counted for key link:{code}: total, per hour and day, per referrer, user agent family,\ncountry and asn. Hits are aggregated in background, so last stats.flush of hits can be missing.\nHourly counters are kept for stats.hour-retention, other counters for stats.retention",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "general"
                 ],
-                "summary": "Get redirect",
+                "summary": "Key stats",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "decoded full url",
+                        "description": "key, link:{code} for short link",
                         "name": "key",
                         "in": "path",
                         "required": true
@@ -77,7 +1512,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/http.serviceGetResponse"
+                            "$ref": "#/definitions/http.statsResponse"
                         }
                     }
                 }
@@ -85,11 +1520,413 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "http.serviceGetResponse": {
+        "http.attemptResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.authCreateRequest": {
+            "type": "object",
+            "required": [
+                "scopes"
+            ],
+            "properties": {
+                "id": {
+                    "description": "ID - 1 to 64 letters, digits, '-' or '_'. Id is generated, when it is empty",
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/http.authScope"
+                    }
+                }
+            }
+        },
+        "http.authKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "Created - unix time, when key was created or rotated",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.authScope"
+                    }
+                },
+                "static": {
+                    "description": "Static - key is loaded from config or keys file, it can not be changed by api",
+                    "type": "boolean"
+                },
+                "token": {
+                    "description": "Token - send it in X-API-Key or Authorization: Bearer header. Token is returned only once, after create or rotate",
+                    "type": "string"
+                }
+            }
+        },
+        "http.authKeysResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.authKeyResponse"
+                    }
+                }
+            }
+        },
+        "http.authScope": {
+            "type": "object",
+            "required": [
+                "permission"
+            ],
+            "properties": {
+                "bucket": {
+                    "description": "Bucket - keys, queues, schedules, channels, locks or admin. Empty bucket means every bucket",
+                    "type": "string",
+                    "enum": [
+                        "keys",
+                        "queues",
+                        "schedules",
+                        "channels",
+                        "locks",
+                        "admin"
+                    ]
+                },
+                "permission": {
+                    "type": "string",
+                    "enum": [
+                        "read",
+                        "write",
+                        "admin"
+                    ]
+                },
+                "prefix": {
+                    "description": "Prefix - scope allows only keys, or names of queues, channels and locks, with prefix",
+                    "type": "string"
+                }
+            }
+        },
+        "http.channelMessageResponse": {
+            "type": "object",
+            "properties": {
+                "channel": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "published": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.channelPublishRequest": {
+            "type": "object",
+            "required": [
+                "payload"
+            ],
+            "properties": {
+                "payload": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.jobResponse": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.attemptResponse"
+                    }
+                },
+                "content_type": {
+                    "type": "string"
+                },
+                "due_at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.lockAcquireRequest": {
+            "type": "object",
+            "required": [
+                "owner",
+                "ttl"
+            ],
+            "properties": {
+                "owner": {
+                    "type": "string"
+                },
+                "ttl": {
+                    "description": "TTL - duration string, for example 30s",
+                    "type": "string"
+                }
+            }
+        },
+        "http.lockLeaseResponse": {
+            "type": "object",
+            "properties": {
+                "expires": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.lockRenewRequest": {
+            "type": "object",
+            "required": [
+                "owner",
+                "token",
+                "ttl"
+            ],
+            "properties": {
+                "owner": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "integer"
+                },
+                "ttl": {
+                    "description": "TTL - duration string, for example 30s",
+                    "type": "string"
+                }
+            }
+        },
+        "http.queueEnqueueRequest": {
+            "type": "object",
+            "required": [
+                "payload"
+            ],
+            "properties": {
+                "delay": {
+                    "description": "Delay - duration string, for example 15s or 10m",
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.queueEnqueueResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.queueMessageResponse": {
+            "type": "object",
+            "properties": {
+                "deliveries": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "queue": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.scheduleRequest": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "at": {
+                    "description": "At - RFC3339 time, when webhook shoud be sent",
+                    "type": "string"
+                },
+                "content_type": {
+                    "type": "string"
+                },
+                "delay": {
+                    "description": "Delay - duration string, for example 15m. Ignored if At is set",
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.scheduleResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.serviceBatchRequest": {
+            "type": "object",
+            "properties": {
+                "delete": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "get": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "set": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.serviceKV"
+                    }
+                }
+            }
+        },
+        "http.serviceBatchResponse": {
+            "type": "object",
+            "properties": {
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "values": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.serviceErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.serviceHotKey": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "reads": {
+                    "description": "Reads - estimated number of reads since last decay",
+                    "type": "integer"
+                }
+            }
+        },
+        "http.serviceHotKeysResponse": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.serviceHotKey"
+                    }
+                }
+            }
+        },
+        "http.serviceKV": {
+            "type": "object",
+            "required": [
+                "key"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "val": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.serviceKeysResponse": {
             "type": "object",
             "properties": {
-                "decode_url": {
+                "cursor": {
                     "type": "string"
+                },
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "http.serviceReshardRequest": {
+            "type": "object",
+            "required": [
+                "workers"
+            ],
+            "properties": {
+                "workers": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "http.serviceReshardResponse": {
+            "type": "object",
+            "properties": {
+                "migrating": {
+                    "type": "boolean"
+                },
+                "moved": {
+                    "type": "integer"
+                },
+                "workers": {
+                    "type": "integer"
                 }
             }
         },
@@ -99,8 +1936,35 @@ const docTemplate = `{
                 "redirect"
             ],
             "properties": {
+                "alias": {
+                    "description": "Alias - custom code of link, 3 to 64 letters, digits, '-' or '_'. Code is generated, when alias is empty",
+                    "type": "string"
+                },
                 "redirect": {
                     "type": "string"
+                },
+                "status": {
+                    "description": "Status - redirect status: 301, 302, 307 or 308, links.status from config by default",
+                    "type": "integer",
+                    "enum": [
+                        301,
+                        302,
+                        307,
+                        308
+                    ]
+                },
+                "ttl": {
+                    "description": "TTL - duration string, for example 24h. Expired link is answered with 410 for 24h, then with 404. Link without ttl never expires, reads do not change ttl of link",
+                    "type": "string"
+                },
+                "write_concern": {
+                    "description": "WriteConcern - async, applied (default) or durable. Durable needs stores saved to file.\nDefault is applied, so created link can be read at once, earlier versions answered before write like async.",
+                    "type": "string",
+                    "enum": [
+                        "async",
+                        "applied",
+                        "durable"
+                    ]
                 }
             }
         },
@@ -111,6 +1975,159 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "http.serviceTxOp": {
+            "type": "object",
+            "required": [
+                "key",
+                "op"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string",
+                    "enum": [
+                        "set",
+                        "delete"
+                    ]
+                },
+                "val": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.serviceTxRequest": {
+            "type": "object",
+            "required": [
+                "ops"
+            ],
+            "properties": {
+                "ops": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/http.serviceTxOp"
+                    }
+                }
+            }
+        },
+        "http.serviceTxResponse": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.serviceWatchEvent": {
+            "type": "object",
+            "properties": {
+                "dropped": {
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "val": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.serviceWorkerHealth": {
+            "type": "object",
+            "properties": {
+                "alive": {
+                    "type": "boolean"
+                },
+                "busy_ms": {
+                    "description": "BusyMs - how long worker is processing current task, in milliseconds",
+                    "type": "integer"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "integer"
+                },
+                "queue_size": {
+                    "type": "integer"
+                },
+                "restarts": {
+                    "type": "integer"
+                }
+            }
+        },
+        "http.serviceWorkersResponse": {
+            "type": "object",
+            "properties": {
+                "rejected": {
+                    "type": "integer"
+                },
+                "workers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.serviceWorkerHealth"
+                    }
+                }
+            }
+        },
+        "http.statsResponse": {
+            "type": "object",
+            "properties": {
+                "agents": {
+                    "description": "Agents - hits per family of user agent",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "asns": {
+                    "description": "ASNs - hits per autonomous system, empty without geo db, other for systems over stats.max-breakdown",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "countries": {
+                    "description": "Countries - hits per country code, empty without geo db",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "days": {
+                    "description": "Days - hits per UTC day, for example 2006-01-02",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "hours": {
+                    "description": "Hours - hits per UTC hour, for example 2006-01-02T15",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "key": {
+                    "type": "string"
+                },
+                "referrers": {
+                    "description": "Referrers - hits per host of referrer, direct for hits without referrer, other for hosts over stats.max-breakdown",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
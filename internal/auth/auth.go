@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"gopkg.in/yaml.v2"
+)
+
+// Permission - what key can do in bucket. Write includes read, admin includes write and allows admin api.
+type Permission string
+
+const (
+	PermRead  Permission = "read"
+	PermWrite Permission = "write"
+	PermAdmin Permission = "admin"
+)
+
+func (p Permission) level() int {
+	switch p {
+	case PermRead:
+		return 1
+	case PermWrite:
+		return 2
+	case PermAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Buckets of api. Empty bucket of scope means every bucket.
+const (
+	BucketKeys      = "keys"
+	BucketQueues    = "queues"
+	BucketSchedules = "schedules"
+	BucketChannels  = "channels"
+	BucketLocks     = "locks"
+	BucketAdmin     = "admin"
+)
+
+var buckets = map[string]bool{
+	"":              true,
+	BucketKeys:      true,
+	BucketQueues:    true,
+	BucketSchedules: true,
+	BucketChannels:  true,
+	BucketLocks:     true,
+	BucketAdmin:     true,
+}
+
+var (
+	ErrNoKey          = errors.New("api key is required")
+	ErrInvalidKey     = errors.New("api key is invalid or revoked")
+	ErrKeyNotFound    = errors.New("api key not found")
+	ErrKeyExists      = errors.New("api key with this id already exists")
+	ErrStaticKey      = errors.New("api key is loaded from config or keys file, it can not be changed by api")
+	ErrInvalidID      = errors.New("id of api key must be 1 to 64 letters, digits, '-' or '_'")
+	ErrInvalidScope   = errors.New("scope must have permission read, write or admin and known bucket")
+	ErrNoScopes       = errors.New("api key must have at least one scope")
+	errInvalidKeyHash = errors.New("hash of api key must be hex sha256 of secret")
+)
+
+// ContextKey - key of gin context, that holds id of api key of request
+const ContextKey = "api-key"
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Scope - permission in bucket for names with prefix. Name is key, or name of queue, channel or lock.
+// Scope with prefix does not allow requests to many names at once, for example batch.
+type Scope struct {
+	Permission Permission `yaml:"permission"`
+	Bucket     string     `yaml:"bucket"`
+	Prefix     string     `yaml:"prefix"`
+}
+
+// Access - what request needs. Empty name means request to many names at once.
+type Access struct {
+	Permission Permission
+	Bucket     string
+	Name       string
+}
+
+// Key - api key. Token of key is <id>.<secret>, only sha256 of secret is stored.
+type Key struct {
+	ID     string  `yaml:"id"`
+	Hash   string  `yaml:"hash"`
+	Scopes []Scope `yaml:"scopes"`
+	// Created - unix time, when key was created or rotated by api
+	Created int64 `yaml:"created"`
+	// Static - key is loaded from config or keys file
+	Static bool `yaml:"-"`
+}
+
+// Allows - some scope of key allows access
+func (k Key) Allows(a Access) bool {
+	for _, s := range k.Scopes {
+		if s.Permission.level() >= a.Permission.level() &&
+			(s.Bucket == "" || s.Bucket == a.Bucket) &&
+			strings.HasPrefix(a.Name, s.Prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HashSecret - hex sha256 of secret, that is stored instead of secret
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func validScopes(scopes []Scope) error {
+	if len(scopes) == 0 {
+		return ErrNoScopes
+	}
+
+	for _, s := range scopes {
+		if s.Permission.level() == 0 || !buckets[s.Bucket] {
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+func validKey(k Key) error {
+	if !idPattern.MatchString(k.ID) {
+		return ErrInvalidID
+	}
+
+	if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+		return errInvalidKeyHash
+	}
+
+	return validScopes(k.Scopes)
+}
+
+// newSecret - random secret, encoded to be safe in header
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Keyring - api keys. Static keys come from config and keys file, keys created by api are stored in bucket.
+type Keyring struct {
+	store  *ttlstore.MapStore[string, Key]
+	static map[string]Key
+	cfg    AuthConfig
+}
+
+func NewKeyring(store *ttlstore.MapStore[string, Key], cfg AuthConfig) (*Keyring, error) {
+	keys := append([]Key{}, cfg.Keys...)
+
+	if cfg.KeysFile != "" {
+		b, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var fileKeys []Key
+		if err := yaml.Unmarshal(b, &fileKeys); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	static := make(map[string]Key, len(keys))
+	for _, k := range keys {
+		if err := validKey(k); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.ID, err)
+		}
+
+		if _, ok := static[k.ID]; ok {
+			return nil, fmt.Errorf("api key %q: %w", k.ID, ErrKeyExists)
+		}
+
+		k.Hash = strings.ToLower(k.Hash)
+		k.Static = true
+		static[k.ID] = k
+	}
+
+	return &Keyring{
+		store:  store,
+		static: static,
+		cfg:    cfg,
+	}, nil
+}
+
+// Enabled - requests need api key
+func (kr *Keyring) Enabled() bool {
+	return kr.cfg.Enabled
+}
+
+func (kr *Keyring) get(ctx context.Context, id string) (Key, bool) {
+	if k, ok := kr.static[id]; ok {
+		return k, true
+	}
+	return kr.store.Get(ctx, id)
+}
+
+// Authenticate - returns key of token <id>.<secret>
+func (kr *Keyring) Authenticate(ctx context.Context, token string) (Key, error) {
+	if token == "" {
+		return Key{}, ErrNoKey
+	}
+
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+
+	k, ok := kr.get(ctx, id)
+	if !ok || subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(k.Hash)) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	return k, nil
+}
+
+// Create - creates key with scopes and returns its token, token is not stored and can not be shown again.
+// Empty id is generated.
+func (kr *Keyring) Create(ctx context.Context, id string, scopes []Scope) (Key, string, error) {
+	if err := validScopes(scopes); err != nil {
+		return Key{}, "", err
+	}
+
+	if id == "" {
+		var err error
+		if id, err = newID(); err != nil {
+			return Key{}, "", err
+		}
+	}
+
+	if !idPattern.MatchString(id) {
+		return Key{}, "", ErrInvalidID
+	}
+
+	if _, ok := kr.static[id]; ok {
+		return Key{}, "", ErrKeyExists
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	k := Key{
+		ID:      id,
+		Hash:    HashSecret(secret),
+		Scopes:  scopes,
+		Created: time.Now().Unix(),
+	}
+
+	err = kr.store.Update(ctx, func(get func(key string) (Key, bool), tx *ttlstore.Tx[string, Key]) error {
+		if _, ok := get(id); ok {
+			return ErrKeyExists
+		}
+
+		tx.Set(id, k, -1)
+		return nil
+	})
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	return k, id + "." + secret, nil
+}
+
+// Rotate - replaces secret of key, old token stops working at once. Returns new token.
+func (kr *Keyring) Rotate(ctx context.Context, id string) (Key, string, error) {
+	if _, ok := kr.static[id]; ok {
+		return Key{}, "", ErrStaticKey
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	var k Key
+	err = kr.store.Update(ctx, func(get func(key string) (Key, bool), tx *ttlstore.Tx[string, Key]) error {
+		var ok bool
+		if k, ok = get(id); !ok {
+			return ErrKeyNotFound
+		}
+
+		k.Hash = HashSecret(secret)
+		k.Created = time.Now().Unix()
+		tx.Set(id, k, -1)
+		return nil
+	})
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	return k, id + "." + secret, nil
+}
+
+// Revoke - deletes key, its token stops working at once
+func (kr *Keyring) Revoke(ctx context.Context, id string) error {
+	if _, ok := kr.static[id]; ok {
+		return ErrStaticKey
+	}
+
+	return kr.store.Update(ctx, func(get func(key string) (Key, bool), tx *ttlstore.Tx[string, Key]) error {
+		if _, ok := get(id); !ok {
+			return ErrKeyNotFound
+		}
+
+		tx.Delete(id)
+		return nil
+	})
+}
+
+// List - every key ordered by id
+func (kr *Keyring) List() []Key {
+	keys := make([]Key, 0, len(kr.static))
+	for _, k := range kr.static {
+		keys = append(keys, k)
+	}
+
+	kr.store.Range(func(id string, k Key) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
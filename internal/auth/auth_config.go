@@ -0,0 +1,18 @@
+package auth
+
+type AuthConfig struct {
+	// Enabled - every request to /v1 needs api key. Disabled auth lets every request in.
+	Enabled bool `yaml:"enabled"`
+	// Keys - api keys, secret of key is stored as hex sha256 hash
+	Keys []Key `yaml:"keys"`
+	// KeysFile - path to yaml file with list of api keys in the same format as Keys. Empty path disables file.
+	KeysFile string `yaml:"keys-file"`
+}
+
+func newAuthConfig(Enabled bool, Keys []Key, KeysFile string) AuthConfig {
+	return AuthConfig{
+		Enabled:  Enabled,
+		Keys:     Keys,
+		KeysFile: KeysFile,
+	}
+}
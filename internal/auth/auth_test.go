@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+)
+
+func newTestKeyring(t *testing.T, cfg AuthConfig) (*Keyring, func()) {
+	store := ttlstore.NewMapStore[string, Key](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	kr, err := NewKeyring(store, cfg)
+	if err != nil {
+		store.Close()
+		t.Fatal(err)
+	}
+	return kr, func() { store.Close() }
+}
+
+func TestAuthScopes(t *testing.T) {
+	k := Key{Scopes: []Scope{
+		{Permission: PermWrite, Bucket: BucketKeys, Prefix: "user:"},
+		{Permission: PermRead, Bucket: BucketQueues},
+	}}
+
+	for _, c := range []struct {
+		access Access
+		want   bool
+	}{
+		{Access{PermRead, BucketKeys, "user:1"}, true},
+		{Access{PermWrite, BucketKeys, "user:1"}, true},
+		{Access{PermAdmin, BucketKeys, "user:1"}, false},
+		{Access{PermWrite, BucketKeys, "order:1"}, false},
+		// Request to many keys needs scope without prefix
+		{Access{PermRead, BucketKeys, ""}, false},
+		{Access{PermRead, BucketQueues, "jobs"}, true},
+		{Access{PermRead, BucketQueues, ""}, true},
+		{Access{PermWrite, BucketQueues, "jobs"}, false},
+		{Access{PermRead, BucketLocks, "a"}, false},
+		{Access{PermAdmin, BucketAdmin, ""}, false},
+	} {
+		if got := k.Allows(c.access); got != c.want {
+			t.Errorf("Access %+v: want %v, got: %v", c.access, c.want, got)
+		}
+	}
+
+	admin := Key{Scopes: []Scope{{Permission: PermAdmin}}}
+	for _, a := range []Access{{PermAdmin, BucketAdmin, ""}, {PermWrite, BucketKeys, ""}, {PermRead, BucketLocks, "a"}} {
+		if !admin.Allows(a) {
+			t.Errorf("Want admin key to allow %+v", a)
+		}
+	}
+}
+
+func TestAuthStaticKeys(t *testing.T) {
+	file, err := os.CreateTemp("", "keys*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(`
+- id: file
+  hash: ` + HashSecret("file-secret") + `
+  scopes:
+   - permission: read
+     bucket: keys
+`)
+	file.Close()
+
+	cfg := newAuthConfig(true, []Key{{
+		ID:     "root",
+		Hash:   strings.ToUpper(HashSecret("root-secret")),
+		Scopes: []Scope{{Permission: PermAdmin}},
+	}}, file.Name())
+
+	kr, stop := newTestKeyring(t, cfg)
+	defer stop()
+
+	ctx := context.Background()
+
+	if k, err := kr.Authenticate(ctx, "root.root-secret"); err != nil || k.ID != "root" || !k.Static {
+		t.Errorf("Want static root key, got: %+v, %v", k, err)
+	}
+
+	if k, err := kr.Authenticate(ctx, "file.file-secret"); err != nil || k.ID != "file" {
+		t.Errorf("Want key from file, got: %+v, %v", k, err)
+	}
+
+	for token, want := range map[string]error{
+		"":                 ErrNoKey,
+		"root":             ErrInvalidKey,
+		"root.wrong":       ErrInvalidKey,
+		"missing.secret":   ErrInvalidKey,
+		"file.root-secret": ErrInvalidKey,
+	} {
+		if _, err := kr.Authenticate(ctx, token); err != want {
+			t.Errorf("Token %q: want %v, got: %v", token, want, err)
+		}
+	}
+
+	if _, _, err := kr.Rotate(ctx, "root"); err != ErrStaticKey {
+		t.Errorf("Want ErrStaticKey, got: %v", err)
+	}
+
+	if err := kr.Revoke(ctx, "file"); err != ErrStaticKey {
+		t.Errorf("Want ErrStaticKey, got: %v", err)
+	}
+
+	if _, _, err := kr.Create(ctx, "root", []Scope{{Permission: PermRead}}); err != ErrKeyExists {
+		t.Errorf("Want ErrKeyExists, got: %v", err)
+	}
+
+	// Invalid keys in config are rejected
+	for _, k := range []Key{
+		{ID: "a.b", Hash: HashSecret("s"), Scopes: []Scope{{Permission: PermRead}}},
+		{ID: "a", Hash: "secret", Scopes: []Scope{{Permission: PermRead}}},
+		{ID: "a", Hash: HashSecret("s")},
+		{ID: "a", Hash: HashSecret("s"), Scopes: []Scope{{Permission: "owner"}}},
+		{ID: "a", Hash: HashSecret("s"), Scopes: []Scope{{Permission: PermRead, Bucket: "files"}}},
+	} {
+		if _, err := NewKeyring(nil, newAuthConfig(true, []Key{k}, "")); err == nil {
+			t.Errorf("Want error for key %+v", k)
+		}
+	}
+}
+
+func TestAuthLifecycle(t *testing.T) {
+	kr, stop := newTestKeyring(t, newAuthConfig(true, nil, ""))
+	defer stop()
+
+	ctx := context.Background()
+	scopes := []Scope{{Permission: PermWrite, Bucket: BucketKeys, Prefix: "ci:"}}
+
+	k, token, err := kr.Create(ctx, "ci", scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(token, "ci.") || strings.Contains(k.Hash, strings.TrimPrefix(token, "ci.")) {
+		t.Errorf("Unexpected token %q of key %+v", token, k)
+	}
+
+	if got, err := kr.Authenticate(ctx, token); err != nil || got.ID != "ci" || !got.Allows(Access{PermWrite, BucketKeys, "ci:1"}) {
+		t.Errorf("Want ci key, got: %+v, %v", got, err)
+	}
+
+	if _, _, err := kr.Create(ctx, "ci", scopes); err != ErrKeyExists {
+		t.Errorf("Want ErrKeyExists, got: %v", err)
+	}
+
+	if _, _, err := kr.Create(ctx, "bad id", scopes); err != ErrInvalidID {
+		t.Errorf("Want ErrInvalidID, got: %v", err)
+	}
+
+	if _, _, err := kr.Create(ctx, "x", nil); err != ErrNoScopes {
+		t.Errorf("Want ErrNoScopes, got: %v", err)
+	}
+
+	generated, _, err := kr.Create(ctx, "", scopes)
+	if err != nil || generated.ID == "" {
+		t.Errorf("Want generated id, got: %+v, %v", generated, err)
+	}
+
+	_, rotated, err := kr.Rotate(ctx, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kr.Authenticate(ctx, token); err != ErrInvalidKey {
+		t.Errorf("Want old token to stop working, got: %v", err)
+	}
+
+	if _, err := kr.Authenticate(ctx, rotated); err != nil {
+		t.Errorf("Want rotated token to work, got: %v", err)
+	}
+
+	if keys := kr.List(); len(keys) != 2 || keys[0].ID > keys[1].ID {
+		t.Errorf("Want 2 keys ordered by id, got: %+v", keys)
+	}
+
+	if err := kr.Revoke(ctx, "ci"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := kr.Authenticate(ctx, rotated); err != ErrInvalidKey {
+		t.Errorf("Want revoked token to stop working, got: %v", err)
+	}
+
+	if err := kr.Revoke(ctx, "ci"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Want ErrKeyNotFound, got: %v", err)
+	}
+
+	if _, _, err := kr.Rotate(ctx, "ci"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Want ErrKeyNotFound, got: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/BON4/timedQ/internal/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type authScope struct {
+	Permission string `json:"permission" binding:"required,oneof=read write admin"`
+	// Bucket - keys, queues, schedules, channels, locks or admin. Empty bucket means every bucket
+	Bucket string `json:"bucket" binding:"omitempty,oneof=keys queues schedules channels locks admin"`
+	// Prefix - scope allows only keys, or names of queues, channels and locks, with prefix
+	Prefix string `json:"prefix"`
+}
+
+type authCreateRequest struct {
+	// ID - 1 to 64 letters, digits, '-' or '_'. Id is generated, when it is empty
+	ID     string      `json:"id"`
+	Scopes []authScope `json:"scopes" binding:"required,min=1,dive"`
+}
+
+type authKeyResponse struct {
+	ID     string      `json:"id"`
+	Scopes []authScope `json:"scopes"`
+	// Created - unix time, when key was created or rotated
+	Created int64 `json:"created"`
+	// Static - key is loaded from config or keys file, it can not be changed by api
+	Static bool `json:"static"`
+	// Token - send it in X-API-Key or Authorization: Bearer header. Token is returned only once, after create or rotate
+	Token string `json:"token,omitempty"`
+}
+
+type authKeysResponse struct {
+	Keys []authKeyResponse `json:"keys"`
+}
+
+type authHandler struct {
+	logger  *logrus.Entry
+	keyring *auth.Keyring
+}
+
+func newAuthKeyResponse(k auth.Key, token string) authKeyResponse {
+	resp := authKeyResponse{
+		ID:      k.ID,
+		Scopes:  make([]authScope, len(k.Scopes)),
+		Created: k.Created,
+		Static:  k.Static,
+		Token:   token,
+	}
+
+	for i, s := range k.Scopes {
+		resp.Scopes[i] = authScope{
+			Permission: string(s.Permission),
+			Bucket:     s.Bucket,
+			Prefix:     s.Prefix,
+		}
+	}
+	return resp
+}
+
+// audit - records change of api keys, made by api key of request
+func (h *authHandler) audit(c *gin.Context, action string, id string) {
+	h.logger.WithFields(logrus.Fields{
+		"audit": action,
+		"key":   id,
+		"actor": c.GetString(auth.ContextKey),
+		"ip":    c.ClientIP(),
+	}).Info("api key changed")
+}
+
+// abortWithKeyringError - aborts request with status, that matches error of keyring
+func abortWithKeyringError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		c.AbortWithError(http.StatusNotFound, err)
+	case errors.Is(err, auth.ErrKeyExists), errors.Is(err, auth.ErrStaticKey):
+		c.AbortWithError(http.StatusConflict, err)
+	case errors.Is(err, auth.ErrInvalidID), errors.Is(err, auth.ErrInvalidScope), errors.Is(err, auth.ErrNoScopes):
+		c.AbortWithError(http.StatusBadRequest, err)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// @Summary      List api keys
+// @Description  every api key with its scopes, secrets are never returned
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  authKeysResponse
+// @Router       /admin/keys [get]
+func (h *authHandler) List() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := h.keyring.List()
+
+		resp := authKeysResponse{
+			Keys: make([]authKeyResponse, len(keys)),
+		}
+		for i, k := range keys {
+			resp.Keys[i] = newAuthKeyResponse(k, "")
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// @Summary      Create api key
+// @Description  creates api key with scopes. Scope allows permission in bucket: read, write (includes read)
+// @Description  or admin (includes write and allows /admin api). Scope with prefix allows only keys, or names of queues, channels and locks,
+// @Description  with prefix, and does not allow requests to many keys at once, for example batch.
+// @Description  Token of key is returned only once, only its sha256 hash is stored
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        input  body      authCreateRequest  true  "id and scopes of key"
+// @Success      201    {object}  authKeyResponse
+// @Failure      400    {object}  error
+// @Failure      409    {object}  error
+// @Failure      500    {object}  error
+// @Router       /admin/keys [post]
+func (h *authHandler) Create() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &authCreateRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		scopes := make([]auth.Scope, len(req.Scopes))
+		for i, s := range req.Scopes {
+			scopes[i] = auth.Scope{
+				Permission: auth.Permission(s.Permission),
+				Bucket:     s.Bucket,
+				Prefix:     s.Prefix,
+			}
+		}
+
+		k, token, err := h.keyring.Create(c.Request.Context(), req.ID, scopes)
+		if err != nil {
+			abortWithKeyringError(c, err)
+			return
+		}
+		h.audit(c, "create", k.ID)
+
+		c.JSON(http.StatusCreated, newAuthKeyResponse(k, token))
+	}
+}
+
+// @Summary      Rotate api key
+// @Description  replaces secret of api key, old token stops working at once. New token is returned only once
+// @Tags         admin
+// @Produce      json
+// @Param        id   path      string  true  "id of key"
+// @Success      200  {object}  authKeyResponse
+// @Failure      404  {object}  error
+// @Failure      409  {object}  error
+// @Failure      500  {object}  error
+// @Router       /admin/keys/{id}/rotate [post]
+func (h *authHandler) Rotate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, token, err := h.keyring.Rotate(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			abortWithKeyringError(c, err)
+			return
+		}
+		h.audit(c, "rotate", k.ID)
+
+		c.JSON(http.StatusOK, newAuthKeyResponse(k, token))
+	}
+}
+
+// @Summary      Revoke api key
+// @Description  deletes api key, its token stops working at once. Keys from config or keys file can not be revoked by api
+// @Tags         admin
+// @Param        id   path      string  true  "id of key"
+// @Success      204
+// @Failure      404  {object}  error
+// @Failure      409  {object}  error
+// @Failure      500  {object}  error
+// @Router       /admin/keys/{id} [delete]
+func (h *authHandler) Revoke() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if err := h.keyring.Revoke(c.Request.Context(), id); err != nil {
+			abortWithKeyringError(c, err)
+			return
+		}
+		h.audit(c, "revoke", id)
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func NewAuthHandler(keyring *auth.Keyring, logger *logrus.Entry) *authHandler {
+	return &authHandler{
+		logger:  logger,
+		keyring: keyring,
+	}
+}
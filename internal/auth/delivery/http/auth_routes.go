@@ -0,0 +1,10 @@
+package http
+
+import "github.com/gin-gonic/gin"
+
+func NewAuthRoutes(group *gin.RouterGroup, h *authHandler) {
+	group.GET("", h.List())
+	group.POST("", h.Create())
+	group.POST("/:id/rotate", h.Rotate())
+	group.DELETE("/:id", h.Revoke())
+}
@@ -0,0 +1,294 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	msgPrefix  = "msg:"
+	headPrefix = "head:"
+
+	DEFAULT_SUBSCRIBER_BUFFER = 64
+	DEFAULT_RETENTION         = time.Hour
+)
+
+var (
+	ErrInvalidName = errors.New("channel name must be non empty and must not contain ':'")
+	ErrHubClosed   = errors.New("hub is closed")
+)
+
+// Message - published message. Offsets of channel start from 1 and grow by one with every message.
+type Message struct {
+	Channel   string
+	Offset    uint64
+	Payload   string
+	Published int64
+}
+
+func msgKey(name string, offset uint64) string {
+	return fmt.Sprintf("%s%s:%020d", msgPrefix, name, offset)
+}
+
+func headKey(name string) string {
+	return headPrefix + name
+}
+
+func validName(name string) bool {
+	return len(name) > 0 && !strings.Contains(name, ":")
+}
+
+// Subscriber - live stream of channel messages. Backlog holds retained messages, requested on subscribe.
+type Subscriber struct {
+	channel string
+	// after - messages with lower or equal offset are already in backlog, or were published before subscribe
+	after   uint64
+	backlog []Message
+	events  chan Message
+	done    chan struct{}
+	once    *sync.Once
+	h       *Hub
+}
+
+// Backlog - retained messages from requested offset, that were published before subscribe. Some of them could have expired.
+func (s *Subscriber) Backlog() []Message {
+	return s.backlog
+}
+
+// Events - messages published after subscribe. Channel is never closed, use Done to detect end of subscription.
+func (s *Subscriber) Events() <-chan Message {
+	return s.events
+}
+
+// Done - closed after Close was called, or subscriber was too slow and got disconnected
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Subscriber) Close() {
+	s.h.remove(s)
+	s.disconnect()
+}
+
+func (s *Subscriber) disconnect() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// channelHead - last offset of channel
+type channelHead struct {
+	// mu - serializes publishes of channel, so its offsets reach fan-out in order
+	mu     *sync.Mutex
+	offset uint64
+	loaded bool
+}
+
+// Hub - pub/sub channels. Messages are retained in ttlstore under msg:<name>:<offset> keys for retention time,
+// last offset of channel is kept under head:<name> key. Messages are sent to subscribers by workers of manager,
+// channel is handled by worker, that owns key of its name.
+type Hub struct {
+	store  *ttlstore.MapStore[string, Message]
+	wm     *manager.WorkerManager
+	cfg    ChannelConfig
+	logger *logrus.Entry
+
+	// mu - guards heads, publishes to different channels do not wait for each other
+	mu    *sync.Mutex
+	heads map[string]*channelHead
+
+	subsMu *sync.RWMutex
+	subs   map[string]map[*Subscriber]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewHub(ctx context.Context, store *ttlstore.MapStore[string, Message], wm *manager.WorkerManager, logger *logrus.Entry, cfg ChannelConfig) *Hub {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = DEFAULT_SUBSCRIBER_BUFFER
+	}
+
+	// Zero ttl does not store message, so nothing could be replayed
+	if cfg.Retention <= 0 {
+		cfg.Retention = DEFAULT_RETENTION
+	}
+
+	h := &Hub{
+		store:  store,
+		wm:     wm,
+		cfg:    cfg,
+		logger: logger,
+		mu:     &sync.Mutex{},
+		heads:  make(map[string]*channelHead),
+		subsMu: &sync.RWMutex{},
+		subs:   make(map[string]map[*Subscriber]struct{}),
+	}
+
+	h.ctx, h.cancel = context.WithCancel(ctx)
+	return h
+}
+
+func (h *Hub) add(s *Subscriber) {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+
+	subs, ok := h.subs[s.channel]
+	if !ok {
+		subs = make(map[*Subscriber]struct{})
+		h.subs[s.channel] = subs
+	}
+	subs[s] = struct{}{}
+}
+
+func (h *Hub) remove(s *Subscriber) {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+
+	subs := h.subs[s.channel]
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.channel)
+	}
+}
+
+// deliver - runs on worker of manager, so it never blocks, slow subscriber is disconnected
+func (h *Hub) deliver(m Message) {
+	h.subsMu.RLock()
+	defer h.subsMu.RUnlock()
+
+	for s := range h.subs[m.Channel] {
+		if m.Offset <= s.after {
+			continue
+		}
+
+		select {
+		case s.events <- m:
+		default:
+			s.disconnect()
+		}
+	}
+}
+
+// head - locked head of channel, loaded from store on first use. Caller MUST unlock it.
+func (h *Hub) head(ctx context.Context, name string) *channelHead {
+	h.mu.Lock()
+	head, ok := h.heads[name]
+	if !ok {
+		head = &channelHead{mu: &sync.Mutex{}}
+		h.heads[name] = head
+	}
+	h.mu.Unlock()
+
+	head.mu.Lock()
+	if !head.loaded {
+		m, _ := h.store.Get(ctx, headKey(name))
+		head.offset, head.loaded = m.Offset, true
+	}
+	return head
+}
+
+// Publish - retains message and sends it to subscribers of channel
+func (h *Hub) Publish(ctx context.Context, name string, payload string) (Message, error) {
+	if !validName(name) {
+		return Message{}, ErrInvalidName
+	}
+
+	if h.ctx.Err() != nil {
+		return Message{}, ErrHubClosed
+	}
+
+	head := h.head(ctx, name)
+	defer head.mu.Unlock()
+
+	m := Message{
+		Channel:   name,
+		Offset:    head.offset + 1,
+		Payload:   payload,
+		Published: time.Now().UnixNano(),
+	}
+
+	if err := h.store.Multi().
+		Set(msgKey(name, m.Offset), m, h.cfg.Retention).
+		Set(headKey(name), Message{Channel: name, Offset: m.Offset}, -1).
+		Exec(ctx); err != nil {
+		return Message{}, err
+	}
+
+	head.offset = m.Offset
+
+	// Message is retained, so subscriber, that missed it, can replay it
+	if err := h.wm.Go(ctx, name, func() { h.deliver(m) }); err != nil {
+		return Message{}, err
+	}
+
+	return m, nil
+}
+
+// Subscribe - subscribes to messages of channel. If from is not zero, retained messages
+// starting from this offset are returned in Backlog, otherwise only new messages are delivered.
+func (h *Hub) Subscribe(ctx context.Context, name string, from uint64) (*Subscriber, error) {
+	if !validName(name) {
+		return nil, ErrInvalidName
+	}
+
+	head := h.head(ctx, name)
+	s := &Subscriber{
+		channel: name,
+		after:   head.offset,
+		events:  make(chan Message, h.cfg.Buffer),
+		done:    make(chan struct{}),
+		once:    &sync.Once{},
+		h:       h,
+	}
+	h.add(s)
+	head.mu.Unlock()
+
+	if from > 0 && from <= s.after {
+		h.store.Snapshot().RangeBetween(msgKey(name, from), msgKey(name, s.after+1), func(key string, m Message) bool {
+			s.backlog = append(s.backlog, m)
+			return true
+		})
+	}
+
+	return s, nil
+}
+
+// Head - last offset of channel, zero if nothing was published yet
+func (h *Hub) Head(ctx context.Context, name string) (uint64, error) {
+	if !validName(name) {
+		return 0, ErrInvalidName
+	}
+
+	head := h.head(ctx, name)
+	defer head.mu.Unlock()
+	return head.offset, nil
+}
+
+// Run - fan-out is done by workers of manager, manager MUST be run too
+func (h *Hub) Run() {
+	h.logger.Info("Running...")
+}
+
+// Stop - disconnects every subscriber
+func (h *Hub) Stop() {
+	h.logger.Info("Stoping...")
+	h.cancel()
+
+	h.subsMu.RLock()
+	defer h.subsMu.RUnlock()
+
+	for _, subs := range h.subs {
+		for s := range subs {
+			s.disconnect()
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package channel
+
+import (
+	"time"
+)
+
+type ChannelConfig struct {
+	// Retention - time, messages are kept for replay
+	Retention time.Duration `yaml:"retention"`
+	// Buffer - number of messages, that subscriber can lag behind, before it is disconnected
+	Buffer int `yaml:"buffer"`
+}
+
+func newChannelConfig(Retention time.Duration, Buffer int) ChannelConfig {
+	return ChannelConfig{
+		Retention: Retention,
+		Buffer:    Buffer,
+	}
+}
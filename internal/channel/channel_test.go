@@ -0,0 +1,282 @@
+package channel
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+func TestMain(m *testing.M) {
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(os.Stdout)
+	os.Exit(m.Run())
+}
+
+func newTestHub(t *testing.T, path string, save bool, cfg ChannelConfig) (*Hub, *ttlstore.MapStore[string, Message]) {
+	store := ttlstore.NewMapStore[string, Message](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, path, save))
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := make([]*ttlstore.MapStore[string, string], 2)
+	for i := range stores {
+		stores[i] = ttlstore.NewMapStore[string, string](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	}
+
+	wm := manager.NewWorkerManager(context.Background(), stores, logger, manager.ManagerConfig{
+		WorkerNum: uint(len(stores)),
+		ValTTL:    time.Minute,
+	})
+	wm.Run()
+	t.Cleanup(wm.Stop)
+
+	h := NewHub(context.Background(), store, wm, logger.WithField("test", t.Name()), cfg)
+	h.Run()
+	return h, store
+}
+
+func publish(t *testing.T, h *Hub, name string, payloads ...string) {
+	for _, p := range payloads {
+		if _, err := h.Publish(context.Background(), name, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func receive(t *testing.T, s *Subscriber, payloads ...string) {
+	for _, p := range payloads {
+		select {
+		case m := <-s.Events():
+			if m.Payload != p {
+				t.Fatalf("Want %s, got: %+v", p, m)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Want %s, got nothing", p)
+		}
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	ctx := context.Background()
+
+	h, store := newTestHub(t, "", false, newChannelConfig(time.Minute, 16))
+	defer store.Close()
+	defer h.Stop()
+
+	if _, err := h.Publish(ctx, "a:b", "x"); err != ErrInvalidName {
+		t.Errorf("Want ErrInvalidName, got: %v", err)
+	}
+
+	subs := make([]*Subscriber, 5)
+	for i := range subs {
+		s, err := h.Subscribe(ctx, "news", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		subs[i] = s
+	}
+
+	other, err := h.Subscribe(ctx, "other", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	publish(t, h, "news", "1", "2", "3")
+
+	for _, s := range subs {
+		receive(t, s, "1", "2", "3")
+	}
+
+	select {
+	case m := <-other.Events():
+		t.Fatalf("Want nothing, got: %+v", m)
+	default:
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	ctx := context.Background()
+
+	h, store := newTestHub(t, "", false, newChannelConfig(time.Second, 16))
+	defer store.Close()
+	defer h.Stop()
+
+	publish(t, h, "news", "1", "2", "3")
+
+	s, err := h.Subscribe(ctx, "news", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	backlog := s.Backlog()
+	if len(backlog) != 2 || backlog[0].Offset != 2 || backlog[1].Payload != "3" {
+		t.Fatalf("Unexpected backlog: %+v", backlog)
+	}
+
+	publish(t, h, "news", "4")
+	receive(t, s, "4")
+
+	// Retained messages expire, offsets keep growing
+	time.Sleep(time.Second * 2)
+
+	late, err := h.Subscribe(ctx, "news", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+
+	if len(late.Backlog()) != 0 {
+		t.Fatalf("Want empty backlog, got: %+v", late.Backlog())
+	}
+
+	if m, _ := h.Publish(ctx, "news", "5"); m.Offset != 5 {
+		t.Errorf("Want offset 5, got: %d", m.Offset)
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	ctx := context.Background()
+
+	h, store := newTestHub(t, "", false, newChannelConfig(time.Minute, 2))
+	defer store.Close()
+	defer h.Stop()
+
+	s, err := h.Subscribe(ctx, "news", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	publish(t, h, "news", "1", "2", "3", "4")
+
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Slow subscriber was not disconnected")
+	}
+}
+
+func TestHubRestore(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#channels.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := newChannelConfig(time.Minute, 16)
+
+	h, store := newTestHub(t, filename, true, cfg)
+	publish(t, h, "news", "1", "2")
+	h.Stop()
+	store.Close()
+
+	h, store = newTestHub(t, filename, true, cfg)
+	defer store.Close()
+	defer h.Stop()
+
+	if head, _ := h.Head(ctx, "news"); head != 2 {
+		t.Fatalf("Want head 2, got: %d", head)
+	}
+
+	s, err := h.Subscribe(ctx, "news", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if len(s.Backlog()) != 2 {
+		t.Fatalf("Want 2 retained messages, got: %+v", s.Backlog())
+	}
+
+	publish(t, h, "news", "3")
+	receive(t, s, "3")
+}
+
+func TestHubZeroConfig(t *testing.T) {
+	ctx := context.Background()
+
+	h, store := newTestHub(t, "", false, newChannelConfig(0, 0))
+	defer store.Close()
+	defer h.Stop()
+
+	if h.cfg.Retention != DEFAULT_RETENTION || h.cfg.Buffer != DEFAULT_SUBSCRIBER_BUFFER {
+		t.Errorf("Want defaults, got: %+v", h.cfg)
+	}
+
+	publish(t, h, "news", "1", "2")
+
+	s, err := h.Subscribe(ctx, "news", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if len(s.Backlog()) != 2 {
+		t.Fatalf("Want 2 retained messages, got: %+v", s.Backlog())
+	}
+}
+
+func TestHubOrder(t *testing.T) {
+	ctx := context.Background()
+
+	h, store := newTestHub(t, "", false, newChannelConfig(time.Minute, 1024))
+	defer store.Close()
+	defer h.Stop()
+
+	names := []string{"a", "b", "c", "d"}
+	subs := make([]*Subscriber, len(names))
+	for i, name := range names {
+		s, err := h.Subscribe(ctx, name, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		subs[i] = s
+	}
+
+	// Concurrent publishers of every channel
+	wg := &sync.WaitGroup{}
+	for _, name := range names {
+		for p := 0; p < 4; p++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					if _, err := h.Publish(ctx, name, "x"); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(name)
+		}
+	}
+	wg.Wait()
+
+	for i, s := range subs {
+		for offset := uint64(1); offset <= 200; offset++ {
+			select {
+			case m := <-s.Events():
+				if m.Offset != offset {
+					t.Fatalf("Channel %s: want offset %d, got: %d", names[i], offset, m.Offset)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Channel %s: want offset %d, got nothing", names[i], offset)
+			}
+		}
+	}
+}
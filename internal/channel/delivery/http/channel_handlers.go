@@ -0,0 +1,199 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BON4/timedQ/internal/channel"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+const subscribeHeartbeat = 15 * time.Second
+
+var errInvalidOffset = errors.New("from must be positive integer offset")
+
+type channelPublishRequest struct {
+	Payload string `json:"payload" binding:"required"`
+}
+
+type channelMessageResponse struct {
+	Channel   string `json:"channel"`
+	Offset    uint64 `json:"offset"`
+	Payload   string `json:"payload"`
+	Published int64  `json:"published"`
+}
+
+type channelHandler struct {
+	logger *logrus.Entry
+	hub    *channel.Hub
+}
+
+func newChannelMessageResponse(m channel.Message) channelMessageResponse {
+	return channelMessageResponse{
+		Channel:   m.Channel,
+		Offset:    m.Offset,
+		Payload:   m.Payload,
+		Published: m.Published,
+	}
+}
+
+// subscribe - subscribes to channel from offset in from query param
+func (h *channelHandler) subscribe(c *gin.Context) (*channel.Subscriber, bool) {
+	var from uint64
+	if q := c.Query("from"); q != "" {
+		var err error
+		if from, err = strconv.ParseUint(q, 10, 64); err != nil || from == 0 {
+			c.AbortWithError(http.StatusBadRequest, errInvalidOffset)
+			return nil, false
+		}
+	}
+
+	sub, err := h.hub.Subscribe(c.Request.Context(), c.Param("name"), from)
+	if errors.Is(err, channel.ErrInvalidName) {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return nil, false
+	} else if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return sub, true
+}
+
+// @Summary      Publish
+// @Description  publishes message to channel. Message is retained for retention time, so late subscribers can replay it
+// @Tags         channels
+// @Accept       json
+// @Produce      json
+// @Param        name   path      string                 true  "channel name"
+// @Param        input  body      channelPublishRequest  true  "message"
+// @Success      200    {object}  channelMessageResponse
+// @Failure      400    {object}  error
+// @Failure      500    {object}  error
+// @Router       /channels/{name} [post]
+func (h *channelHandler) Publish() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &channelPublishRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		m, err := h.hub.Publish(c.Request.Context(), c.Param("name"), req.Payload)
+		if errors.Is(err, channel.ErrInvalidName) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, newChannelMessageResponse(m))
+	}
+}
+
+// @Summary      Subscribe
+// @Description  streams messages of channel as server-sent message events. If from is set, retained messages
+// @Description  starting from this offset are replayed first. Slow client gets overflow event and is disconnected,
+// @Description  it can resubscribe from offset of next message
+// @Tags         channels
+// @Produce      text/event-stream
+// @Param        name  path      string   true   "channel name"
+// @Param        from  query     integer  false  "replay retained messages starting from offset"
+// @Success      200   {object}  channelMessageResponse
+// @Failure      400   {object}  error
+// @Router       /channels/{name} [get]
+func (h *channelHandler) Subscribe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, ok := h.subscribe(c)
+		if !ok {
+			return
+		}
+		defer sub.Close()
+
+		for _, m := range sub.Backlog() {
+			c.SSEvent("message", newChannelMessageResponse(m))
+		}
+
+		heartbeat := time.NewTicker(subscribeHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case m := <-sub.Events():
+				c.SSEvent("message", newChannelMessageResponse(m))
+				return true
+			case <-sub.Done():
+				c.SSEvent("overflow", "")
+				return false
+			case <-heartbeat.C:
+				c.SSEvent("heartbeat", "")
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// @Summary      Subscribe over WebSocket
+// @Description  same as Subscribe, but every message is sent as JSON text frame over WebSocket
+// @Tags         channels
+// @Param        name  path      string   true   "channel name"
+// @Param        from  query     integer  false  "replay retained messages starting from offset"
+// @Success      101   {object}  channelMessageResponse
+// @Failure      400   {object}  error
+// @Router       /channels/{name}/ws [get]
+func (h *channelHandler) SubscribeWebSocket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, ok := h.subscribe(c)
+		if !ok {
+			return
+		}
+		defer sub.Close()
+
+		websocket.Server{
+			Handler: func(ws *websocket.Conn) {
+				defer ws.Close()
+
+				// Client messages are ignored, read fails when client goes away
+				gone := make(chan struct{})
+				go func() {
+					defer close(gone)
+					io.Copy(io.Discard, ws)
+				}()
+
+				for _, m := range sub.Backlog() {
+					if err := websocket.JSON.Send(ws, newChannelMessageResponse(m)); err != nil {
+						return
+					}
+				}
+
+				for {
+					select {
+					case m := <-sub.Events():
+						if err := websocket.JSON.Send(ws, newChannelMessageResponse(m)); err != nil {
+							return
+						}
+					case <-sub.Done():
+						return
+					case <-gone:
+						return
+					}
+				}
+			},
+		}.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func NewChannelHandler(hub *channel.Hub, logger *logrus.Entry) *channelHandler {
+	return &channelHandler{
+		logger: logger,
+		hub:    hub,
+	}
+}
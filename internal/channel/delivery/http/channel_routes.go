@@ -0,0 +1,9 @@
+package http
+
+import "github.com/gin-gonic/gin"
+
+func NewChannelRoutes(group *gin.RouterGroup, h *channelHandler) {
+	group.POST("/:name", h.Publish())
+	group.GET("/:name", h.Subscribe())
+	group.GET("/:name/ws", h.SubscribeWebSocket())
+}
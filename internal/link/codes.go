@@ -0,0 +1,161 @@
+package link
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/pkg/ttlstore"
+)
+
+// CodeGenerator - how codes of links are generated
+type CodeGenerator string
+
+const (
+	// CodesRandom - random base62 codes of cfg.CodeLength, taken codes are retried
+	CodesRandom CodeGenerator = "random"
+	// CodesCounter - base62 of persisted counter. Codes are the shortest, but they are predictable.
+	CodesCounter CodeGenerator = "counter"
+
+	base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// counterKey - key of counter in store of links. Keys of reverse index start with status,
+	// so counter never collides with them, and key-value api can not write it.
+	counterKey = "counter"
+	// legacyCounterKey - key of counter, that was kept with values, counter is seeded from it once
+	legacyCounterKey = "links:counter"
+)
+
+var (
+	ErrInvalidAlias  = errors.New("alias must be 3 to 64 letters, digits, '-' or '_'")
+	ErrAliasReserved = errors.New("alias is reserved")
+	ErrAliasProfane  = errors.New("alias contains blocked word")
+	ErrAliasTaken    = errors.New("alias is already taken")
+	ErrNoFreeCode    = errors.New("could not find free code, retries are exhausted")
+
+	aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,64}$`)
+
+	// reservedAliases - paths of service, alias equal to them would be confusing
+	reservedAliases = []string{"admin", "api", "r", "v1", "swagger", "keys", "watch", "batch", "tx",
+		"queues", "schedules", "channels", "locks", "health", "static"}
+
+	defaultProfanity = []string{"fuck", "shit", "cunt", "bitch", "whore", "slut", "bastard", "asshole"}
+
+	// leet - digits, that are used instead of letters to hide words
+	leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "-", "", "_", "")
+)
+
+type generator interface {
+	next(ctx context.Context) (string, error)
+}
+
+type randomGenerator struct {
+	length int
+}
+
+// next - base62 code from crypto/rand. Bytes >= 248 are dropped, so every symbol is equally likely.
+func (g *randomGenerator) next(_ context.Context) (string, error) {
+	code := make([]byte, 0, g.length)
+	buf := make([]byte, g.length*2)
+
+	for len(code) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			if b < 248 && len(code) < g.length {
+				code = append(code, base62[b%62])
+			}
+		}
+	}
+
+	return string(code), nil
+}
+
+type counterGenerator struct {
+	wm    *manager.WorkerManager
+	index *ttlstore.MapStore[string, string]
+}
+
+// next - increments counter in store of links. Counter of previous version is read from values,
+// until counter is written to store of links, value, that is not counter, is ignored.
+func (g *counterGenerator) next(ctx context.Context) (string, error) {
+	var legacy uint64
+	if _, ok := g.index.Get(ctx, counterKey); !ok {
+		val, _, err := g.wm.Get(ctx, legacyCounterKey)
+		if err != nil {
+			return "", err
+		}
+		legacy, _ = strconv.ParseUint(val, 10, 64)
+	}
+
+	var n uint64
+	if err := g.index.Update(ctx, func(get func(key string) (string, bool), tx *ttlstore.Tx[string, string]) error {
+		n = legacy
+		if val, ok := get(counterKey); ok {
+			var err error
+			if n, err = strconv.ParseUint(val, 10, 64); err != nil {
+				return manager.ErrNotCounter
+			}
+		}
+
+		n++
+		tx.Set(counterKey, strconv.FormatUint(n, 10), -1)
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return encodeBase62(n), nil
+}
+
+func encodeBase62(n uint64) string {
+	if n == 0 {
+		return base62[:1]
+	}
+
+	code := make([]byte, 0, 11)
+	for ; n > 0; n /= 62 {
+		code = append(code, base62[n%62])
+	}
+
+	for i, j := 0, len(code)-1; i < j; i, j = i+1, j-1 {
+		code[i], code[j] = code[j], code[i]
+	}
+	return string(code)
+}
+
+// profane - code contains blocked word, also when letters are replaced by digits or words are split by '-' and '_'
+func (s *Shortener) profane(code string) bool {
+	lower := strings.ToLower(code)
+	plain := leet.Replace(lower)
+
+	for _, word := range s.profanity {
+		if strings.Contains(lower, word) || strings.Contains(plain, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAlias - checks, that alias, chosen by caller, has allowed symbols, is not reserved and has no blocked words
+func (s *Shortener) ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return ErrInvalidAlias
+	}
+
+	if _, ok := s.reserved[strings.ToLower(alias)]; ok {
+		return ErrAliasReserved
+	}
+
+	if s.profane(alias) {
+		return ErrAliasProfane
+	}
+
+	return nil
+}
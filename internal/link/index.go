@@ -0,0 +1,74 @@
+package link
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/purell"
+)
+
+// normalizeFlags - normalizations, that do not change resource, url points to, in practice
+const normalizeFlags = purell.FlagsUsuallySafeGreedy | purell.FlagRemoveDuplicateSlashes
+
+// indexKey - key of link in reverse index. Links with different status are different links,
+// so status is a part of key.
+func indexKey(l Link) (string, error) {
+	norm, err := purell.NormalizeURLString(l.URL, normalizeFlags)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(l.Status) + " " + norm, nil
+}
+
+// minIndexTTL - ttl of reverse entry of link, that expires in less than it or has expired already.
+// Zero ttl is not stored and negative one never expires, so entry lives a bit longer than link,
+// existing checks link anyway.
+const minIndexTTL = time.Second
+
+// indexTTL - reverse entry expires together with link, entry of link without expiration never expires
+func (s *Shortener) indexTTL(l Link) time.Duration {
+	if l.Expires > 0 {
+		if ttl := time.Until(time.Unix(l.Expires, 0)); ttl > minIndexTTL {
+			return ttl
+		}
+		return minIndexTTL
+	}
+	return -1
+}
+
+// existing - live code of link with the same normalized url and status. Reverse entry can outlive link,
+// or link can be replaced, so link is checked before code is returned.
+func (s *Shortener) existing(ctx context.Context, key string) (string, Link, bool, error) {
+	code, ok := s.index.Get(ctx, key)
+	if !ok {
+		return "", Link{}, false, nil
+	}
+
+	l, found, err := s.get(ctx, code)
+	if err != nil || !found || l.Expired(time.Now()) {
+		return "", Link{}, false, err
+	}
+
+	if k, err := indexKey(l); err != nil || k != key {
+		return "", Link{}, false, nil
+	}
+
+	return code, l, true, nil
+}
+
+// reuse - returns existing code of link. With cfg.RefreshTTL expiration of link is replaced by expiration of new one.
+func (s *Shortener) reuse(ctx context.Context, key string, code string, cur Link, l Link, opts Options) (string, error) {
+	if !s.cfg.RefreshTTL || cur.Expires == l.Expires {
+		return code, nil
+	}
+
+	if err := s.wm.SetWithTTL(ctx, Key(code), encode(l), linkTTL(l), opts.Concern); err != nil {
+		return "", err
+	}
+
+	if err := s.index.Set(ctx, key, code, s.indexTTL(l)); err != nil {
+		return "", err
+	}
+	return code, nil
+}
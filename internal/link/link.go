@@ -0,0 +1,319 @@
+package link
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/pkg/ttlstore"
+)
+
+// linkPrefix - links are stored under link:<code> keys, so other keys can not be resolved as links
+const linkPrefix = "link:"
+
+var (
+	ErrNotFound            = errors.New("link not found")
+	ErrExpired             = errors.New("link has expired")
+	ErrInvalidURL          = errors.New("target must be absolute url with host and without credentials")
+	ErrSchemeNotAllowed    = errors.New("scheme of target url is not allowed")
+	ErrHostBlocked         = errors.New("host of target url is blocked")
+	ErrInvalidStatus       = errors.New("redirect status must be 301, 302, 307 or 308")
+	ErrInvalidTTL          = errors.New("ttl must not be negative")
+	errInvalidStatusConfig = errors.New("default redirect status must be 301, 302, 307 or 308")
+	errInvalidCodesConfig  = errors.New("codes must be random or counter")
+)
+
+// Link - short link. Zero Status means default status from config, zero Expires means link never expires.
+type Link struct {
+	URL     string `json:"url"`
+	Status  int    `json:"status,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+}
+
+// Expired - link is expired at now. Expired link is kept, until ttl of its value ends, so it is answered with 410.
+func (l Link) Expired(now time.Time) bool {
+	return l.Expires > 0 && now.Unix() >= l.Expires
+}
+
+// encode - link is stored as json object
+func encode(l Link) string {
+	b, _ := json.Marshal(l)
+	return string(b)
+}
+
+// decode - reverse of encode. Link keys can be written by key-value api too,
+// so ok is false for value, that is not encoded link.
+func decode(val string) (Link, bool) {
+	var l Link
+	if !strings.HasPrefix(val, "{") || json.Unmarshal([]byte(val), &l) != nil || l.URL == "" {
+		return Link{}, false
+	}
+	return l, true
+}
+
+// Key - key of link with code
+func Key(code string) string {
+	return linkPrefix + code
+}
+
+// goneTTL - time, expired link is kept for, so it is answered with 410, not 404
+const goneTTL = 24 * time.Hour
+
+// linkTTL - ttl of link key. Link without expiration never expires, expired link is kept for goneTTL.
+// Reads do not refresh ttl of link.
+func linkTTL(l Link) time.Duration {
+	if l.Expires > 0 {
+		return time.Until(time.Unix(l.Expires, 0)) + goneTTL
+	}
+	return -1
+}
+
+func validStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Options - options of created link
+type Options struct {
+	// Alias - code, chosen by caller. Empty alias means generated code.
+	Alias string
+	// Status - redirect status, zero means default status
+	Status int
+	// TTL - zero means link never expires
+	TTL time.Duration
+	// Concern - WriteAsync is applied as WriteApplied, because code must be checked to be free
+	Concern manager.WriteConcern
+}
+
+// Shortener - short links on top of worker manager. Link is value of link:<code> key.
+// Reverse index maps normalized url of generated link to its code, so the same url gets the same code.
+type Shortener struct {
+	wm    *manager.WorkerManager
+	index *ttlstore.MapStore[string, string]
+	cfg   LinkConfig
+	gen   generator
+
+	reserved  map[string]struct{}
+	profanity []string
+}
+
+func NewShortener(wm *manager.WorkerManager, index *ttlstore.MapStore[string, string], cfg LinkConfig) (*Shortener, error) {
+	if cfg.Status == 0 {
+		cfg.Status = DEFAULT_STATUS
+	}
+
+	if !validStatus(cfg.Status) {
+		return nil, errInvalidStatusConfig
+	}
+
+	if len(cfg.Schemes) == 0 {
+		cfg.Schemes = []string{"http", "https"}
+	}
+
+	if cfg.CodeLength <= 0 {
+		cfg.CodeLength = DEFAULT_CODE_LENGTH
+	}
+
+	if cfg.Retries <= 0 {
+		cfg.Retries = DEFAULT_RETRIES
+	}
+
+	s := &Shortener{
+		wm:        wm,
+		index:     index,
+		cfg:       cfg,
+		reserved:  make(map[string]struct{}),
+		profanity: append([]string{}, defaultProfanity...),
+	}
+
+	switch cfg.Codes {
+	case "", CodesRandom:
+		s.gen = &randomGenerator{length: cfg.CodeLength}
+	case CodesCounter:
+		s.gen = &counterGenerator{wm: wm, index: index}
+	default:
+		return nil, errInvalidCodesConfig
+	}
+
+	for _, r := range append(reservedAliases, cfg.Reserved...) {
+		s.reserved[strings.ToLower(r)] = struct{}{}
+	}
+
+	for _, w := range cfg.Profanity {
+		s.profanity = append(s.profanity, strings.ToLower(w))
+	}
+
+	return s, nil
+}
+
+// Validate - checks, that target is absolute url with allowed scheme and host, that is not blocked.
+// Relative and scheme relative urls (//host) are rejected, so link can not redirect by host of request,
+// credentials are rejected, so host can not be hidden behind them (https://bank.com@evil.com).
+func (s *Shortener) Validate(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ErrInvalidURL
+	}
+
+	allowed := false
+	for _, scheme := range s.cfg.Schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return ErrSchemeNotAllowed
+	}
+
+	if u.Opaque != "" || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, blocked := range s.cfg.BlockedHosts {
+		blocked = strings.ToLower(blocked)
+		if host == blocked || strings.HasSuffix(host, "."+blocked) {
+			return ErrHostBlocked
+		}
+	}
+
+	return nil
+}
+
+// Create - creates link to target and returns its code. Code is alias of caller, or generated one.
+// Url, that already has live generated link with the same status, gets code of that link.
+// Generated code, that is taken or contains blocked word, is replaced by next one, at most cfg.Retries times.
+func (s *Shortener) Create(ctx context.Context, target string, opts Options) (string, error) {
+	if err := s.Validate(target); err != nil {
+		return "", err
+	}
+
+	if opts.Status != 0 && !validStatus(opts.Status) {
+		return "", ErrInvalidStatus
+	}
+
+	if opts.TTL < 0 {
+		return "", ErrInvalidTTL
+	}
+
+	if opts.Alias != "" {
+		if err := s.ValidateAlias(opts.Alias); err != nil {
+			return "", err
+		}
+	}
+
+	if opts.Concern == manager.WriteAsync {
+		opts.Concern = manager.WriteApplied
+	}
+
+	l := Link{
+		URL:    target,
+		Status: opts.Status,
+	}
+
+	if opts.TTL > 0 {
+		l.Expires = time.Now().Add(opts.TTL).Unix()
+	}
+	val := encode(l)
+
+	if opts.Alias != "" {
+		ok, err := s.wm.SetNXWithTTL(ctx, Key(opts.Alias), val, linkTTL(l), opts.Concern)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return "", ErrAliasTaken
+		}
+		return opts.Alias, nil
+	}
+
+	key, err := indexKey(l)
+	if err != nil {
+		return "", ErrInvalidURL
+	}
+
+	code, cur, ok, err := s.existing(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	if ok {
+		return s.reuse(ctx, key, code, cur, l, opts)
+	}
+
+	for i := 0; i < s.cfg.Retries; i++ {
+		code, err := s.gen.next(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		if s.profane(code) {
+			continue
+		}
+
+		ok, err := s.wm.SetNXWithTTL(ctx, Key(code), val, linkTTL(l), opts.Concern)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			continue
+		}
+
+		// Concurrent creations of the same url can get different codes, last one is kept in index
+		if err := s.index.Set(ctx, key, code, s.indexTTL(l)); err != nil {
+			return "", err
+		}
+		return code, nil
+	}
+
+	return "", ErrNoFreeCode
+}
+
+// get - link by code as it is stored
+func (s *Shortener) get(ctx context.Context, code string) (Link, bool, error) {
+	val, found, err := s.wm.Get(ctx, Key(code))
+	if err != nil || !found {
+		return Link{}, false, err
+	}
+	l, ok := decode(val)
+	return l, ok, nil
+}
+
+// Resolve - returns link by code with status to redirect with. Returns ErrNotFound for missing code,
+// and link with ErrExpired for expired one. Link keys can be written by key-value api too,
+// so link, that does not pass Validate, is not found.
+func (s *Shortener) Resolve(ctx context.Context, code string) (Link, error) {
+	l, found, err := s.get(ctx, code)
+	if err != nil {
+		return Link{}, err
+	}
+
+	if !found || s.Validate(l.URL) != nil || (l.Status != 0 && !validStatus(l.Status)) {
+		return Link{}, ErrNotFound
+	}
+
+	if l.Expired(time.Now()) {
+		return s.withStatus(l), ErrExpired
+	}
+
+	return s.withStatus(l), nil
+}
+
+func (s *Shortener) withStatus(l Link) Link {
+	if l.Status == 0 {
+		l.Status = s.cfg.Status
+	}
+	return l
+}
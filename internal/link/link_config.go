@@ -0,0 +1,42 @@
+package link
+
+import (
+	"net/http"
+)
+
+const (
+	DEFAULT_STATUS      = http.StatusFound
+	DEFAULT_CODE_LENGTH = 7
+	DEFAULT_RETRIES     = 5
+)
+
+type LinkConfig struct {
+	// Status - redirect status of links, created without status: 301, 302 (default), 307 or 308
+	Status int `yaml:"status"`
+	// Schemes - allowed schemes of target urls, http and https by default
+	Schemes []string `yaml:"schemes"`
+	// BlockedHosts - targets on these hosts and their subdomains are rejected, for example host of shortener itself
+	BlockedHosts []string `yaml:"blocked-hosts"`
+	// Codes - generator of codes: random (default) or counter
+	Codes CodeGenerator `yaml:"codes"`
+	// CodeLength - length of random codes
+	CodeLength int `yaml:"code-length"`
+	// Retries - max number of generated codes, tried before creation fails, when codes are taken
+	Retries int `yaml:"retries"`
+	// Reserved - aliases, that can not be chosen, in addition to paths of service
+	Reserved []string `yaml:"reserved"`
+	// Profanity - words, that aliases and generated codes must not contain, in addition to built in list
+	Profanity []string `yaml:"profanity"`
+	// RefreshTTL - when url is shortened again, existing link gets ttl of new request
+	RefreshTTL bool `yaml:"refresh-ttl"`
+}
+
+func newLinkConfig(Status int, Schemes []string, BlockedHosts []string, Codes CodeGenerator, CodeLength int) LinkConfig {
+	return LinkConfig{
+		Status:       Status,
+		Schemes:      Schemes,
+		BlockedHosts: BlockedHosts,
+		Codes:        Codes,
+		CodeLength:   CodeLength,
+	}
+}
@@ -0,0 +1,398 @@
+package link
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+func TestMain(m *testing.M) {
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(os.Stdout)
+	os.Exit(m.Run())
+}
+
+func newTestShortener(t *testing.T, cfg LinkConfig) (*Shortener, func()) {
+	ctx := context.Background()
+
+	store := ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	wm := manager.NewWorkerManager(ctx, []*ttlstore.MapStore[string, string]{store}, logger, manager.ManagerConfig{
+		WorkerNum: 1,
+		// Values of key-value api expire fast, links keep their own ttl
+		ValTTL:    time.Second,
+		OpTimeout: time.Second,
+	})
+	wm.Run()
+
+	index := ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	if err := index.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewShortener(wm, index, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, func() {
+		wm.Stop()
+		store.Close()
+		index.Close()
+	}
+}
+
+func TestLinkValidate(t *testing.T) {
+	s, stop := newTestShortener(t, newLinkConfig(0, nil, []string{"short.io"}, CodesRandom, 0))
+	defer stop()
+
+	cases := map[string]error{
+		"https://example.com/a?b=c":       nil,
+		"HTTP://example.com":              nil,
+		"javascript:alert(1)":             ErrSchemeNotAllowed,
+		"data:text/html,<script>":         ErrSchemeNotAllowed,
+		"ftp://example.com":               ErrSchemeNotAllowed,
+		"//evil.com":                      ErrSchemeNotAllowed,
+		"/local/path":                     ErrSchemeNotAllowed,
+		"http:evil.com":                   ErrInvalidURL,
+		"https://":                        ErrInvalidURL,
+		"https://bank.com@evil.com":       ErrInvalidURL,
+		"https://exa mple.com":            ErrInvalidURL,
+		"https://short.io/abc":            ErrHostBlocked,
+		"https://WWW.Short.io./abc":       ErrHostBlocked,
+		"https://notshort.io/abc":         nil,
+		"https://short.io.example.com/ab": nil,
+	}
+
+	for target, want := range cases {
+		if err := s.Validate(target); err != want {
+			t.Errorf("%s: want %v, got: %v", target, want, err)
+		}
+	}
+}
+
+func TestLinkEncode(t *testing.T) {
+	for _, l := range []Link{{URL: "https://example.com"}, {URL: "https://example.com", Status: 301, Expires: 42}} {
+		if got, ok := decode(encode(l)); !ok || got != l {
+			t.Errorf("Want %+v, got: %+v, %v", l, got, ok)
+		}
+	}
+
+	// Values, that are not encoded links, are not links
+	for _, val := range []string{"https://example.com", "secret", "", "{}", `{"status":301}`, `{"url":"https://example.com"`, `"https://example.com"`} {
+		if l, ok := decode(val); ok {
+			t.Errorf("Want %q not to be link, got: %+v", val, l)
+		}
+	}
+}
+
+func TestLinkResolve(t *testing.T) {
+	ctx := context.Background()
+
+	s, stop := newTestShortener(t, newLinkConfig(http.StatusTemporaryRedirect, nil, nil, CodesRandom, 0))
+	defer stop()
+
+	if _, err := s.Create(ctx, "https://example.com", Options{Status: 303}); err != ErrInvalidStatus {
+		t.Errorf("Want ErrInvalidStatus, got: %v", err)
+	}
+
+	if _, err := s.Create(ctx, "javascript:alert(1)", Options{}); err != ErrSchemeNotAllowed {
+		t.Errorf("Want ErrSchemeNotAllowed, got: %v", err)
+	}
+
+	if _, err := s.Resolve(ctx, "missing"); err != ErrNotFound {
+		t.Errorf("Want ErrNotFound, got: %v", err)
+	}
+
+	code, err := s.Create(ctx, "https://example.com", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := s.Resolve(ctx, code); err != nil || l.URL != "https://example.com" || l.Status != http.StatusTemporaryRedirect {
+		t.Errorf("Want link with default status, got: %+v, %v", l, err)
+	}
+
+	code, err = s.Create(ctx, "https://example.com/tmp", Options{Status: http.StatusMovedPermanently, TTL: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err := s.Resolve(ctx, code); err != nil || l.Status != http.StatusMovedPermanently || l.Expires == 0 {
+		t.Errorf("Want expiring permanent link, got: %+v, %v", l, err)
+	}
+
+	time.Sleep(time.Second * 2)
+
+	if _, err := s.Resolve(ctx, code); err != ErrExpired {
+		t.Errorf("Want ErrExpired, got: %v", err)
+	}
+
+	// Keys written by key-value api are resolved only under link prefix, and only if they pass validation
+	for key, val := range map[string]string{
+		"plain":          "https://example.com",
+		legacyCounterKey: "42",
+		Key("raw"):       "https://example.com",
+		Key("secret"):    "secret",
+		Key("js"):        `{"url":"javascript:alert(1)"}`,
+		Key("relative"):  `{"url":"//evil.com"}`,
+		Key("status"):    `{"url":"https://example.com","status":200}`,
+	} {
+		if err := s.wm.Set(ctx, key, val, manager.WriteApplied); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Resolve(ctx, strings.TrimPrefix(key, linkPrefix)); err != ErrNotFound {
+			t.Errorf("Key %s: want ErrNotFound, got: %v", key, err)
+		}
+	}
+}
+
+func TestLinkCodes(t *testing.T) {
+	ctx := context.Background()
+
+	for n, want := range map[uint64]string{0: "0", 61: "z", 62: "10", 3843: "zz"} {
+		if code := encodeBase62(n); code != want {
+			t.Errorf("%d: want %s, got: %s", n, want, code)
+		}
+	}
+
+	s, stop := newTestShortener(t, newLinkConfig(0, nil, nil, CodesRandom, 10))
+	defer stop()
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		code, err := s.Create(ctx, fmt.Sprintf("https://example.com/%d", i), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(code) != 10 || strings.Trim(code, base62) != "" {
+			t.Errorf("Want 10 base62 symbols, got: %s", code)
+		}
+
+		if _, ok := seen[code]; ok {
+			t.Errorf("Code is repeated: %s", code)
+		}
+		seen[code] = struct{}{}
+	}
+
+	counter, stopCounter := newTestShortener(t, newLinkConfig(0, nil, nil, CodesCounter, 0))
+	defer stopCounter()
+
+	// Code 2 is taken by alias, so it is skipped
+	if _, err := counter.wm.SetNX(ctx, Key("2"), "https://example.com", manager.WriteApplied); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"1", "3", "4"} {
+		if code, err := counter.Create(ctx, fmt.Sprintf("https://example.com/%d", i), Options{}); err != nil || code != want {
+			t.Errorf("Want %s, got: %s, %v", want, code, err)
+		}
+	}
+
+	// Counter is not in key-value namespace, so writes by key-value api do not change it
+	if err := counter.wm.Set(ctx, legacyCounterKey, "not a counter", manager.WriteApplied); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, err := counter.Create(ctx, "https://example.com/5", Options{}); err != nil || code != "5" {
+		t.Errorf("Want 5, got: %s, %v", code, err)
+	}
+}
+
+func TestLinkLegacyCounter(t *testing.T) {
+	ctx := context.Background()
+
+	s, stop := newTestShortener(t, newLinkConfig(0, nil, nil, CodesCounter, 0))
+	defer stop()
+
+	// Counter of previous version is continued
+	if err := s.wm.Set(ctx, legacyCounterKey, "61", manager.WriteApplied); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []string{"10", "11"} {
+		if code, err := s.Create(ctx, fmt.Sprintf("https://example.com/%d", i), Options{}); err != nil || code != want {
+			t.Errorf("Want %s, got: %s, %v", want, code, err)
+		}
+	}
+}
+
+func TestLinkAlias(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := newLinkConfig(0, nil, nil, CodesRandom, 0)
+	cfg.Reserved = []string{"Promo"}
+	cfg.Profanity = []string{"Spam"}
+
+	s, stop := newTestShortener(t, cfg)
+	defer stop()
+
+	cases := map[string]error{
+		"ab":             ErrInvalidAlias,
+		"my link":        ErrInvalidAlias,
+		"links:counter":  ErrInvalidAlias,
+		"Admin":          ErrAliasReserved,
+		"promo":          ErrAliasReserved,
+		"holy-sh1t":      ErrAliasProfane,
+		"b_i_t_c_h":      ErrAliasProfane,
+		"best-SPAM-ever": ErrAliasProfane,
+		"summer-sale":    nil,
+	}
+
+	for alias, want := range cases {
+		if _, err := s.Create(ctx, "https://example.com", Options{Alias: alias}); err != want {
+			t.Errorf("%s: want %v, got: %v", alias, want, err)
+		}
+	}
+
+	if _, err := s.Create(ctx, "https://example.org", Options{Alias: "summer-sale"}); err != ErrAliasTaken {
+		t.Errorf("Want ErrAliasTaken, got: %v", err)
+	}
+
+	if l, err := s.Resolve(ctx, "summer-sale"); err != nil || l.URL != "https://example.com" {
+		t.Errorf("Want first link to be kept, got: %+v, %v", l, err)
+	}
+}
+
+func TestLinkDedupe(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := newLinkConfig(0, nil, nil, CodesRandom, 0)
+	cfg.RefreshTTL = true
+
+	s, stop := newTestShortener(t, cfg)
+	defer stop()
+
+	code, err := s.Create(ctx, "https://Example.com:443/a/../b?x=1", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, err := s.Create(ctx, "https://example.com/b?x=1", Options{}); err != nil || again != code {
+		t.Errorf("Want code %s of the same url, got: %s, %v", code, again, err)
+	}
+
+	// Other status, alias, or url are other links
+	for _, opts := range []Options{{Status: http.StatusMovedPermanently}, {Alias: "my-b"}} {
+		if other, err := s.Create(ctx, "https://example.com/b?x=1", opts); err != nil || other == code {
+			t.Errorf("Want new code for %+v, got: %s, %v", opts, other, err)
+		}
+	}
+
+	if other, err := s.Create(ctx, "https://example.com/b?x=2", Options{}); err != nil || other == code {
+		t.Errorf("Want new code for other url, got: %s, %v", other, err)
+	}
+
+	// Existing link gets ttl of new request, reverse entry expires with it
+	if again, err := s.Create(ctx, "https://example.com/b?x=1", Options{TTL: time.Second}); err != nil || again != code {
+		t.Errorf("Want code %s of the same url, got: %s, %v", code, again, err)
+	}
+
+	if l, err := s.Resolve(ctx, code); err != nil || l.Expires == 0 {
+		t.Errorf("Want refreshed ttl, got: %+v, %v", l, err)
+	}
+
+	time.Sleep(time.Second * 2)
+
+	key, _ := indexKey(Link{URL: "https://example.com/b?x=1"})
+	if _, ok := s.index.Get(ctx, key); ok {
+		t.Error("Want reverse entry to expire with link")
+	}
+
+	if fresh, err := s.Create(ctx, "https://example.com/b?x=1", Options{}); err != nil || fresh == code {
+		t.Errorf("Want new code after link has expired, got: %s, %v", fresh, err)
+	}
+}
+
+func TestLinkIndexTTL(t *testing.T) {
+	s, stop := newTestShortener(t, newLinkConfig(0, nil, nil, CodesRandom, 0))
+	defer stop()
+
+	now := time.Now()
+	for _, l := range []Link{
+		{URL: "https://example.com", Expires: now.Unix()},
+		{URL: "https://example.com", Expires: now.Add(-time.Hour).Unix()},
+		{URL: "https://example.com", Expires: now.Add(time.Second / 2).Unix()},
+	} {
+		if ttl := s.indexTTL(l); ttl != minIndexTTL {
+			t.Errorf("Want ttl %v of link about to expire %+v, got: %v", minIndexTTL, l, ttl)
+		}
+	}
+
+	if ttl := s.indexTTL(Link{URL: "https://example.com", Expires: now.Add(time.Hour).Unix()}); ttl <= time.Minute*59 {
+		t.Errorf("Want ttl till expiration, got: %v", ttl)
+	}
+
+	if ttl := s.indexTTL(Link{URL: "https://example.com"}); ttl >= 0 {
+		t.Errorf("Want reverse entry of link without expiration never to expire, got: %v", ttl)
+	}
+
+	// Reverse entry of link about to expire is stored and expires
+	ctx := context.Background()
+	key, _ := indexKey(Link{URL: "https://example.com"})
+	if err := s.index.Set(ctx, key, "code", s.indexTTL(Link{URL: "https://example.com", Expires: now.Unix()})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.index.Get(ctx, key); !ok {
+		t.Error("Want reverse entry to be stored")
+	}
+
+	time.Sleep(minIndexTTL * 2)
+
+	if _, ok := s.index.Get(ctx, key); ok {
+		t.Error("Want reverse entry to expire")
+	}
+}
+
+func TestLinkTTL(t *testing.T) {
+	ctx := context.Background()
+
+	s, stop := newTestShortener(t, newLinkConfig(0, nil, nil, CodesRandom, 0))
+	defer stop()
+
+	forever, err := s.Create(ctx, "https://example.com/forever", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiring, err := s.Create(ctx, "https://example.com/expiring", Options{TTL: 3 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Links, that are not read, outlive manager.val-ttl
+	time.Sleep(2 * time.Second)
+
+	for _, code := range []string{forever, expiring} {
+		if _, err := s.Resolve(ctx, code); err != nil {
+			t.Errorf("Want link %s, got: %v", code, err)
+		}
+	}
+
+	// Expired link is gone, not missing, reads do not refresh it
+	time.Sleep(2 * time.Second)
+
+	if _, err := s.Resolve(ctx, expiring); err != ErrExpired {
+		t.Errorf("Want ErrExpired, got: %v", err)
+	}
+
+	if _, err := s.Resolve(ctx, forever); err != nil {
+		t.Errorf("Want link without ttl, got: %v", err)
+	}
+}
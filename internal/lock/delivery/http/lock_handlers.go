@@ -0,0 +1,187 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BON4/timedQ/internal/lock"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var errInvalidToken = errors.New("token must be positive integer")
+
+type lockAcquireRequest struct {
+	Owner string `json:"owner" binding:"required"`
+	// TTL - duration string, for example 30s
+	TTL string `json:"ttl" binding:"required"`
+}
+
+type lockRenewRequest struct {
+	Owner string `json:"owner" binding:"required"`
+	Token uint64 `json:"token" binding:"required"`
+	// TTL - duration string, for example 30s
+	TTL string `json:"ttl" binding:"required"`
+}
+
+type lockLeaseResponse struct {
+	Name    string `json:"name"`
+	Owner   string `json:"owner"`
+	Token   uint64 `json:"token"`
+	Expires int64  `json:"expires"`
+}
+
+type lockHandler struct {
+	logger *logrus.Entry
+	locker *lock.Locker
+}
+
+func newLockLeaseResponse(l lock.Lease) lockLeaseResponse {
+	return lockLeaseResponse{
+		Name:    l.Name,
+		Owner:   l.Owner,
+		Token:   l.Token,
+		Expires: l.Expires,
+	}
+}
+
+// respond - writes lease, or maps lock error to status. Conflict response carries current holder.
+func (h *lockHandler) respond(c *gin.Context, lease lock.Lease, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, newLockLeaseResponse(lease))
+	case errors.Is(err, lock.ErrLocked):
+		c.Error(err)
+		c.AbortWithStatusJSON(http.StatusConflict, newLockLeaseResponse(lease))
+	case errors.Is(err, lock.ErrNotHeld):
+		c.AbortWithError(http.StatusConflict, err)
+	case errors.Is(err, lock.ErrInvalidTTL), errors.Is(err, lock.ErrNoOwner):
+		c.AbortWithError(http.StatusBadRequest, err)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// @Summary      Get lock
+// @Description  returns current lease of lock
+// @Tags         locks
+// @Produce      json
+// @Param        name  path      string  true  "lock name"
+// @Success      200   {object}  lockLeaseResponse
+// @Failure      404   {object}  error
+// @Router       /locks/{name} [get]
+func (h *lockHandler) Get() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lease, ok := h.locker.Get(c.Request.Context(), c.Param("name"))
+		if !ok {
+			c.AbortWithError(http.StatusNotFound, lock.ErrNotHeld)
+			return
+		}
+
+		c.JSON(http.StatusOK, newLockLeaseResponse(lease))
+	}
+}
+
+// @Summary      Acquire lock
+// @Description  acquires lock for ttl and returns lease with new fencing token. Tokens grow with every acquisition,
+// @Description  also after restart. If owner already holds the lock, lease is extended and token is kept.
+// @Description  If lock is held by other owner, 409 is returned with current lease
+// @Tags         locks
+// @Accept       json
+// @Produce      json
+// @Param        name   path      string              true  "lock name"
+// @Param        input  body      lockAcquireRequest  true  "owner and ttl"
+// @Success      200    {object}  lockLeaseResponse
+// @Failure      400    {object}  error
+// @Failure      409    {object}  lockLeaseResponse
+// @Failure      500    {object}  error
+// @Router       /locks/{name} [post]
+func (h *lockHandler) Acquire() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &lockAcquireRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		lease, err := h.locker.Acquire(c.Request.Context(), c.Param("name"), req.Owner, ttl)
+		h.respond(c, lease, err)
+	}
+}
+
+// @Summary      Renew lock
+// @Description  extends lease for ttl, if lock is still held by owner with token
+// @Tags         locks
+// @Accept       json
+// @Produce      json
+// @Param        name   path      string            true  "lock name"
+// @Param        input  body      lockRenewRequest  true  "owner, token and ttl"
+// @Success      200    {object}  lockLeaseResponse
+// @Failure      400    {object}  error
+// @Failure      409    {object}  error
+// @Failure      500    {object}  error
+// @Router       /locks/{name} [put]
+func (h *lockHandler) Renew() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &lockRenewRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		lease, err := h.locker.Renew(c.Request.Context(), c.Param("name"), req.Owner, req.Token, ttl)
+		h.respond(c, lease, err)
+	}
+}
+
+// @Summary      Release lock
+// @Description  releases lock, if it is still held by owner with token
+// @Tags         locks
+// @Param        name   path   string   true  "lock name"
+// @Param        owner  query  string   true  "lock owner"
+// @Param        token  query  integer  true  "fencing token of lease"
+// @Success      204
+// @Failure      400    {object}  error
+// @Failure      409    {object}  error
+// @Failure      500    {object}  error
+// @Router       /locks/{name} [delete]
+func (h *lockHandler) Release() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := strconv.ParseUint(c.Query("token"), 10, 64)
+		if err != nil || token == 0 {
+			c.AbortWithError(http.StatusBadRequest, errInvalidToken)
+			return
+		}
+
+		err = h.locker.Release(c.Request.Context(), c.Param("name"), c.Query("owner"), token)
+		switch {
+		case err == nil:
+			c.Status(http.StatusNoContent)
+		case errors.Is(err, lock.ErrNotHeld):
+			c.AbortWithError(http.StatusConflict, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+	}
+}
+
+func NewLockHandler(locker *lock.Locker, logger *logrus.Entry) *lockHandler {
+	return &lockHandler{
+		logger: logger,
+		locker: locker,
+	}
+}
@@ -0,0 +1,10 @@
+package http
+
+import "github.com/gin-gonic/gin"
+
+func NewLockRoutes(group *gin.RouterGroup, h *lockHandler) {
+	group.GET("/:name", h.Get())
+	group.POST("/:name", h.Acquire())
+	group.PUT("/:name", h.Renew())
+	group.DELETE("/:name", h.Release())
+}
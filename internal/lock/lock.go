@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+)
+
+const (
+	leasePrefix = "lease:"
+	fencePrefix = "fence:"
+)
+
+var (
+	ErrLocked     = errors.New("lock is held by other owner")
+	ErrNotHeld    = errors.New("lock is not held by owner with this token")
+	ErrInvalidTTL = errors.New("ttl must be at least one second and not greater than max ttl")
+	ErrNoOwner    = errors.New("owner must not be empty")
+)
+
+// Lease - lock held by owner until Expires (unix time).
+// Token is fencing token, it grows with every acquisition of lock, so resource can reject writes of stale holders.
+type Lease struct {
+	Name    string
+	Owner   string
+	Token   uint64
+	Expires int64
+}
+
+func leaseKey(name string) string {
+	return leasePrefix + name
+}
+
+func fenceKey(name string) string {
+	return fencePrefix + name
+}
+
+// Locker - leases on top of ttlstore. Lease is stored under lease:<name> key with ttl,
+// last issued token is stored under fence:<name> key without ttl, so tokens keep growing after restart.
+type Locker struct {
+	store *ttlstore.MapStore[string, Lease]
+	cfg   LockConfig
+}
+
+func NewLocker(store *ttlstore.MapStore[string, Lease], cfg LockConfig) *Locker {
+	return &Locker{
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+func (l *Locker) validTTL(ttl time.Duration) bool {
+	return ttl >= time.Second && (l.cfg.MaxTTL <= 0 || ttl <= l.cfg.MaxTTL)
+}
+
+// Acquire - acquires lock for ttl with new fencing token. If owner already holds the lock, lease is extended and token is kept.
+// If lock is held by other owner, current lease is returned with ErrLocked.
+func (l *Locker) Acquire(ctx context.Context, name string, owner string, ttl time.Duration) (Lease, error) {
+	if owner == "" {
+		return Lease{}, ErrNoOwner
+	}
+
+	if !l.validTTL(ttl) {
+		return Lease{}, ErrInvalidTTL
+	}
+
+	var lease Lease
+	issued := false
+	err := l.store.Update(ctx, func(get func(key string) (Lease, bool), tx *ttlstore.Tx[string, Lease]) error {
+		cur, held := get(leaseKey(name))
+		if held && cur.Owner != owner {
+			lease = cur
+			return ErrLocked
+		}
+
+		lease = Lease{
+			Name:    name,
+			Owner:   owner,
+			Token:   cur.Token,
+			Expires: time.Now().Add(ttl).Unix(),
+		}
+
+		if !held {
+			fence, _ := get(fenceKey(name))
+			lease.Token = fence.Token + 1
+			tx.Set(fenceKey(name), Lease{Name: name, Token: lease.Token}, -1)
+			issued = true
+		}
+
+		tx.Set(leaseKey(name), lease, ttl)
+		return nil
+	})
+
+	if err != nil || !issued {
+		return lease, err
+	}
+
+	// New token is returned only after it is on disk, so token is never issued twice after crash
+	if err := l.store.Sync(ctx); err != nil {
+		return Lease{}, err
+	}
+	return lease, nil
+}
+
+// Renew - extends lease for ttl, if it is still held by owner with token
+func (l *Locker) Renew(ctx context.Context, name string, owner string, token uint64, ttl time.Duration) (Lease, error) {
+	if !l.validTTL(ttl) {
+		return Lease{}, ErrInvalidTTL
+	}
+
+	var lease Lease
+	err := l.store.Update(ctx, func(get func(key string) (Lease, bool), tx *ttlstore.Tx[string, Lease]) error {
+		cur, held := get(leaseKey(name))
+		if !held || cur.Owner != owner || cur.Token != token {
+			return ErrNotHeld
+		}
+
+		lease = cur
+		lease.Expires = time.Now().Add(ttl).Unix()
+
+		tx.Set(leaseKey(name), lease, ttl)
+		return nil
+	})
+
+	return lease, err
+}
+
+// Release - releases lock, if it is still held by owner with token
+func (l *Locker) Release(ctx context.Context, name string, owner string, token uint64) error {
+	return l.store.Update(ctx, func(get func(key string) (Lease, bool), tx *ttlstore.Tx[string, Lease]) error {
+		cur, held := get(leaseKey(name))
+		if !held || cur.Owner != owner || cur.Token != token {
+			return ErrNotHeld
+		}
+
+		tx.Delete(leaseKey(name))
+		return nil
+	})
+}
+
+// Get - current lease of lock
+func (l *Locker) Get(ctx context.Context, name string) (Lease, bool) {
+	return l.store.Get(ctx, leaseKey(name))
+}
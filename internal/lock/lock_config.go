@@ -0,0 +1,16 @@
+package lock
+
+import (
+	"time"
+)
+
+type LockConfig struct {
+	// MaxTTL - longest lease, that can be acquired or renewed at once
+	MaxTTL time.Duration `yaml:"max-ttl"`
+}
+
+func newLockConfig(MaxTTL time.Duration) LockConfig {
+	return LockConfig{
+		MaxTTL: MaxTTL,
+	}
+}
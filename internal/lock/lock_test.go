@@ -0,0 +1,227 @@
+package lock
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+)
+
+func newTestLocker(t *testing.T, path string, save bool) (*Locker, *ttlstore.MapStore[string, Lease]) {
+	store := ttlstore.NewMapStore[string, Lease](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, path, save))
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewLocker(store, newLockConfig(time.Minute)), store
+}
+
+func TestLockAcquire(t *testing.T) {
+	ctx := context.Background()
+
+	l, store := newTestLocker(t, "", false)
+	defer store.Close()
+
+	if _, err := l.Acquire(ctx, "job", "a", time.Hour); err != ErrInvalidTTL {
+		t.Errorf("Want ErrInvalidTTL, got: %v", err)
+	}
+
+	first, err := l.Acquire(ctx, "job", "a", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Token != 1 {
+		t.Errorf("Want token 1, got: %d", first.Token)
+	}
+
+	held, err := l.Acquire(ctx, "job", "b", time.Second)
+	if err != ErrLocked || held.Owner != "a" {
+		t.Fatalf("Want ErrLocked with holder a, got: %+v, %v", held, err)
+	}
+
+	// Owner can acquire again, token is kept
+	if again, err := l.Acquire(ctx, "job", "a", time.Second); err != nil || again.Token != first.Token {
+		t.Fatalf("Want same token, got: %+v, %v", again, err)
+	}
+
+	// Lease expires, next holder gets greater token
+	time.Sleep(time.Second * 3)
+
+	second, err := l.Acquire(ctx, "job", "b", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.Token <= first.Token {
+		t.Errorf("Want token greater than %d, got: %d", first.Token, second.Token)
+	}
+
+	if _, err := l.Renew(ctx, "job", "a", first.Token, time.Second); err != ErrNotHeld {
+		t.Errorf("Want ErrNotHeld, got: %v", err)
+	}
+}
+
+func TestLockRenewRelease(t *testing.T) {
+	ctx := context.Background()
+
+	l, store := newTestLocker(t, "", false)
+	defer store.Close()
+
+	lease, err := l.Acquire(ctx, "job", "a", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := l.Renew(ctx, "job", "a", lease.Token, time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if renewed.Token != lease.Token || renewed.Expires <= lease.Expires {
+		t.Errorf("Unexpected renewed lease: %+v", renewed)
+	}
+
+	// Renewed lease outlives original ttl
+	time.Sleep(time.Second * 3)
+
+	if _, ok := l.Get(ctx, "job"); !ok {
+		t.Fatal("Renewed lease expired")
+	}
+
+	if err := l.Release(ctx, "job", "b", lease.Token); err != ErrNotHeld {
+		t.Errorf("Want ErrNotHeld, got: %v", err)
+	}
+
+	if err := l.Release(ctx, "job", "a", lease.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := l.Get(ctx, "job"); ok {
+		t.Fatal("Want released lock")
+	}
+
+	next, err := l.Acquire(ctx, "job", "b", time.Second)
+	if err != nil || next.Token != lease.Token+1 {
+		t.Fatalf("Want token %d, got: %+v, %v", lease.Token+1, next, err)
+	}
+}
+
+func TestLockConcurrentAcquire(t *testing.T) {
+	ctx := context.Background()
+
+	l, store := newTestLocker(t, "", false)
+	defer store.Close()
+
+	owners := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	acquired := make(chan Lease, len(owners))
+
+	wg := &sync.WaitGroup{}
+	for _, owner := range owners {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			if lease, err := l.Acquire(ctx, "job", owner, time.Minute); err == nil {
+				acquired <- lease
+			} else if err != ErrLocked {
+				t.Error(err)
+			}
+		}(owner)
+	}
+	wg.Wait()
+	close(acquired)
+
+	if len(acquired) != 1 {
+		t.Fatalf("Want exactly one holder, got: %d", len(acquired))
+	}
+}
+
+func TestLockRestore(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#locks.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	l, store := newTestLocker(t, filename, true)
+
+	lease, err := l.Acquire(ctx, "job", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Release(ctx, "job", "a", lease.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := l.Acquire(ctx, "held", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	l, store = newTestLocker(t, filename, true)
+	defer store.Close()
+
+	if _, err := l.Acquire(ctx, "held", "b", time.Minute); err != ErrLocked {
+		t.Errorf("Want ErrLocked after restart, got: %v", err)
+	}
+
+	if _, err := l.Renew(ctx, "held", "a", held.Token, time.Minute); err != nil {
+		t.Errorf("Want lease to survive restart, got: %v", err)
+	}
+
+	next, err := l.Acquire(ctx, "job", "b", time.Minute)
+	if err != nil || next.Token <= lease.Token {
+		t.Fatalf("Want token greater than %d after restart, got: %+v, %v", lease.Token, next, err)
+	}
+}
+
+func TestLockFenceCrash(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#locks_crash.db"
+	crashed := "#locks_crashed.db"
+	os.Remove(filename)
+	os.Remove(crashed)
+	defer os.Remove(filename)
+	defer os.Remove(crashed)
+
+	l, store := newTestLocker(t, filename, true)
+	defer store.Close()
+
+	lease, err := l.Acquire(ctx, "job", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Process crashes right after token is returned, store is not closed, so only synced records are in file
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(crashed, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, restartedStore := newTestLocker(t, crashed, true)
+	defer restartedStore.Close()
+
+	next, err := restarted.Acquire(ctx, "job", "b", time.Minute)
+	if err != ErrLocked && (err != nil || next.Token <= lease.Token) {
+		t.Fatalf("Want lease of a or token greater than %d after crash, got: %+v, %v", lease.Token, next, err)
+	}
+
+	if err == ErrLocked && next.Token != lease.Token {
+		t.Errorf("Want lease with token %d after crash, got: %+v", lease.Token, next)
+	}
+}
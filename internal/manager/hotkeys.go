@@ -0,0 +1,211 @@
+package manager
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	sketchWidth = 1 << 12
+	sketchDepth = 4
+)
+
+// sketch - count-min sketch of key reads. Estimate is never lower than real count,
+// counters are halved by decay, so reads of the past are forgotten.
+type sketch struct {
+	rows [sketchDepth][sketchWidth]atomic.Uint32
+}
+
+// add - counts read of key, returns estimated number of reads
+func (s *sketch) add(key string) uint32 {
+	h := xxhash.Sum64String(key)
+	h1, h2 := h&0xffffffff, h>>32|1
+
+	est := ^uint32(0)
+	for i := range s.rows {
+		if n := s.rows[i][(h1+uint64(i)*h2)%sketchWidth].Add(1); n < est {
+			est = n
+		}
+	}
+	return est
+}
+
+func (s *sketch) decay() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			c := &s.rows[i][j]
+			c.Store(c.Load() / 2)
+		}
+	}
+}
+
+// hotKey - key with the most reads. Written is epoch of last write, replicas filled before it are stale.
+type hotKey struct {
+	reads   atomic.Uint32
+	written atomic.Uint64
+}
+
+// HotKey - estimated number of reads of key since last decay halved counters
+type HotKey struct {
+	Key   string
+	Reads uint32
+}
+
+// hotKeys - top of keys by reads. Keys with at least minReads reads are hot, they are read from replicas.
+type hotKeys struct {
+	limit    int
+	minReads uint32
+
+	sketch *sketch
+	// epoch - grows with every write of hot key
+	epoch atomic.Uint64
+
+	mu  *sync.RWMutex
+	top map[string]*hotKey
+}
+
+func newHotKeys(limit int, minReads uint32) *hotKeys {
+	hk := &hotKeys{
+		limit:    limit,
+		minReads: minReads,
+		mu:       &sync.RWMutex{},
+		top:      make(map[string]*hotKey),
+	}
+
+	if limit > 0 {
+		hk.sketch = &sketch{}
+	}
+	return hk
+}
+
+// read - counts read of key, returns true if key is hot
+func (hk *hotKeys) read(key string) bool {
+	if hk.limit <= 0 {
+		return false
+	}
+
+	est := hk.sketch.add(key)
+
+	hk.mu.RLock()
+	k, ok := hk.top[key]
+	hk.mu.RUnlock()
+
+	if ok {
+		k.reads.Store(est)
+		return est >= hk.minReads
+	}
+
+	if est < hk.minReads {
+		return false
+	}
+
+	hk.mu.Lock()
+	defer hk.mu.Unlock()
+
+	if _, ok := hk.top[key]; !ok && !hk.evict(est) {
+		return false
+	}
+
+	k = &hotKey{}
+	k.reads.Store(est)
+	// Replicas of key, filled while it was not tracked, are stale
+	k.written.Store(hk.epoch.Add(1))
+	hk.top[key] = k
+
+	return true
+}
+
+// evict - makes room for key with est reads, returns false if every key in top is hotter. MUST be called under hk.mu
+func (hk *hotKeys) evict(est uint32) bool {
+	if len(hk.top) < hk.limit {
+		return true
+	}
+
+	coldest, reads := "", est
+	for key, k := range hk.top {
+		if r := k.reads.Load(); r < reads {
+			coldest, reads = key, r
+		}
+	}
+
+	if coldest == "" {
+		return false
+	}
+
+	delete(hk.top, coldest)
+	return true
+}
+
+// hot - returns tracked key, if it is hot
+func (hk *hotKeys) hot(key string) (*hotKey, bool) {
+	if hk.limit <= 0 {
+		return nil, false
+	}
+
+	hk.mu.RLock()
+	defer hk.mu.RUnlock()
+
+	k, ok := hk.top[key]
+	if !ok || k.reads.Load() < hk.minReads {
+		return nil, false
+	}
+	return k, true
+}
+
+// written - invalidates replicas of keys. MUST be called by owner after write is applied and before it is acknowledged.
+func (hk *hotKeys) written(keys ...string) {
+	if hk.limit <= 0 {
+		return
+	}
+
+	hk.mu.RLock()
+	defer hk.mu.RUnlock()
+
+	for _, key := range keys {
+		if k, ok := hk.top[key]; ok {
+			k.written.Store(hk.epoch.Add(1))
+		}
+	}
+}
+
+// decay - halves counters, keys, that are not hot anymore, leave top
+func (hk *hotKeys) decay() {
+	if hk.limit <= 0 {
+		return
+	}
+
+	hk.sketch.decay()
+
+	hk.mu.Lock()
+	defer hk.mu.Unlock()
+
+	for key, k := range hk.top {
+		reads := k.reads.Load() / 2
+		if reads < hk.minReads/2 {
+			delete(hk.top, key)
+			continue
+		}
+		k.reads.Store(reads)
+	}
+}
+
+// list - tracked keys, the hottest first
+func (hk *hotKeys) list() []HotKey {
+	hk.mu.RLock()
+	keys := make([]HotKey, 0, len(hk.top))
+	for key, k := range hk.top {
+		keys = append(keys, HotKey{Key: key, Reads: k.reads.Load()})
+	}
+	hk.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Reads != keys[j].Reads {
+			return keys[i].Reads > keys[j].Reads
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
@@ -2,10 +2,19 @@ package manager
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/cespare/xxhash/v2"
+	rendezvous "github.com/dgryski/go-rendezvous"
 	"github.com/sirupsen/logrus"
 )
 
@@ -14,191 +23,1403 @@ type TaskType int8
 const (
 	GetTask TaskType = iota
 	SetTask
+	MGetTask
+	MSetTask
+	MDeleteTask
+	TxTask
+	// MigrateTask - moves Keys from store From to store of worker, that owns them
+	MigrateTask
+	// BarrierTask - completes after every task, that was queued to worker before it
+	BarrierTask
+	// ReplicaGetTask - gets hot key from replica of worker, replica is filled from store From of owner
+	ReplicaGetTask
+	// SetNXTask - sets key, only if it is missing. Result.Found reports, that key was present and was not set.
+	SetNXTask
+	// IncrTask - increments counter in key, Result.Val is new value
+	IncrTask
+	// CASTask - sets key, only if it is present with one of Revs. Result.Rev is new revision of key.
+	CASTask
+	// CASDeleteTask - deletes key, only if it is present with one of Revs
+	CASDeleteTask
+	// FuncTask - runs Fn on worker goroutine, Fn must not block
+	FuncTask
 )
 
+var (
+	ErrCrossShard    = errors.New("keys of transaction belong to different shards, use hash tags {...} to keep them together")
+	ErrStopped       = errors.New("manager is stopped")
+	ErrOverloaded    = errors.New("queue of worker is full")
+	ErrCrashed       = errors.New("worker crashed while processing task")
+	ErrNotCounter    = errors.New("value of key is not a counter")
+	ErrValueTooLarge = errors.New("value exceeds max value size")
+	ErrRevMismatch   = errors.New("key is missing or its revision does not match")
+)
+
+// WriteConcern - when Set is acknowledged to caller
+type WriteConcern uint8
+
+const (
+	// WriteApplied - value is stored in memory of worker store
+	WriteApplied WriteConcern = iota
+	// WriteAsync - task is queued to worker, errors of write are only logged
+	WriteAsync
+	// WriteDurable - value is stored, written to dump file and synced to disk
+	WriteDurable
+)
+
+var (
+	ErrInvalidWriteConcern = errors.New("write concern must be async, applied or durable")
+	// ErrNotDurable - durable write to store, that is not saved to file, could not be synced
+	ErrNotDurable = fmt.Errorf("%w, durable needs stores saved to file", ErrInvalidWriteConcern)
+)
+
+func (wc WriteConcern) String() string {
+	switch wc {
+	case WriteApplied:
+		return "applied"
+	case WriteAsync:
+		return "async"
+	case WriteDurable:
+		return "durable"
+	}
+	return "unknown"
+}
+
+// ParseWriteConcern - parses name of write concern, empty name is WriteApplied
+func ParseWriteConcern(name string) (WriteConcern, error) {
+	switch name {
+	case "", "applied":
+		return WriteApplied, nil
+	case "async":
+		return WriteAsync, nil
+	case "durable":
+		return WriteDurable, nil
+	}
+	return WriteApplied, ErrInvalidWriteConcern
+}
+
+// TxOp - single write of transaction
+type TxOp struct {
+	Op  ttlstore.OpType
+	Key string
+	Val string
+}
+
+// Result - result of GetTask. Found is false, when key is missing or expired.
+type Result struct {
+	Val string
+	// Rev - revision of key, it changes with every write of key
+	Rev uint64
+	// Expires - time, when key expires, zero time when key never expires
+	Expires time.Time
+	Found   bool
+	Err     error
+}
+
 type Task struct {
+	// Ctx - ctx of caller, task is skipped by worker, when ctx is done before task is processed
+	Ctx      context.Context
 	Key      string
 	Val      string
-	RespChan chan string
+	RespChan chan Result
 	Type     TaskType
 
-	mapIndex int
+	// Batch tasks. Keys of batch task belong to one worker, Found and Vals are filled by worker for MGetTask,
+	// DoneChan receives result after task has been handled.
+	Keys     []string
+	Vals     []string
+	Found    []bool
+	Ops      []TxOp
+	DoneChan chan error
+
+	// Concern - write concern of SetTask, DoneChan receives result, when write satisfies it
+	Concern WriteConcern
+	// TTL - ttl of value of SetTask and SetNXTask, that is not refreshed by reads. Zero means manager.val-ttl,
+	// that is refreshed by reads, negative ttl means value never expires.
+	TTL time.Duration
+
+	// Revs - revisions of key, one of which is expected by CASTask and CASDeleteTask. Empty Revs match any revision.
+	Revs []uint64
+
+	From *ttlstore.MapStore[string, string]
+
+	Fn func()
 }
 
 type Worker struct {
-	index        int
-	valTTL       time.Duration
-	store        *ttlstore.MapStore[string, string]
-	logger       *logrus.Entry
-	notFoundChan chan *Task
-	next         *Worker
+	index  int
+	valTTL time.Duration
+	store  *ttlstore.MapStore[string, string]
+	logger *logrus.Entry
+	tasks  chan *Task
+	mig    *migration
+	// done - closed when worker stops listening
+	done chan struct{}
+
+	alive    atomic.Bool
+	restarts atomic.Uint64
+	// busySince - unix nano time, when processing of current task started, zero when worker is idle
+	busySince atomic.Int64
+
+	hot        *hotKeys
+	replicaTTL time.Duration
+	// replicas - copies of hot keys of other workers, used only by worker goroutine
+	replicas map[string]replica
+}
+
+// replica - copy of hot key. Copy is stale, when key was written after epoch, or copy has expired.
+type replica struct {
+	val   string
+	rev   uint64
+	found bool
+	// valExpires - time, when value expires in store of owner
+	valExpires time.Time
+	epoch      uint64
+	expires    time.Time
 }
 
 func newWorker(index int,
 	valTTL time.Duration,
 	store *ttlstore.MapStore[string, string],
 	logger *logrus.Entry,
-	notFoundChan chan *Task,
-	next *Worker) *Worker {
+	tasks chan *Task,
+	mig *migration,
+	hot *hotKeys,
+	replicaTTL time.Duration) *Worker {
 	return &Worker{
-		index:        index,
-		valTTL:       valTTL,
-		store:        store,
-		logger:       logger,
-		notFoundChan: notFoundChan,
-		next:         next,
+		index:      index,
+		valTTL:     valTTL,
+		store:      store,
+		logger:     logger,
+		tasks:      tasks,
+		mig:        mig,
+		done:       make(chan struct{}),
+		hot:        hot,
+		replicaTTL: replicaTTL,
+		replicas:   make(map[string]replica),
 	}
 }
 
-func (w *Worker) Listen(ctx context.Context, taskChan chan *Task, wg *sync.WaitGroup) {
-	defer wg.Done()
+// lookup - gets value from worker store. While keys are migrating, key can still be in other store,
+// then it is moved to worker store first. Until tasks, queued by old layout, are done, they can still write key
+// to other store, so key is only read from there.
+func (w *Worker) lookup(ctx context.Context, key string) (string, uint64, bool) {
+	if val, rev, ok := w.store.GetRev(ctx, key); ok || !w.mig.active.Load() {
+		return val, rev, ok
+	}
 
-	respond := func(t *Task) {
-		val, ok := w.store.Get(ctx, t.Key)
-		if !ok {
-			// w.logger.Infof("Not Found. Passing to Next Worker. Key: %s", t.Key)
-			if t.mapIndex == -1 {
-				t.mapIndex = w.index
+	if !w.mig.settled.Load() {
+		for _, s := range w.mig.others(w.store) {
+			if val, rev, ok := s.GetRev(ctx, key); ok {
+				return val, rev, ok
 			}
-			w.next.notFoundChan <- t
-			return
 		}
+		return "", 0, false
+	}
+
+	for _, s := range w.mig.others(w.store) {
+		if _, ok := s.Get(ctx, key); ok {
+			if err := s.MoveTo(ctx, w.store, []string{key}); err != nil {
+				w.logger.Errorf("got error while moving key: %s", err.Error())
+			}
+			break
+		}
+	}
+
+	return w.store.GetRev(ctx, key)
+}
+
+// purge - while keys are migrating, deletes keys from other stores, so migration does not bring them back
+func (w *Worker) purge(ctx context.Context, keys []string) error {
+	if !w.mig.active.Load() {
+		return nil
+	}
+
+	for _, s := range w.mig.others(w.store) {
+		if err := s.Drop(ctx, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Worker) get(ctx context.Context, t *Task) {
+	val, rev, ok := w.lookup(ctx, t.Key)
+	if !ok {
+		t.RespChan <- Result{}
+		return
+	}
+
+	//Refresh TTL
+	if _, err := w.store.Touch(ctx, t.Key, w.valTTL); err != nil {
+		w.logger.Errorf("got error while refreshing value: %s", err.Error())
+	}
+
+	expires, _ := w.store.Expires(ctx, t.Key)
+	t.RespChan <- Result{Val: val, Rev: rev, Expires: expires, Found: true}
+}
+
+// replicaGet - gets hot key of other worker from replica. Stale replica is filled again from store of owner,
+// owner store is refreshed at most once per replica ttl.
+func (w *Worker) replicaGet(ctx context.Context, t *Task) {
+	if w.mig.active.Load() {
+		// Reshard started after task was queued, key can be in any store. Replica is not filled,
+		// and key is not moved, only owner moves keys.
+		delete(w.replicas, t.Key)
+
+		from := t.From
+		val, rev, found := from.GetRev(ctx, t.Key)
+		for _, s := range w.mig.others(t.From) {
+			if found {
+				break
+			}
+			from = s
+			val, rev, found = s.GetRev(ctx, t.Key)
+		}
+
+		expires, _ := from.Expires(ctx, t.Key)
+		t.RespChan <- Result{Val: val, Rev: rev, Expires: expires, Found: found}
+		return
+	}
 
-		t.RespChan <- val
+	k, ok := w.hot.hot(t.Key)
+	if r, cached := w.replicas[t.Key]; ok && cached && r.epoch >= k.written.Load() && time.Now().Before(r.expires) {
+		t.RespChan <- Result{Val: r.val, Rev: r.rev, Expires: r.valExpires, Found: r.found}
+		return
+	}
 
+	// Epoch is read before value, so write, that is applied after read, always makes replica stale
+	r := replica{
+		epoch:   w.hot.epoch.Load(),
+		expires: time.Now().Add(w.replicaTTL),
+	}
+	r.val, r.rev, r.found = t.From.GetRev(ctx, t.Key)
+	if r.found {
 		//Refresh TTL
-		if err := w.store.Set(ctx, t.Key, val, w.valTTL); err != nil {
+		if _, err := t.From.Touch(ctx, t.Key, w.valTTL); err != nil {
 			w.logger.Errorf("got error while refreshing value: %s", err.Error())
 		}
+		r.valExpires, _ = t.From.Expires(ctx, t.Key)
 	}
+	t.RespChan <- Result{Val: r.val, Rev: r.rev, Expires: r.valExpires, Found: r.found}
 
-	for {
+	if !ok {
+		delete(w.replicas, t.Key)
+		return
+	}
+
+	if len(w.replicas) >= 2*w.hot.limit {
+		w.prune()
+	}
+	w.replicas[t.Key] = r
+}
+
+// prune - drops replicas of keys, that are not hot anymore
+func (w *Worker) prune() {
+	for key := range w.replicas {
+		if _, ok := w.hot.hot(key); !ok {
+			delete(w.replicas, key)
+		}
+	}
+}
+
+func (w *Worker) set(ctx context.Context, t *Task) error {
+	if t.Concern == WriteDurable && !w.store.Persistent() {
+		return ErrNotDurable
+	}
+
+	set := w.store.Set
+	ttl := w.valTTL
+	if t.TTL != 0 {
+		set, ttl = w.store.SetFixed, t.TTL
+	}
+
+	if err := set(ctx, t.Key, t.Val, ttl); err != nil {
+		return err
+	}
+	w.hot.written(t.Key)
+	return nil
+}
+
+// synced - calls reply, after write of task is synced to disk, if task is durable. Sync waits for save daemon
+// in its own goroutine, so worker goes on with other tasks of shard meanwhile.
+func (w *Worker) synced(t *Task, reply func(err error)) {
+	if t.Concern != WriteDurable {
+		reply(nil)
+		return
+	}
+
+	store := w.store
+	go func() {
+		reply(store.Sync(t.Ctx))
+	}()
+}
+
+// setnx - check and set are atomic, because only owner writes key
+func (w *Worker) setnx(ctx context.Context, t *Task) {
+	if _, _, ok := w.lookup(ctx, t.Key); ok {
+		t.RespChan <- Result{Found: true}
+		return
+	}
+
+	if err := w.set(ctx, t); err != nil {
+		t.RespChan <- Result{Err: err}
+		return
+	}
+
+	w.synced(t, func(err error) {
+		t.RespChan <- Result{Err: err}
+	})
+}
+
+// incr - counter is stored without ttl, so it is not lost, when it is not incremented for long
+func (w *Worker) incr(ctx context.Context, t *Task) {
+	n := uint64(0)
+	if val, _, ok := w.lookup(ctx, t.Key); ok {
+		var err error
+		if n, err = strconv.ParseUint(val, 10, 64); err != nil {
+			t.RespChan <- Result{Err: ErrNotCounter}
+			return
+		}
+	}
+
+	val := strconv.FormatUint(n+1, 10)
+	if err := w.store.Set(ctx, t.Key, val, -1); err != nil {
+		t.RespChan <- Result{Err: err}
+		return
+	}
+	w.hot.written(t.Key)
+
+	t.RespChan <- Result{Val: val, Found: true}
+}
+
+// matchRev - rev is one of revs, empty revs match any revision
+func matchRev(rev uint64, revs []uint64) bool {
+	if len(revs) == 0 {
+		return true
+	}
+
+	for _, r := range revs {
+		if r == rev {
+			return true
+		}
+	}
+	return false
+}
+
+// cas - compare and write are atomic, because only owner writes key
+func (w *Worker) cas(ctx context.Context, t *Task) {
+	_, rev, ok := w.lookup(ctx, t.Key)
+	if !ok || !matchRev(rev, t.Revs) {
+		t.RespChan <- Result{Rev: rev, Found: ok, Err: ErrRevMismatch}
+		return
+	}
+
+	if t.Type == CASDeleteTask {
+		t.RespChan <- Result{Err: w.mdelete(ctx, []string{t.Key})}
+		return
+	}
+
+	if err := w.set(ctx, t); err != nil {
+		t.RespChan <- Result{Err: err}
+		return
+	}
+
+	_, rev, ok = w.store.GetRev(ctx, t.Key)
+	w.synced(t, func(err error) {
+		t.RespChan <- Result{Rev: rev, Found: ok, Err: err}
+	})
+}
+
+func (w *Worker) mget(ctx context.Context, t *Task) {
+	for i, k := range t.Keys {
+		if val, _, ok := w.lookup(ctx, k); ok {
+			t.Vals[i], t.Found[i] = val, true
+
+			//Refresh TTL
+			if _, err := w.store.Touch(ctx, k, w.valTTL); err != nil {
+				w.logger.Errorf("got error while refreshing value: %s", err.Error())
+			}
+		}
+	}
+
+	t.DoneChan <- nil
+}
+
+func (w *Worker) mset(ctx context.Context, keys []string, vals []string) error {
+	if err := w.store.MSet(ctx, keys, vals, w.valTTL); err != nil {
+		return err
+	}
+
+	w.hot.written(keys...)
+	return nil
+}
+
+func (w *Worker) exec(ctx context.Context, ops []TxOp) error {
+	deleted := make([]string, 0)
+	written := make([]string, 0, len(ops))
+
+	tx := w.store.Multi()
+	for _, op := range ops {
+		written = append(written, op.Key)
+
+		switch op.Op {
+		case ttlstore.OpDelete:
+			tx.Delete(op.Key)
+			deleted = append(deleted, op.Key)
+		default:
+			tx.Set(op.Key, op.Val, w.valTTL)
+		}
+	}
+
+	if err := tx.Exec(ctx); err != nil {
+		return err
+	}
+	w.hot.written(written...)
+
+	return w.purge(ctx, deleted)
+}
+
+func (w *Worker) mdelete(ctx context.Context, keys []string) error {
+	if err := w.store.MDelete(ctx, keys); err != nil {
+		return err
+	}
+	w.hot.written(keys...)
+
+	return w.purge(ctx, keys)
+}
+
+// skip - replies to task, that is not processed, with err. Never blocks, if worker has already replied before crash.
+func (w *Worker) skip(t *Task, err error) {
+	if t.RespChan != nil {
 		select {
-		case <-ctx.Done():
+		case t.RespChan <- Result{Err: err}:
+		default:
+		}
+	}
+
+	if t.DoneChan != nil {
+		select {
+		case t.DoneChan <- err:
+		default:
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, t *Task) {
+	// Caller has already gone, or operation timed out, while task was queued
+	if t.Ctx != nil && t.Ctx.Err() != nil {
+		w.skip(t, t.Ctx.Err())
+		return
+	}
+
+	switch t.Type {
+	case GetTask:
+		w.get(ctx, t)
+	case SetTask:
+		w.logger.Info("Setting.")
+
+		if err := w.set(ctx, t); err != nil {
+			w.logger.Errorf("got error while setting key-value: %s", err.Error())
+			t.DoneChan <- err
 			return
-		case t := <-taskChan:
-			switch t.Type {
-			case GetTask:
-				// w.logger.Infof("Getting. Key: %s", t.Key)
-				respond(t)
-			case SetTask:
-				w.logger.Info("Setting.")
-
-				if err := w.store.Set(ctx, t.Key, t.Val, w.valTTL); err != nil {
-					w.logger.Errorf("got error while setting key-value: %s", err.Error())
-				}
+		}
+
+		w.synced(t, func(err error) {
+			t.DoneChan <- err
+		})
+	case MGetTask:
+		w.mget(ctx, t)
+	case MSetTask:
+		t.DoneChan <- w.mset(ctx, t.Keys, t.Vals)
+	case MDeleteTask:
+		t.DoneChan <- w.mdelete(ctx, t.Keys)
+	case TxTask:
+		t.DoneChan <- w.exec(ctx, t.Ops)
+	case MigrateTask:
+		t.DoneChan <- t.From.MoveTo(ctx, w.store, t.Keys)
+	case BarrierTask:
+		t.DoneChan <- nil
+	case ReplicaGetTask:
+		w.replicaGet(ctx, t)
+	case SetNXTask:
+		w.setnx(ctx, t)
+	case IncrTask:
+		w.incr(ctx, t)
+	case CASTask, CASDeleteTask:
+		w.cas(ctx, t)
+	case FuncTask:
+		t.Fn()
+	}
+}
+
+// Listen - processes tasks until ctx is done, or tasks channel is closed and drained.
+// Worker is supervised: after panic it is restarted with the same store and queue,
+// and task, that caused panic, fails with ErrCrashed.
+func (w *Worker) Listen(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(w.done)
+
+	w.alive.Store(true)
+	defer w.alive.Store(false)
+
+	for !w.serve(ctx) {
+		w.restarts.Add(1)
+		w.logger.Warnf("Worker%d. Restarted after panic.", w.index)
+	}
+}
+
+// serve - processes tasks, returns false, when worker panicked
+func (w *Worker) serve(ctx context.Context) (stopped bool) {
+	var cur *Task
+
+	defer func() {
+		if r := recover(); r != nil {
+			w.logger.Errorf("Worker%d. Panic: %v\n%s", w.index, r, debug.Stack())
+			w.busySince.Store(0)
+
+			if cur != nil {
+				w.skip(cur, ErrCrashed)
 			}
-		case t := <-w.notFoundChan:
-			if t.mapIndex == w.index {
-				t.RespChan <- ""
-			} else {
-				respond(t)
+			stopped = false
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case t, ok := <-w.tasks:
+			if !ok {
+				return true
 			}
+
+			cur = t
+			w.busySince.Store(time.Now().UnixNano())
+			w.process(ctx, t)
+			w.busySince.Store(0)
+			cur = nil
 		}
 	}
 }
 
-type WorkerRing struct {
-	len int
-	cur *Worker
+// WorkerHealth - liveness and load of worker
+type WorkerHealth struct {
+	Index    int
+	Alive    bool
+	Restarts uint64
+	// Healthy - worker is alive and current task takes less than cfg.StuckAfter
+	Healthy    bool
+	QueueDepth int
+	QueueSize  int
+	// Busy - how long worker is processing current task, zero when worker is idle
+	Busy time.Duration
 }
 
-func (wr *WorkerRing) Push(w *Worker) {
-	if wr.cur == nil {
-		wr.cur = w
-	} else if wr.len >= 1 {
-		w.next = wr.cur.next
+func (w *Worker) health(stuckAfter time.Duration) WorkerHealth {
+	h := WorkerHealth{
+		Index:      w.index,
+		Alive:      w.alive.Load(),
+		Restarts:   w.restarts.Load(),
+		QueueDepth: len(w.tasks),
+		QueueSize:  cap(w.tasks),
+	}
+
+	if since := w.busySince.Load(); since > 0 {
+		h.Busy = time.Since(time.Unix(0, since))
 	}
 
-	// 0 -> 1
-	//   <-
-	wr.cur.next = w
-	wr.cur = w
-	wr.len++
+	h.Healthy = h.Alive && (stuckAfter <= 0 || h.Busy < stuckAfter)
+	return h
 }
 
-func (wr *WorkerRing) Range(f func(w *Worker)) {
-	temp := wr.cur
-	for {
-		f(temp)
-		temp = temp.next
-		if temp.index == wr.cur.index {
-			break
+// hashTag - part of key, that is hashed to choose shard. If key contains non empty {...},
+// only its content is hashed, so keys with the same tag always belong to one shard.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
 		}
 	}
+	return key
 }
 
+// WorkerManager - routes operations to workers, that own keys. Workers never send tasks to each other,
+// every task is queued by caller, so full queues can slow callers down, but can not deadlock workers.
 type WorkerManager struct {
 	logger *logrus.Logger
+	cfg    ManagerConfig
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	waitG  *sync.WaitGroup
 
-	ReqChan     chan *Task
-	WorkerArena *WorkerRing
+	// mu - guards layout of shards. Tasks are queued under read lock, so no task reaches worker after it is retired.
+	mu      *sync.RWMutex
+	workers []*Worker
+	// shards - rendezvous hash of worker indexes. Index of store does not change between restarts,
+	// so key is always served by worker, which store has saved it.
+	shards *rendezvous.Rendezvous
+
+	mig      *migration
+	provider StoreProvider
+
+	// rejected - number of operations, rejected because queue of worker was full
+	rejected atomic.Uint64
+
+	hot *hotKeys
+	// next - round robin counter of workers, that serve reads of hot keys
+	next atomic.Uint64
 }
 
-// NewWorkerManager - creates new worker manager, length of stroes MUST be == to cfg.Manager.WorkerNum
+// NewWorkerManager - creates new worker manager, length of stroes MUST be >= to cfg.Manager.WorkerNum.
+// Stores after first cfg.WorkerNum are retired: their keys are moved to workers, then stores are closed and removed.
 func NewWorkerManager(ctx context.Context, stores []*ttlstore.MapStore[string, string], logger *logrus.Logger, cfg ManagerConfig) *WorkerManager {
-	//TODO CHAN SIZE???
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DEFAULT_RETRY_AFTER
+	}
+
+	if cfg.StuckAfter == 0 {
+		cfg.StuckAfter = DEFAULT_STUCK_AFTER
+	}
+
+	if cfg.HotKeys == 0 {
+		cfg.HotKeys = DEFAULT_HOT_KEYS
+	}
+
+	if cfg.HotReads == 0 {
+		cfg.HotReads = DEFAULT_HOT_READS
+	}
+
+	if cfg.HotDecay <= 0 {
+		cfg.HotDecay = DEFAULT_HOT_DECAY
+	}
+
+	if cfg.ReplicaTTL <= 0 {
+		cfg.ReplicaTTL = DEFAULT_REPLICA_TTL
+	}
+
+	if cfg.MaxValueSize == 0 {
+		cfg.MaxValueSize = DEFAULT_MAX_VALUE_SIZE
+	}
+
 	wm := &WorkerManager{
-		ReqChan:     make(chan *Task, 100),
-		WorkerArena: &WorkerRing{},
-		waitG:       &sync.WaitGroup{},
-		logger:      logger,
+		logger:  logger,
+		cfg:     cfg,
+		waitG:   &sync.WaitGroup{},
+		mu:      &sync.RWMutex{},
+		workers: make([]*Worker, cfg.WorkerNum),
+		mig:     newMigration(stores, stores[cfg.WorkerNum:]),
+		hot:     newHotKeys(cfg.HotKeys, cfg.HotReads),
 	}
 
-	// Build a cercualr list of workers
 	for widx := 0; widx < int(cfg.WorkerNum); widx++ {
-		wm.WorkerArena.Push(newWorker(
-			widx,
-			cfg.ValTTL,
-			stores[widx],
-			logger.WithField("worker", widx),
-			make(chan *Task, 10), nil),
-		)
+		wm.workers[widx] = wm.newWorker(widx, stores[widx])
 	}
 
+	wm.shards = newShards(len(wm.workers))
+
 	wm.ctx, wm.cancel = context.WithCancel(ctx)
 
 	return wm
 }
 
-func (wm *WorkerManager) Get(key string) string {
-	respChan := make(chan string, 1)
+func (wm *WorkerManager) newWorker(index int, store *ttlstore.MapStore[string, string]) *Worker {
+	return newWorker(
+		index,
+		wm.cfg.ValTTL,
+		store,
+		wm.logger.WithField("worker", index),
+		make(chan *Task, wm.cfg.QueueSize),
+		wm.mig,
+		wm.hot,
+		wm.cfg.ReplicaTTL,
+	)
+}
+
+func newShards(n int) *rendezvous.Rendezvous {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = strconv.Itoa(i)
+	}
+	return rendezvous.New(nodes, xxhash.Sum64String)
+}
+
+// owner - worker, that stores key. MUST be called under wm.mu
+func (wm *WorkerManager) owner(key string) *Worker {
+	idx, _ := strconv.Atoi(wm.shards.Lookup(hashTag(key)))
+	return wm.workers[idx]
+}
+
+// split - groups indexes of keys by worker, that owns them. MUST be called under wm.mu
+func (wm *WorkerManager) split(keys []string) map[*Worker][]int {
+	groups := make(map[*Worker][]int)
+	for i, k := range keys {
+		w := wm.owner(k)
+		groups[w] = append(groups[w], i)
+	}
+	return groups
+}
+
+// withTimeout - limits operation by cfg.OpTimeout, if it is set
+func (wm *WorkerManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if wm.cfg.OpTimeout > 0 {
+		return context.WithTimeout(ctx, wm.cfg.OpTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// enqueue - queues task to worker, waits while queue is full. Task gets ctx of caller, if it has no ctx yet. Returns error of ctx, when ctx is done before task is queued,
+// or ErrStopped, when manager is stopped. MUST be called under wm.mu
+func (wm *WorkerManager) enqueue(ctx context.Context, w *Worker, t *Task) error {
+	if t.Ctx == nil {
+		t.Ctx = ctx
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if wm.ctx.Err() != nil {
+		return ErrStopped
+	}
+
+	select {
+	case w.tasks <- t:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wm.ctx.Done():
+		return ErrStopped
+	}
+}
+
+// dispatch - queues task of caller to worker. With AdmitReject policy, ErrOverloaded is returned at once, when queue is full,
+// otherwise caller waits like in enqueue. MUST be called under wm.mu
+func (wm *WorkerManager) dispatch(ctx context.Context, w *Worker, t *Task) error {
+	if wm.cfg.Admission != AdmitReject {
+		return wm.enqueue(ctx, w, t)
+	}
+
+	if t.Ctx == nil {
+		t.Ctx = ctx
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if wm.ctx.Err() != nil {
+		return ErrStopped
+	}
+
+	select {
+	case w.tasks <- t:
+		return nil
+	default:
+		wm.rejected.Add(1)
+		return ErrOverloaded
+	}
+}
+
+// Rejected - number of operations, rejected because queue of worker was full
+func (wm *WorkerManager) Rejected() uint64 {
+	return wm.rejected.Load()
+}
+
+// RetryAfter - delay, that clients should wait before retry, when operation was rejected
+func (wm *WorkerManager) RetryAfter() time.Duration {
+	return wm.cfg.RetryAfter
+}
+
+// ValTTL - ttl of values, it is refreshed, when value is read
+func (wm *WorkerManager) ValTTL() time.Duration {
+	return wm.cfg.ValTTL
+}
+
+// MaxValueSize - max size of value in bytes, negative if size is not limited
+func (wm *WorkerManager) MaxValueSize() int64 {
+	return wm.cfg.MaxValueSize
+}
+
+// checkSize - returns ErrValueTooLarge, if any of vals exceeds max value size
+func (wm *WorkerManager) checkSize(vals ...string) error {
+	if wm.cfg.MaxValueSize < 0 {
+		return nil
+	}
+
+	for _, val := range vals {
+		if int64(len(val)) > wm.cfg.MaxValueSize {
+			return ErrValueTooLarge
+		}
+	}
+	return nil
+}
+
+// send - queues task to worker, that owns key
+func (wm *WorkerManager) send(ctx context.Context, key string, t *Task) error {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.dispatch(ctx, wm.owner(key), t)
+}
+
+// sendReplica - queues read of hot key to next worker by round robin. Owner serves it as usual GetTask,
+// other workers serve it from their replicas.
+func (wm *WorkerManager) sendReplica(ctx context.Context, t *Task) error {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	owner := wm.owner(t.Key)
+	w := wm.workers[wm.next.Add(1)%uint64(len(wm.workers))]
+	if w == owner || wm.mig.active.Load() {
+		return wm.dispatch(ctx, owner, t)
+	}
+
+	t.Type = ReplicaGetTask
+	t.From = owner.store
+	return wm.dispatch(ctx, w, t)
+}
+
+// wait - waits for every task, returns first error. Tasks, that are still queued, when ctx is done, are skipped by workers.
+func (wm *WorkerManager) wait(ctx context.Context, tasks []*Task) error {
+	var err error
+	for _, t := range tasks {
+		select {
+		case tErr := <-t.DoneChan:
+			if tErr != nil && err == nil {
+				err = tErr
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wm.ctx.Done():
+			return ErrStopped
+		}
+	}
+	return err
+}
+
+// Go - runs f on worker, that owns key, after every task queued to this worker before. So functions of one key run
+// in order they were queued, while workers are not resharded. Waits while queue of worker is full, f runs even if ctx is done
+// after it has been queued.
+func (wm *WorkerManager) Go(ctx context.Context, key string, f func()) error {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.enqueue(ctx, wm.owner(key), &Task{
+		Ctx:  wm.ctx,
+		Type: FuncTask,
+		Fn:   f,
+	})
+}
+
+// Get - gets value of key, found is false when key is missing. Returns ErrStopped, when manager is stopped,
+// or error of ctx, when ctx is done or operation timed out.
+func (wm *WorkerManager) Get(ctx context.Context, key string) (string, bool, error) {
+	val, _, found, err := wm.GetRev(ctx, key)
+	return val, found, err
+}
+
+// GetRev - same as Get, also returns revision of key. Revision changes with every write of key, it is kept, when ttl is refreshed.
+func (wm *WorkerManager) GetRev(ctx context.Context, key string) (string, uint64, bool, error) {
+	val, rev, _, found, err := wm.GetWithTTL(ctx, key)
+	return val, rev, found, err
+}
+
+// GetWithTTL - same as GetRev, also returns time, when key expires after this read, zero time when key never expires.
+func (wm *WorkerManager) GetWithTTL(ctx context.Context, key string) (string, uint64, time.Time, bool, error) {
+	ctx, cancel := wm.withTimeout(ctx)
+	defer cancel()
+
+	respChan := make(chan Result, 1)
 
 	t := &Task{
-		mapIndex: -1,
 		Key:      key,
 		RespChan: respChan,
 		Type:     GetTask,
 	}
-	wm.ReqChan <- t
 
-	return <-respChan
+	var err error
+	if wm.hot.read(key) && !wm.mig.active.Load() {
+		err = wm.sendReplica(ctx, t)
+	} else {
+		err = wm.send(ctx, key, t)
+	}
+
+	if err != nil {
+		return "", 0, time.Time{}, false, err
+	}
+
+	select {
+	case res := <-respChan:
+		return res.Val, res.Rev, res.Expires, res.Found, res.Err
+	case <-ctx.Done():
+		return "", 0, time.Time{}, false, ctx.Err()
+	case <-wm.ctx.Done():
+		return "", 0, time.Time{}, false, ErrStopped
+	}
 }
 
-func (wm *WorkerManager) Set(key string, val string) {
+// Set - sets value of key. Returns, when write satisfies write concern: after task is queued (WriteAsync),
+// value is stored (WriteApplied), or value is synced to dump file (WriteDurable).
+func (wm *WorkerManager) Set(ctx context.Context, key string, val string, wc WriteConcern) error {
+	return wm.SetWithTTL(ctx, key, val, 0, wc)
+}
+
+// SetWithTTL - same as Set, value expires after ttl, reads do not refresh it. Negative ttl means value never expires,
+// zero ttl means manager.val-ttl, that is refreshed by reads.
+func (wm *WorkerManager) SetWithTTL(ctx context.Context, key string, val string, ttl time.Duration, wc WriteConcern) error {
+	if err := wm.checkSize(val); err != nil {
+		return err
+	}
+
+	ctx, cancel := wm.withTimeout(ctx)
+	defer cancel()
+
 	t := &Task{
-		mapIndex: -1,
 		Key:      key,
 		Val:      val,
+		DoneChan: make(chan error, 1),
 		Type:     SetTask,
+		Concern:  wc,
+		TTL:      ttl,
+	}
+
+	if wc == WriteAsync {
+		// Caller does not wait, so task MUST NOT be skipped, when ctx of caller is done
+		t.Ctx = wm.ctx
+	}
+
+	if err := wm.send(ctx, key, t); err != nil {
+		return err
+	}
+
+	if wc == WriteAsync {
+		return nil
+	}
+
+	return wm.wait(ctx, []*Task{t})
+}
+
+// SetNX - sets value of key, only if key is missing. Returns false, when key is already present.
+// Caller must know result, so WriteAsync is not allowed.
+func (wm *WorkerManager) SetNX(ctx context.Context, key string, val string, wc WriteConcern) (bool, error) {
+	return wm.SetNXWithTTL(ctx, key, val, 0, wc)
+}
+
+// SetNXWithTTL - same as SetNX, ttl is the same as ttl of SetWithTTL
+func (wm *WorkerManager) SetNXWithTTL(ctx context.Context, key string, val string, ttl time.Duration, wc WriteConcern) (bool, error) {
+	if wc == WriteAsync {
+		return false, ErrInvalidWriteConcern
+	}
+
+	if err := wm.checkSize(val); err != nil {
+		return false, err
+	}
+
+	t := &Task{
+		Key:     key,
+		Val:     val,
+		Type:    SetNXTask,
+		Concern: wc,
+		TTL:     ttl,
+	}
+
+	res, err := wm.request(ctx, key, t)
+	if err != nil {
+		return false, err
+	}
+
+	if res.Err != nil {
+		return false, res.Err
+	}
+	return !res.Found, nil
+}
+
+// Incr - increments counter in key and returns new value. Missing counter starts from zero,
+// counter does not expire. Returns ErrNotCounter, when value of key is not unsigned integer.
+func (wm *WorkerManager) Incr(ctx context.Context, key string) (uint64, error) {
+	t := &Task{
+		Key:  key,
+		Type: IncrTask,
+	}
+
+	res, err := wm.request(ctx, key, t)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.Err != nil {
+		return 0, res.Err
+	}
+	return strconv.ParseUint(res.Val, 10, 64)
+}
+
+// CompareAndSwap - sets value of key, only if key is present with one of revs, empty revs match any revision.
+// Returns new revision of key, or ErrRevMismatch, when key is missing or has other revision.
+// Caller must know result, so WriteAsync is not allowed.
+func (wm *WorkerManager) CompareAndSwap(ctx context.Context, key string, val string, wc WriteConcern, revs ...uint64) (uint64, error) {
+	if wc == WriteAsync {
+		return 0, ErrInvalidWriteConcern
+	}
+
+	if err := wm.checkSize(val); err != nil {
+		return 0, err
+	}
+
+	t := &Task{
+		Key:     key,
+		Val:     val,
+		Type:    CASTask,
+		Concern: wc,
+		Revs:    revs,
+	}
+
+	res, err := wm.request(ctx, key, t)
+	if err != nil {
+		return 0, err
+	}
+	return res.Rev, res.Err
+}
+
+// CompareAndDelete - deletes key, only if key is present with one of revs, empty revs match any revision.
+// Returns ErrRevMismatch, when key is missing or has other revision.
+func (wm *WorkerManager) CompareAndDelete(ctx context.Context, key string, revs ...uint64) error {
+	t := &Task{
+		Key:  key,
+		Type: CASDeleteTask,
+		Revs: revs,
+	}
+
+	res, err := wm.request(ctx, key, t)
+	if err != nil {
+		return err
+	}
+	return res.Err
+}
+
+// request - sends task to owner of key and waits for its result
+func (wm *WorkerManager) request(ctx context.Context, key string, t *Task) (Result, error) {
+	ctx, cancel := wm.withTimeout(ctx)
+	defer cancel()
+
+	t.RespChan = make(chan Result, 1)
+
+	if err := wm.send(ctx, key, t); err != nil {
+		return Result{}, err
+	}
+
+	select {
+	case res := <-t.RespChan:
+		return res, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	case <-wm.ctx.Done():
+		return Result{}, ErrStopped
+	}
+}
+
+// MGet - gets values of every key, found[i] reports whether keys[i] is present.
+// Batch is split into one task per shard, shards are read concurrently.
+func (wm *WorkerManager) MGet(ctx context.Context, keys []string) (vals []string, found []bool, err error) {
+	ctx, cancel := wm.withTimeout(ctx)
+	defer cancel()
+
+	vals = make([]string, len(keys))
+	found = make([]bool, len(keys))
+
+	wm.mu.RLock()
+	groups := wm.split(keys)
+	tasks := make([]*Task, 0, len(groups))
+	idxs := make(map[*Task][]int, len(groups))
+	for w, wIdxs := range groups {
+		t := &Task{
+			Keys:     make([]string, len(wIdxs)),
+			Vals:     make([]string, len(wIdxs)),
+			Found:    make([]bool, len(wIdxs)),
+			DoneChan: make(chan error, 1),
+			Type:     MGetTask,
+		}
+
+		for i, idx := range wIdxs {
+			t.Keys[i] = keys[idx]
+		}
+
+		if err := wm.dispatch(ctx, w, t); err != nil {
+			wm.mu.RUnlock()
+			return nil, nil, err
+		}
+
+		tasks = append(tasks, t)
+		idxs[t] = wIdxs
+	}
+	wm.mu.RUnlock()
+
+	if err := wm.wait(ctx, tasks); err != nil {
+		return nil, nil, err
+	}
+
+	for t, wIdxs := range idxs {
+		for i, idx := range wIdxs {
+			vals[idx], found[idx] = t.Vals[i], t.Found[i]
+		}
+	}
+
+	return vals, found, nil
+}
+
+// MSet - sets every key to value with the same index. Keys of one shard are set in one transaction,
+// keys of different shards are not atomic.
+func (wm *WorkerManager) MSet(ctx context.Context, keys []string, vals []string) error {
+	if len(keys) != len(vals) {
+		return ttlstore.ErrBatchMismatch
+	}
+
+	if err := wm.checkSize(vals...); err != nil {
+		return err
+	}
+
+	ctx, cancel := wm.withTimeout(ctx)
+	defer cancel()
+
+	wm.mu.RLock()
+	groups := wm.split(keys)
+	tasks := make([]*Task, 0, len(groups))
+	for w, idxs := range groups {
+		t := &Task{
+			Keys:     make([]string, len(idxs)),
+			Vals:     make([]string, len(idxs)),
+			DoneChan: make(chan error, 1),
+			Type:     MSetTask,
+		}
+
+		for i, idx := range idxs {
+			t.Keys[i], t.Vals[i] = keys[idx], vals[idx]
+		}
+
+		if err := wm.dispatch(ctx, w, t); err != nil {
+			wm.mu.RUnlock()
+			return err
+		}
+		tasks = append(tasks, t)
+	}
+	wm.mu.RUnlock()
+
+	return wm.wait(ctx, tasks)
+}
+
+// MDelete - deletes every key. Keys of one shard are deleted in one transaction.
+func (wm *WorkerManager) MDelete(ctx context.Context, keys []string) error {
+	ctx, cancel := wm.withTimeout(ctx)
+	defer cancel()
+
+	wm.mu.RLock()
+	groups := wm.split(keys)
+	tasks := make([]*Task, 0, len(groups))
+	for w, idxs := range groups {
+		t := &Task{
+			Keys:     make([]string, len(idxs)),
+			DoneChan: make(chan error, 1),
+			Type:     MDeleteTask,
+		}
+
+		for i, idx := range idxs {
+			t.Keys[i] = keys[idx]
+		}
+
+		if err := wm.dispatch(ctx, w, t); err != nil {
+			wm.mu.RUnlock()
+			return err
+		}
+		tasks = append(tasks, t)
 	}
-	wm.ReqChan <- t
+	wm.mu.RUnlock()
+
+	return wm.wait(ctx, tasks)
+}
+
+// Tx - group of writes, applied all-or-nothing by Exec
+type Tx struct {
+	wm  *WorkerManager
+	ops []TxOp
 }
 
+// Multi - starts new transaction, writes are not visible until Exec is called
+func (wm *WorkerManager) Multi() *Tx {
+	return &Tx{
+		wm: wm,
+	}
+}
+
+func (tx *Tx) Set(key string, val string) *Tx {
+	tx.ops = append(tx.ops, TxOp{Op: ttlstore.OpSet, Key: key, Val: val})
+	return tx
+}
+
+func (tx *Tx) Delete(key string) *Tx {
+	tx.ops = append(tx.ops, TxOp{Op: ttlstore.OpDelete, Key: key})
+	return tx
+}
+
+// Exec - applies every queued write in one worker store, and saves it to file as one record.
+// Every key of transaction MUST belong to one shard, otherwise ErrCrossShard is returned.
+func (tx *Tx) Exec(ctx context.Context) error {
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	for _, op := range tx.ops {
+		if err := tx.wm.checkSize(op.Val); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := tx.wm.withTimeout(ctx)
+	defer cancel()
+
+	t := &Task{
+		Ops:      tx.ops,
+		DoneChan: make(chan error, 1),
+		Type:     TxTask,
+	}
+
+	tx.wm.mu.RLock()
+	w := tx.wm.owner(tx.ops[0].Key)
+	for _, op := range tx.ops[1:] {
+		if tx.wm.owner(op.Key) != w {
+			tx.wm.mu.RUnlock()
+			return ErrCrossShard
+		}
+	}
+
+	err := tx.wm.dispatch(ctx, w, t)
+	tx.wm.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	return tx.wm.wait(ctx, []*Task{t})
+}
+
+// Watch - subscribes to changes of keys in every store, stores of workers, added by Reshard later, are watched too.
+// Subscription MUST be closed by caller.
+func (wm *WorkerManager) Watch(cfg ttlstore.WatchConfig[string]) *ttlstore.Subscription[string, string] {
+	sub := ttlstore.NewSubscription[string, string](cfg)
+	wm.mig.watch(sub)
+	return sub
+}
+
+// Scan - returns up to limit keys with provided prefix in ascending order, starting after cursor.
+// Keys are merged from every store. Returned cursor is empty when there is no more keys.
+func (wm *WorkerManager) Scan(prefix string, cursor string, limit int) ([]string, string) {
+	if limit <= 0 {
+		return nil, ""
+	}
+
+	more := false
+	// While keys are migrating, the same key can be present in two stores
+	seen := make(map[string]struct{})
+	keys := make([]string, 0, limit)
+
+	for _, s := range wm.mig.all() {
+		sKeys, next := s.Scan(prefix, cursor, limit)
+		if next != "" {
+			more = true
+		}
+
+		for _, k := range sKeys {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+
+	if len(keys) > limit {
+		return keys[:limit], keys[limit-1]
+	}
+
+	if more && len(keys) > 0 {
+		return keys, keys[len(keys)-1]
+	}
+
+	return keys, ""
+}
+
+// Health - liveness and queue depth of every current worker
+func (wm *WorkerManager) Health() []WorkerHealth {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	health := make([]WorkerHealth, len(wm.workers))
+	for i, w := range wm.workers {
+		health[i] = w.health(wm.cfg.StuckAfter)
+	}
+	return health
+}
+
+// Stores - every store, that is used by manager, including retired stores, that are not drained yet
+func (wm *WorkerManager) Stores() []*ttlstore.MapStore[string, string] {
+	return wm.mig.all()
+}
+
+func (wm *WorkerManager) listen(w *Worker) {
+	wm.logger.Infof("Worker%d. Listening.", w.index)
+	wm.waitG.Add(1)
+	go w.Listen(wm.ctx, wm.waitG)
+}
+
+// Run - starts workers, and moves keys, that are stored in wrong store, to their owners in background.
+// WARNING: stores shoud be loaded before Run
 func (wm *WorkerManager) Run() {
-	wm.WorkerArena.Range(func(w *Worker) {
-		wm.logger.Infof("Worker%d. Listening.", w.index)
-		go w.Listen(wm.ctx, wm.ReqChan, wm.waitG)
+	for _, w := range wm.workers {
+		wm.listen(w)
+	}
+
+	// Previous run could stop before keys were migrated
+	wm.mig.running.Store(true)
+	wm.mig.settled.Store(false)
+	wm.mig.active.Store(true)
+	wm.waitG.Add(1)
+	go wm.migrate(nil)
+
+	if wm.cfg.HotKeys > 0 {
 		wm.waitG.Add(1)
-	})
+		go wm.decay()
+	}
 
 	wm.logger.Info("Running...")
 }
 
+// decay - periodically halves read counters, so keys, that are not read anymore, stop being hot
+func (wm *WorkerManager) decay() {
+	defer wm.waitG.Done()
+
+	ticker := time.NewTicker(wm.cfg.HotDecay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			wm.hot.decay()
+		case <-wm.ctx.Done():
+			return
+		}
+	}
+}
+
+// HotKeys - the most read keys, the hottest first. Keys with at least cfg.HotReads reads are served from replicas.
+func (wm *WorkerManager) HotKeys() []HotKey {
+	return wm.hot.list()
+}
+
 func (wm *WorkerManager) Stop() {
 	wm.logger.Info("Stoping...")
 	wm.cancel()
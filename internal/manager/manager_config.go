@@ -4,15 +4,56 @@ import (
 	"time"
 )
 
+// AdmissionPolicy - what happens with operation, when queue of worker is full
+type AdmissionPolicy string
+
+const (
+	// AdmitBlock - caller waits for free place in queue, until ctx is done
+	AdmitBlock AdmissionPolicy = "block"
+	// AdmitReject - operation is rejected at once with ErrOverloaded
+	AdmitReject AdmissionPolicy = "reject"
+
+	DEFAULT_QUEUE_SIZE  = 100
+	DEFAULT_RETRY_AFTER = time.Second
+	DEFAULT_STUCK_AFTER = 5 * time.Second
+	DEFAULT_HOT_KEYS    = 16
+	DEFAULT_HOT_READS   = 1000
+	DEFAULT_HOT_DECAY   = 10 * time.Second
+	DEFAULT_REPLICA_TTL = time.Second
+
+	DEFAULT_MAX_VALUE_SIZE = 8 << 20
+)
+
 type ManagerConfig struct {
 	WorkerNum uint          `yaml:"worker-num"`
 	ValTTL    time.Duration `yaml:"val-ttl"`
+	// OpTimeout - max duration of single operation, including time in queue of worker. Zero disables timeout.
+	OpTimeout time.Duration `yaml:"op-timeout"`
+	// QueueSize - max number of tasks, queued to one worker
+	QueueSize uint `yaml:"queue-size"`
+	// Admission - block (default) or reject
+	Admission AdmissionPolicy `yaml:"admission"`
+	// RetryAfter - delay, suggested to clients, when operation is rejected
+	RetryAfter time.Duration `yaml:"retry-after"`
+	// StuckAfter - worker, that processes one task longer, is reported unhealthy. Negative disables check.
+	StuckAfter time.Duration `yaml:"stuck-after"`
+	// HotKeys - max number of tracked hot keys. Negative disables read replicas.
+	HotKeys int `yaml:"hot-keys"`
+	// HotReads - key, read at least HotReads times per HotDecay, is served from replicas on every worker
+	HotReads uint32 `yaml:"hot-reads"`
+	// HotDecay - period, after which read counters are halved
+	HotDecay time.Duration `yaml:"hot-decay"`
+	// ReplicaTTL - max duration between reads of hot key from its owner store
+	ReplicaTTL time.Duration `yaml:"replica-ttl"`
+	// MaxValueSize - max size of value in bytes, larger writes fail with ErrValueTooLarge. Negative disables limit.
+	MaxValueSize int64 `yaml:"max-value-size"`
 }
 
-func newManagerConfig(WorkerNum uint, ValTTL time.Duration) ManagerConfig {
+func newManagerConfig(WorkerNum uint, ValTTL time.Duration, OpTimeout time.Duration) ManagerConfig {
 	return ManagerConfig{
 		ValTTL:    ValTTL,
 		WorkerNum: WorkerNum,
+		OpTimeout: OpTimeout,
 	}
 
 }
@@ -2,8 +2,10 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -29,7 +31,7 @@ func TestManagerNotExist(t *testing.T) {
 	stores := make([]*ttlstore.MapStore[string, string], storeCount)
 
 	for i := 0; i < len(stores); i++ {
-		path := fmt.Sprintf("/home/home/go/src/timedQ/internal/manager/#temp%d.db", i)
+		path := fmt.Sprintf("#temp%d.db", i)
 		stores[i] = ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, path, true))
 		if err := stores[i].Load(); err != nil {
 			t.Logf("Error at %s:", path)
@@ -49,31 +51,61 @@ func TestManagerNotExist(t *testing.T) {
 		defer stores[i].Close()
 	}
 
-	wmcfg := newManagerConfig(uint(storeCount), time.Minute)
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
 	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
 
 	wm.Run()
 
-	res := wm.Get("test|")
+	res, found, err := wm.Get(ctx, "test|")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if found || res != "" {
+		t.Errorf("Want not found, got: %s\n", res)
+	}
 
-	if res != "" {
-		t.Errorf("Want empty, got: %s\n", res)
+	// Stored empty value is found
+	if err := wm.Set(ctx, "empty", "", WriteApplied); err != nil {
+		t.Error(err)
+	}
+	if _, found, _ := wm.Get(ctx, "empty"); !found {
+		t.Error("Want empty value to be found")
 	}
 
 	time.Sleep(time.Second * 2)
 
 	wm.Stop()
+
+	if _, _, err := wm.Get(ctx, "test|"); err != ErrStopped {
+		t.Errorf("Want ErrStopped, got: %v", err)
+	}
 }
 
-func TestWorkerRing(t *testing.T) {
-	wr := &WorkerRing{}
-	for i := 0; i < 5; i++ {
-		wr.Push(newWorker(i, time.Second, nil, nil, nil, nil))
+func TestWorkerShards(t *testing.T) {
+	stores := newMemoryStores(context.Background(), 5)
+	wm := NewWorkerManager(context.Background(), stores, logger, newManagerConfig(5, time.Second, 0))
+	again := NewWorkerManager(context.Background(), stores, logger, newManagerConfig(5, time.Second, 0))
+
+	used := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("key%d", i)
+
+		// Owner does not depend on instance, so keys are found in the same store after restart
+		if wm.owner(k).index != again.owner(k).index {
+			t.Errorf("Key %s has different owners", k)
+		}
+		used[wm.owner(k).index]++
+	}
+
+	if len(used) != 5 {
+		t.Errorf("Want keys in every shard, got: %v", used)
 	}
 
-	wr.Range(func(w *Worker) {
-		t.Logf("Wroker index: %d", w.index)
-	})
+	// Keys with the same hash tag belong to one shard
+	if wm.owner("{user:1}:name") != wm.owner("{user:1}:email") {
+		t.Error("Keys with the same hash tag have different owners")
+	}
 }
 
 func TestManager(t *testing.T) {
@@ -84,7 +116,7 @@ func TestManager(t *testing.T) {
 	stores := make([]*ttlstore.MapStore[string, string], storeCount)
 
 	for i := 0; i < len(stores); i++ {
-		path := fmt.Sprintf("/home/home/go/src/timedQ/internal/manager/#temp%d.db", i)
+		path := fmt.Sprintf("#temp%d.db", i)
 		stores[i] = ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, path, true))
 		if err := stores[i].Load(); err != nil {
 			t.Logf("Error at %s:", path)
@@ -101,16 +133,16 @@ func TestManager(t *testing.T) {
 		defer stores[i].Close()
 	}
 
-	wmcfg := newManagerConfig(uint(storeCount), time.Minute)
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
 	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
 
 	wm.Run()
 
-	wm.Set("ping", "pong")
+	wm.Set(ctx, "ping", "pong", WriteApplied)
 
 	time.Sleep(time.Second * 2)
 
-	res := wm.Get("ping")
+	res, _, _ := wm.Get(ctx, "ping")
 
 	t.Logf("Got: %s", res)
 
@@ -127,7 +159,7 @@ func TestManagerBigChunks(t *testing.T) {
 	stores := make([]*ttlstore.MapStore[string, string], storeCount)
 
 	for i := 0; i < len(stores); i++ {
-		path := fmt.Sprintf("/home/home/go/src/timedQ/internal/manager/#temp%d.db", i)
+		path := fmt.Sprintf("#temp%d.db", i)
 		stores[i] = ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, path, true))
 		if err := stores[i].Load(); err != nil {
 			t.Logf("Error at %s:", path)
@@ -144,7 +176,7 @@ func TestManagerBigChunks(t *testing.T) {
 		defer stores[i].Close()
 	}
 
-	wmcfg := newManagerConfig(uint(storeCount), time.Minute)
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
 	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
 
 	wm.Run()
@@ -158,13 +190,13 @@ func TestManagerBigChunks(t *testing.T) {
 		wg.Add(1)
 		k := fmt.Sprintf("test{%d}", j)
 		v := fmt.Sprintf("test_value{%d}", j)
-		wm.Set(k, v)
+		wm.Set(ctx, k, v, WriteApplied)
 		time.Sleep(time.Second / 4)
 		go func(wg *sync.WaitGroup) {
 			defer wg.Done()
 			for i := 0; i < nReq; i++ {
-				val := wm.Get(k)
-				if val == "" {
+				val, found, err := wm.Get(ctx, k)
+				if err != nil || !found || val == "" {
 					t.Error("Cant get value")
 				}
 			}
@@ -189,3 +221,1008 @@ func TestManagerBigChunks(t *testing.T) {
 		}
 	}
 }
+
+// newMemoryStores - creates stores, that are not saved to file
+func newMemoryStores(ctx context.Context, n int) []*ttlstore.MapStore[string, string] {
+	stores := make([]*ttlstore.MapStore[string, string], n)
+	for i := 0; i < n; i++ {
+		stores[i] = ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	}
+	return stores
+}
+
+func TestManagerScan(t *testing.T) {
+	ctx := context.Background()
+
+	storeCount := 3
+
+	stores := newMemoryStores(ctx, storeCount)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
+	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
+
+	// Spread keys over every store, to check that pages are merged in order
+	for i := 0; i < 20; i++ {
+		if err := stores[i%storeCount].Set(ctx, fmt.Sprintf("user:42:%02d", i), "v", -1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := stores[0].Set(ctx, "user:43:00", "v", -1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var got []string
+	cursor := ""
+	for {
+		keys, next := wm.Scan("user:42:", cursor, 7)
+		got = append(got, keys...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(got) != 20 {
+		t.Errorf("Want 20 keys, got: %d", len(got))
+		return
+	}
+
+	for i, k := range got {
+		if want := fmt.Sprintf("user:42:%02d", i); k != want {
+			t.Errorf("Want %s, got: %s", want, k)
+		}
+	}
+}
+
+func TestManagerBatch(t *testing.T) {
+	ctx := context.Background()
+
+	storeCount := 3
+
+	stores := newMemoryStores(ctx, storeCount)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
+	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
+
+	wm.Run()
+	defer wm.Stop()
+
+	if err := wm.MSet(ctx, []string{"a", "b", "c"}, []string{"1", "2", "3"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := wm.Multi().Set("{a}d", "4").Delete("a").Exec(ctx); err != nil {
+		t.Error(err)
+		return
+	}
+
+	other := "a"
+	for i := 0; wm.owner(other) == wm.owner("a"); i++ {
+		other = fmt.Sprintf("other%d", i)
+	}
+
+	if err := wm.Multi().Set("a", "1").Set(other, "2").Exec(ctx); err != ErrCrossShard {
+		t.Errorf("Want ErrCrossShard, got: %v", err)
+	}
+
+	if err := wm.MDelete(ctx, []string{"c"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	vals, found, err := wm.MGet(ctx, []string{"a", "b", "c", "{a}d", "e"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	wantFound := []bool{false, true, false, true, false}
+	wantVals := []string{"", "2", "", "4", ""}
+	for i := range wantFound {
+		if found[i] != wantFound[i] || vals[i] != wantVals[i] {
+			t.Errorf("Key %d: want (%s, %v), got: (%s, %v)", i, wantVals[i], wantFound[i], vals[i], found[i])
+		}
+	}
+
+	if err := wm.MSet(ctx, []string{"a"}, nil); err != ttlstore.ErrBatchMismatch {
+		t.Errorf("Want ErrBatchMismatch, got: %v", err)
+	}
+}
+
+func TestManagerWatch(t *testing.T) {
+	ctx := context.Background()
+
+	storeCount := 3
+
+	stores := newMemoryStores(ctx, storeCount)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
+	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
+
+	wm.Run()
+	defer wm.Stop()
+
+	sub := wm.Watch(ttlstore.WatchConfig[string]{Prefix: "user:"})
+	defer sub.Close()
+
+	// Keys are written directly, so events come from different stores
+	for i := 0; i < storeCount; i++ {
+		if err := stores[i].Set(ctx, fmt.Sprintf("user:%d", i), "v", -1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	for i := 0; i < storeCount; i++ {
+		select {
+		case e := <-sub.Events():
+			if e.Type != ttlstore.EventSet {
+				t.Errorf("Want set event, got: %s", e.Type)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Event %d was not delivered", i)
+			return
+		}
+	}
+}
+
+func TestManagerRebalance(t *testing.T) {
+	ctx := context.Background()
+
+	storeCount := 3
+
+	stores := newMemoryStores(ctx, storeCount)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
+	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
+
+	// Keys left in every store by ring version of manager
+	for i := 0; i < 30; i++ {
+		if err := stores[i%storeCount].Set(ctx, fmt.Sprintf("key%d", i), fmt.Sprintf("val%d", i), -1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	wm.Run()
+	defer wm.Stop()
+
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("key%d", i)
+		if val, _, _ := wm.Get(ctx, k); val != fmt.Sprintf("val%d", i) {
+			t.Errorf("Key %s: want val%d, got: %s", k, i, val)
+		}
+	}
+
+	waitMigration(t, wm)
+
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("key%d", i)
+		for j, s := range stores {
+			if _, ok := s.Get(ctx, k); ok != (wm.owner(k).index == j) {
+				t.Errorf("Key %s: present in store %d is %v", k, j, ok)
+			}
+		}
+	}
+}
+
+func waitMigration(t *testing.T, wm *WorkerManager) {
+	for i := 0; wm.Status().Migrating; i++ {
+		if i > 100 {
+			t.Fatal("migration is not finished")
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+}
+
+// memoryProvider - creates in memory stores for new workers
+type memoryProvider struct {
+	ctx     context.Context
+	created []*ttlstore.MapStore[string, string]
+	dropped []*ttlstore.MapStore[string, string]
+}
+
+func (p *memoryProvider) Create(index int) (*ttlstore.MapStore[string, string], error) {
+	s := newMemoryStores(p.ctx, 1)[0]
+	p.created = append(p.created, s)
+	return s, nil
+}
+
+func (p *memoryProvider) Drop(s *ttlstore.MapStore[string, string]) error {
+	p.dropped = append(p.dropped, s)
+	return s.Close()
+}
+
+func TestManagerReshard(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	// stores[1] is dropped by shrink
+	defer stores[0].Close()
+
+	wm := NewWorkerManager(ctx, stores, logger, newManagerConfig(2, time.Minute, 0))
+	if err := wm.Reshard(4); err != ErrNoStoreProvider {
+		t.Errorf("want ErrNoStoreProvider, got: %v", err)
+	}
+
+	p := &memoryProvider{ctx: ctx}
+	wm.SetStoreProvider(p)
+
+	wm.Run()
+	defer wm.Stop()
+
+	keyCount := 200
+	for i := 0; i < keyCount; i++ {
+		wm.Set(ctx, fmt.Sprintf("key%d", i), fmt.Sprintf("val%d", i), WriteApplied)
+	}
+	waitMigration(t, wm)
+
+	check := func(workers int) {
+		if st := wm.Status(); st.Workers != workers {
+			t.Errorf("want %d workers, got: %d", workers, st.Workers)
+		}
+
+		for i := 0; i < keyCount; i++ {
+			k := fmt.Sprintf("key%d", i)
+			if val, _, _ := wm.Get(ctx, k); val != fmt.Sprintf("val%d", i) {
+				t.Errorf("Key %s: want val%d, got: %s", k, i, val)
+			}
+		}
+	}
+
+	sub := wm.Watch(ttlstore.WatchConfig[string]{Buffer: keyCount * 4})
+	defer sub.Close()
+
+	// Grow
+	if err := wm.Reshard(4); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Keys are served while they are migrating
+	check(4)
+	waitMigration(t, wm)
+	check(4)
+
+	// Migrated keys do not change for watchers, reads only refresh them
+	for len(sub.Events()) > 0 {
+		if e := <-sub.Events(); e.Type != ttlstore.EventRefresh {
+			t.Errorf("Want no changes of migrated keys, got: %+v", e)
+		}
+	}
+
+	if len(p.created) != 2 {
+		t.Errorf("want 2 created stores, got: %d", len(p.created))
+	}
+
+	// Watch started before grow gets changes of keys of new workers
+	var added string
+	wm.mu.RLock()
+	for i := 0; added == ""; i++ {
+		if k := fmt.Sprintf("new%d", i); wm.owner(k).index >= 2 {
+			added = k
+		}
+	}
+	wm.mu.RUnlock()
+
+	if err := wm.Set(ctx, added, "val", WriteApplied); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-sub.Events():
+		if e.Type != ttlstore.EventSet || e.Key != added {
+			t.Errorf("Want set of %s, got: %+v", added, e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Want set event of key %s of new worker", added)
+	}
+
+	if err := wm.MDelete(ctx, []string{added}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys are moved by migration or by lookups, only keys of new workers change owner
+	wm.mu.RLock()
+	changed := 0
+	for i := 0; i < keyCount; i++ {
+		k := fmt.Sprintf("key%d", i)
+		o := wm.owner(k)
+		if o.index >= 2 {
+			changed++
+		}
+
+		if _, ok := o.store.Get(ctx, k); !ok {
+			t.Errorf("Key %s is not in store of owner", k)
+		}
+	}
+	wm.mu.RUnlock()
+
+	if changed == 0 || changed == keyCount {
+		t.Errorf("want some keys to change owner, changed: %d", changed)
+	}
+
+	// Shrink
+	if err := wm.Reshard(1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := wm.Reshard(3); err != ErrReshardInProgress && wm.Status().Migrating {
+		t.Errorf("want ErrReshardInProgress, got: %v", err)
+	}
+
+	waitMigration(t, wm)
+	check(1)
+
+	if len(p.dropped) != 3 {
+		t.Errorf("want 3 dropped stores, got: %d", len(p.dropped))
+	}
+
+	if keys, _ := stores[0].Scan("", "", keyCount+1); len(keys) != keyCount {
+		t.Errorf("want %d keys in last store, got: %d", keyCount, len(keys))
+	}
+
+	if err := wm.Reshard(0); err != ErrInvalidWorkerNum {
+		t.Errorf("want ErrInvalidWorkerNum, got: %v", err)
+	}
+}
+
+func TestManagerContext(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wm := NewWorkerManager(ctx, stores, logger, newManagerConfig(2, time.Minute, time.Millisecond*50))
+
+	// Workers are not running yet, so tasks stay in queue until timeout
+	if err := wm.Set(ctx, "stuck", "val", WriteApplied); err != context.DeadlineExceeded {
+		t.Errorf("Want DeadlineExceeded, got: %v", err)
+	}
+
+	if _, _, err := wm.Get(ctx, "stuck"); err != context.DeadlineExceeded {
+		t.Errorf("Want DeadlineExceeded, got: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if err := wm.MSet(cancelled, []string{"a"}, []string{"1"}); err != context.Canceled {
+		t.Errorf("Want Canceled, got: %v", err)
+	}
+
+	wm.Run()
+	defer wm.Stop()
+
+	// Timed out set is skipped by worker
+	if _, found, err := wm.Get(ctx, "stuck"); err != nil || found {
+		t.Errorf("Want not found, got: (%v, %v)", found, err)
+	}
+
+	if err := wm.Set(ctx, "a", "1", WriteApplied); err != nil {
+		t.Error(err)
+	}
+
+	if val, found, err := wm.Get(ctx, "a"); err != nil || !found || val != "1" {
+		t.Errorf("Want 1, got: (%s, %v, %v)", val, found, err)
+	}
+}
+
+func TestManagerAdmission(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 1)
+	defer stores[0].Close()
+
+	cfg := newManagerConfig(1, time.Minute, time.Millisecond*50)
+	cfg.QueueSize = 1
+	cfg.Admission = AdmitReject
+
+	wm := NewWorkerManager(ctx, stores, logger, cfg)
+
+	// Workers are not running, first task fills the queue
+	if err := wm.Set(ctx, "a", "1", WriteApplied); err != context.DeadlineExceeded {
+		t.Errorf("Want DeadlineExceeded, got: %v", err)
+	}
+
+	if err := wm.Set(ctx, "b", "2", WriteApplied); err != ErrOverloaded {
+		t.Errorf("Want ErrOverloaded, got: %v", err)
+	}
+
+	if _, _, err := wm.MGet(ctx, []string{"a", "b"}); err != ErrOverloaded {
+		t.Errorf("Want ErrOverloaded, got: %v", err)
+	}
+
+	if wm.Rejected() != 2 {
+		t.Errorf("Want 2 rejected operations, got: %d", wm.Rejected())
+	}
+
+	if wm.RetryAfter() != DEFAULT_RETRY_AFTER {
+		t.Errorf("Want default retry after, got: %v", wm.RetryAfter())
+	}
+
+	wm.Run()
+	defer wm.Stop()
+	waitMigration(t, wm)
+
+	// Queue is drained, operations are admitted again
+	if err := wm.Set(ctx, "b", "2", WriteApplied); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerWriteConcern(t *testing.T) {
+	ctx := context.Background()
+
+	path := "#concern.db"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	store := ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, path, true))
+	if err := store.Load(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := store.Run(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	wm := NewWorkerManager(ctx, []*ttlstore.MapStore[string, string]{store}, logger, newManagerConfig(1, time.Minute, time.Second))
+	wm.Run()
+	waitMigration(t, wm)
+
+	// Async write is not skipped, after caller is gone
+	cancelled, cancel := context.WithCancel(ctx)
+	if err := wm.Set(cancelled, "async", "1", WriteAsync); err != nil {
+		t.Error(err)
+	}
+	cancel()
+
+	if err := wm.Set(ctx, "durable", "2", WriteDurable); err != nil {
+		t.Error(err)
+	}
+
+	// Tasks of one worker are processed in order, so async write is done before durable one
+	if _, found, _ := wm.Get(ctx, "async"); !found {
+		t.Error("Want async write to be applied")
+	}
+
+	wm.Stop()
+	store.Close()
+
+	// Both writes are in file
+	reopened := ttlstore.NewMapStore[string, string](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, path, true))
+	defer reopened.Close()
+
+	if err := reopened.Load(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, found := reopened.MGet(ctx, []string{"async", "durable"}); !found[0] || !found[1] {
+		t.Errorf("Want both writes saved, got: %v", found)
+	}
+
+	if _, err := ParseWriteConcern("eventually"); err != ErrInvalidWriteConcern {
+		t.Errorf("Want ErrInvalidWriteConcern, got: %v", err)
+	}
+
+	if wc, err := ParseWriteConcern(""); err != nil || wc != WriteApplied {
+		t.Errorf("Want applied by default, got: %v, %v", wc, err)
+	}
+
+	// Store, that is not saved to file, can not sync durable write
+	memory := newMemoryStores(ctx, 1)
+	defer memory[0].Close()
+
+	wm = NewWorkerManager(ctx, memory, logger, newManagerConfig(1, time.Minute, time.Second))
+	wm.Run()
+	defer wm.Stop()
+
+	if err := wm.Set(ctx, "durable", "2", WriteDurable); !errors.Is(err, ErrInvalidWriteConcern) {
+		t.Errorf("Want ErrInvalidWriteConcern, got: %v", err)
+	}
+
+	if _, err := wm.SetNX(ctx, "durable", "2", WriteDurable); !errors.Is(err, ErrInvalidWriteConcern) {
+		t.Errorf("Want ErrInvalidWriteConcern, got: %v", err)
+	}
+
+	if _, found, _ := wm.Get(ctx, "durable"); found {
+		t.Error("Want durable write rejected before it is applied")
+	}
+}
+
+func TestManagerSupervisor(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wm := NewWorkerManager(ctx, stores, logger, newManagerConfig(2, time.Minute, time.Second))
+	wm.Run()
+	defer wm.Stop()
+	waitMigration(t, wm)
+
+	if err := wm.Set(ctx, "a", "1", WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Task without result slices panics in worker, that owns the key
+	w := wm.owner("a")
+	bad := &Task{
+		Keys:     []string{"a"},
+		DoneChan: make(chan error, 1),
+		Type:     MGetTask,
+	}
+
+	wm.mu.RLock()
+	err := wm.enqueue(ctx, w, bad)
+	wm.mu.RUnlock()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := wm.wait(ctx, []*Task{bad}); err != ErrCrashed {
+		t.Errorf("Want ErrCrashed, got: %v", err)
+	}
+
+	// Worker is restarted with its store
+	if val, found, err := wm.Get(ctx, "a"); err != nil || !found || val != "1" {
+		t.Errorf("Want 1, got: (%s, %v, %v)", val, found, err)
+	}
+
+	health := wm.Health()
+	if len(health) != 2 {
+		t.Errorf("Want health of 2 workers, got: %d", len(health))
+		return
+	}
+
+	for _, h := range health {
+		if !h.Alive || !h.Healthy || h.QueueSize != DEFAULT_QUEUE_SIZE {
+			t.Errorf("Unexpected health: %+v", h)
+		}
+
+		restarts := uint64(0)
+		if h.Index == w.index {
+			restarts = 1
+		}
+
+		if h.Restarts != restarts {
+			t.Errorf("Want %d restarts of worker %d, got: %d", restarts, h.Index, h.Restarts)
+		}
+	}
+}
+
+func TestHotKeys(t *testing.T) {
+	hk := newHotKeys(2, 10)
+
+	for i := 0; i < 100; i++ {
+		hk.read("a")
+		if i%2 == 0 {
+			hk.read("b")
+		}
+		hk.read(fmt.Sprintf("cold-%d", i))
+	}
+
+	keys := hk.list()
+	if len(keys) != 2 || keys[0].Key != "a" || keys[1].Key != "b" {
+		t.Errorf("Want hot keys [a b], got: %+v", keys)
+		return
+	}
+
+	if keys[0].Reads < 100 || keys[1].Reads < 50 {
+		t.Errorf("Reads are underestimated: %+v", keys)
+	}
+
+	k, ok := hk.hot("a")
+	if !ok {
+		t.Error("Want a to be hot")
+		return
+	}
+
+	epoch := k.written.Load()
+	hk.written("a")
+	if k.written.Load() <= epoch {
+		t.Error("Want write to invalidate replicas")
+	}
+
+	// a: 100 -> 50 -> 25 -> 12 -> 6 -> 3, 3 < 10/2
+	for i := 0; i < 5; i++ {
+		hk.decay()
+	}
+
+	if keys := hk.list(); len(keys) != 0 {
+		t.Errorf("Want cold keys to leave top, got: %+v", keys)
+	}
+}
+
+func TestManagerReplicas(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 4)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	cfg := newManagerConfig(4, time.Minute, time.Second)
+	cfg.HotReads = 5
+	cfg.ReplicaTTL = time.Minute
+
+	wm := NewWorkerManager(ctx, stores, logger, cfg)
+	wm.Run()
+	defer wm.Stop()
+	waitMigration(t, wm)
+
+	if err := wm.Set(ctx, "hot", "1", WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 20; i++ {
+		if val, found, err := wm.Get(ctx, "hot"); err != nil || !found || val != "1" {
+			t.Errorf("Want 1, got: (%s, %v, %v)", val, found, err)
+			return
+		}
+	}
+
+	if keys := wm.HotKeys(); len(keys) != 1 || keys[0].Key != "hot" {
+		t.Errorf("Want hot key, got: %+v", keys)
+		return
+	}
+
+	// Key is removed behind manager, so only replicas still have it
+	wm.mu.RLock()
+	owner := wm.owner("hot")
+	wm.mu.RUnlock()
+
+	if err := owner.store.Delete(ctx, "hot"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	replicated := 0
+	for i := 0; i < 8; i++ {
+		if _, found, _ := wm.Get(ctx, "hot"); found {
+			replicated++
+		}
+	}
+
+	if replicated != 6 {
+		t.Errorf("Want 6 reads from replicas, got: %d", replicated)
+	}
+
+	// Write invalidates every replica
+	if err := wm.Set(ctx, "hot", "2", WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 8; i++ {
+		if val, found, err := wm.Get(ctx, "hot"); err != nil || !found || val != "2" {
+			t.Errorf("Want 2, got: (%s, %v, %v)", val, found, err)
+			return
+		}
+	}
+}
+
+func TestManagerSetNX(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wm := NewWorkerManager(ctx, stores, logger, newManagerConfig(2, time.Minute, time.Second))
+	wm.Run()
+	defer wm.Stop()
+	waitMigration(t, wm)
+
+	if _, err := wm.SetNX(ctx, "a", "1", WriteAsync); err != ErrInvalidWriteConcern {
+		t.Errorf("Want ErrInvalidWriteConcern, got: %v", err)
+	}
+
+	if ok, err := wm.SetNX(ctx, "a", "1", WriteApplied); err != nil || !ok {
+		t.Errorf("Want key to be set, got: %v, %v", ok, err)
+	}
+
+	if ok, err := wm.SetNX(ctx, "a", "2", WriteApplied); err != nil || ok {
+		t.Errorf("Want present key to be kept, got: %v, %v", ok, err)
+	}
+
+	if val, _, _ := wm.Get(ctx, "a"); val != "1" {
+		t.Errorf("Want 1, got: %s", val)
+	}
+
+	for want := uint64(1); want <= 3; want++ {
+		if n, err := wm.Incr(ctx, "counter"); err != nil || n != want {
+			t.Errorf("Want %d, got: %d, %v", want, n, err)
+		}
+	}
+
+	if err := wm.Set(ctx, "b", "x", WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := wm.Incr(ctx, "b"); err != ErrNotCounter {
+		t.Errorf("Want ErrNotCounter, got: %v", err)
+	}
+}
+
+func TestManagerMaxValueSize(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	cfg := newManagerConfig(2, time.Minute, time.Second)
+	cfg.MaxValueSize = 4 << 20
+
+	wm := NewWorkerManager(ctx, stores, logger, cfg)
+	wm.Run()
+	defer wm.Stop()
+	waitMigration(t, wm)
+
+	large := strings.Repeat("x", 4<<20)
+	if err := wm.Set(ctx, "large", large, WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if val, found, err := wm.Get(ctx, "large"); err != nil || !found || val != large {
+		t.Errorf("Want value of %d bytes, got %d bytes, %v, %v", len(large), len(val), found, err)
+	}
+
+	tooLarge := large + "x"
+	if err := wm.Set(ctx, "a", tooLarge, WriteApplied); err != ErrValueTooLarge {
+		t.Errorf("Want ErrValueTooLarge, got: %v", err)
+	}
+
+	if _, err := wm.SetNX(ctx, "a", tooLarge, WriteApplied); err != ErrValueTooLarge {
+		t.Errorf("Want ErrValueTooLarge, got: %v", err)
+	}
+
+	if err := wm.MSet(ctx, []string{"a", "b"}, []string{"1", tooLarge}); err != ErrValueTooLarge {
+		t.Errorf("Want ErrValueTooLarge, got: %v", err)
+	}
+
+	if err := wm.Multi().Set("a", tooLarge).Exec(ctx); err != ErrValueTooLarge {
+		t.Errorf("Want ErrValueTooLarge, got: %v", err)
+	}
+
+	if _, found, _ := wm.Get(ctx, "a"); found {
+		t.Error("Want rejected value not to be stored")
+	}
+}
+
+func TestManagerCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wm := NewWorkerManager(ctx, stores, logger, newManagerConfig(2, time.Minute, time.Second))
+	wm.Run()
+	defer wm.Stop()
+	waitMigration(t, wm)
+
+	if _, err := wm.CompareAndSwap(ctx, "a", "1", WriteApplied); err != ErrRevMismatch {
+		t.Errorf("Want ErrRevMismatch for missing key, got: %v", err)
+	}
+
+	if err := wm.Set(ctx, "a", "1", WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, rev, found, err := wm.GetRev(ctx, "a")
+	if err != nil || !found || rev == 0 {
+		t.Errorf("Want revision of key, got: %d, %v, %v", rev, found, err)
+	}
+
+	// Reading key refreshes its ttl, but keeps revision
+	if _, again, _, _ := wm.GetRev(ctx, "a"); again != rev {
+		t.Errorf("Want revision %d after read, got: %d", rev, again)
+	}
+
+	if _, err := wm.CompareAndSwap(ctx, "a", "2", WriteAsync, rev); err != ErrInvalidWriteConcern {
+		t.Errorf("Want ErrInvalidWriteConcern, got: %v", err)
+	}
+
+	if _, err := wm.CompareAndSwap(ctx, "a", "2", WriteApplied, rev+100); err != ErrRevMismatch {
+		t.Errorf("Want ErrRevMismatch, got: %v", err)
+	}
+
+	newRev, err := wm.CompareAndSwap(ctx, "a", "2", WriteApplied, rev+100, rev)
+	if err != nil || newRev <= rev {
+		t.Errorf("Want new revision greater than %d, got: %d, %v", rev, newRev, err)
+	}
+
+	if val, got, _, _ := wm.GetRev(ctx, "a"); val != "2" || got != newRev {
+		t.Errorf("Want 2 with revision %d, got: %s, %d", newRev, val, got)
+	}
+
+	// Old revision does not match anymore
+	if err := wm.CompareAndDelete(ctx, "a", rev); err != ErrRevMismatch {
+		t.Errorf("Want ErrRevMismatch, got: %v", err)
+	}
+
+	if err := wm.CompareAndDelete(ctx, "a", newRev); err != nil {
+		t.Error(err)
+	}
+
+	if _, found, _ := wm.Get(ctx, "a"); found {
+		t.Error("Want key to be deleted")
+	}
+
+	// Empty revisions match any revision of present key
+	if err := wm.CompareAndDelete(ctx, "a"); err != ErrRevMismatch {
+		t.Errorf("Want ErrRevMismatch for missing key, got: %v", err)
+	}
+
+	if err := wm.Set(ctx, "a", "3", WriteApplied); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Memory stores can not sync durable write
+	if _, err := wm.CompareAndSwap(ctx, "a", "4", WriteDurable); !errors.Is(err, ErrInvalidWriteConcern) {
+		t.Errorf("Want ErrInvalidWriteConcern, got: %v", err)
+	}
+
+	if _, err := wm.CompareAndSwap(ctx, "a", "4", WriteApplied); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagerGetWithTTL(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wm := NewWorkerManager(ctx, stores, logger, newManagerConfig(2, time.Minute, time.Second))
+	wm.Run()
+	defer wm.Stop()
+
+	for key, ttl := range map[string]time.Duration{"sliding": 0, "fixed": time.Hour, "forever": -1} {
+		if err := wm.SetWithTTL(ctx, key, "1", ttl, WriteApplied); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Read refreshes sliding ttl to manager.val-ttl
+	if _, _, expires, found, err := wm.GetWithTTL(ctx, "sliding"); err != nil || !found || time.Until(expires) < 58*time.Second || time.Until(expires) > time.Minute+time.Second {
+		t.Errorf("Want value to expire after val-ttl, got: %v, %v, %v", expires, found, err)
+	}
+
+	if _, _, expires, found, err := wm.GetWithTTL(ctx, "fixed"); err != nil || !found || time.Until(expires) < 59*time.Minute {
+		t.Errorf("Want value to expire after its own ttl, got: %v, %v, %v", expires, found, err)
+	}
+
+	if _, _, expires, found, err := wm.GetWithTTL(ctx, "forever"); err != nil || !found || !expires.IsZero() {
+		t.Errorf("Want value, that never expires, got: %v, %v, %v", expires, found, err)
+	}
+
+	if _, _, expires, found, _ := wm.GetWithTTL(ctx, "missing"); found || !expires.IsZero() {
+		t.Errorf("Want missing key, got: %v, %v", expires, found)
+	}
+}
+
+func TestManagerGo(t *testing.T) {
+	ctx := context.Background()
+
+	storeCount := 3
+
+	stores := newMemoryStores(ctx, storeCount)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	wmcfg := newManagerConfig(uint(storeCount), time.Minute, 0)
+	wm := NewWorkerManager(context.Background(), stores, logger, wmcfg)
+
+	wm.Run()
+
+	// Functions of one key run in order, after tasks of key queued before them
+	if err := wm.Set(ctx, "key", "v", WriteApplied); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan int, 100)
+	for i := 0; i < 100; i++ {
+		i := i
+		if err := wm.Go(ctx, "key", func() {
+			if _, ok := wm.owner("key").store.Get(ctx, "key"); !ok {
+				t.Error("Want key set before function")
+			}
+			got <- i
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if n := <-got; n != i {
+			t.Fatalf("Want function %d, got: %d", i, n)
+		}
+	}
+
+	wm.Stop()
+	if err := wm.Go(ctx, "key", func() {}); err != ErrStopped {
+		t.Errorf("Want ErrStopped, got: %v", err)
+	}
+}
+
+func TestManagerLookupSettled(t *testing.T) {
+	ctx := context.Background()
+
+	stores := newMemoryStores(ctx, 2)
+	for _, s := range stores {
+		defer s.Close()
+	}
+
+	if err := stores[1].Set(ctx, "a", "old", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	mig := newMigration(stores, nil)
+	mig.active.Store(true)
+
+	w := newWorker(0, time.Minute, stores[0], logger.WithField("worker", 0), make(chan *Task), mig, newHotKeys(0, 0), time.Second)
+
+	// Task of old layout can still write key to other store, so key is not moved yet
+	if val, _, ok := w.lookup(ctx, "a"); !ok || val != "old" {
+		t.Errorf("Want value from other store, got: %s, %v", val, ok)
+	}
+
+	if _, ok := stores[0].Get(ctx, "a"); ok {
+		t.Error("Want key not to be moved before old layout is settled")
+	}
+
+	if err := stores[1].Set(ctx, "a", "new", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	mig.settled.Store(true)
+
+	if val, _, ok := w.lookup(ctx, "a"); !ok || val != "new" {
+		t.Errorf("Want last written value, got: %s, %v", val, ok)
+	}
+
+	if _, ok := stores[1].Get(ctx, "a"); ok {
+		t.Error("Want key to be moved after old layout is settled")
+	}
+}
@@ -0,0 +1,363 @@
+package manager
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+)
+
+// migrateBatch - max number of keys, moved by one MigrateTask
+const migrateBatch = 512
+
+var (
+	ErrInvalidWorkerNum  = errors.New("number of workers must be greater than zero")
+	ErrReshardInProgress = errors.New("keys are still migrating after previous reshard")
+	ErrNoStoreProvider   = errors.New("store provider is not set, can not create stores for new workers")
+)
+
+// StoreProvider - creates stores for workers, added by Reshard, and drops stores of retired workers,
+// after every key has been moved out of them.
+type StoreProvider interface {
+	// Create - returns loaded and running store for worker with index
+	Create(index int) (*ttlstore.MapStore[string, string], error)
+	// Drop - closes store and removes its file
+	Drop(store *ttlstore.MapStore[string, string]) error
+}
+
+// ReshardStatus - current layout of workers and progress of migration
+type ReshardStatus struct {
+	Workers   int
+	Migrating bool
+	// Moved - number of keys, moved to their owners by last migration
+	Moved uint64
+}
+
+// migration - state of keys migration. While migration is active, key can be present in any store,
+// so workers look for missing keys in other stores, and delete keys from every store.
+type migration struct {
+	active  atomic.Bool
+	running atomic.Bool
+	// settled - tasks, queued by old layout, are done, so keys in other stores do not change anymore
+	settled atomic.Bool
+	moved   atomic.Uint64
+
+	mu *sync.RWMutex
+	// stores - every store in use, stores of current workers come first
+	stores []*ttlstore.MapStore[string, string]
+	// retired - stores, that are drained by running migration and dropped after it
+	retired []*ttlstore.MapStore[string, string]
+	// subs - subscriptions of Watch, they are subscribed to stores, that are added later
+	subs map[*ttlstore.Subscription[string, string]]struct{}
+}
+
+func newMigration(stores []*ttlstore.MapStore[string, string], retired []*ttlstore.MapStore[string, string]) *migration {
+	return &migration{
+		mu:      &sync.RWMutex{},
+		stores:  append([]*ttlstore.MapStore[string, string]{}, stores...),
+		retired: append([]*ttlstore.MapStore[string, string]{}, retired...),
+		subs:    make(map[*ttlstore.Subscription[string, string]]struct{}),
+	}
+}
+
+func (m *migration) all() []*ttlstore.MapStore[string, string] {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]*ttlstore.MapStore[string, string]{}, m.stores...)
+}
+
+// others - every store except own
+func (m *migration) others(own *ttlstore.MapStore[string, string]) []*ttlstore.MapStore[string, string] {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stores := make([]*ttlstore.MapStore[string, string], 0, len(m.stores))
+	for _, s := range m.stores {
+		if s != own {
+			stores = append(stores, s)
+		}
+	}
+	return stores
+}
+
+func (m *migration) add(s *ttlstore.MapStore[string, string]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stores = append(m.stores, s)
+	for sub := range m.subs {
+		s.Subscribe(sub)
+	}
+}
+
+// watch - subscribes sub to every store in use and to stores, that are added later.
+// Closed subscriptions are forgotten.
+func (m *migration) watch(sub *ttlstore.Subscription[string, string]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for s := range m.subs {
+		select {
+		case <-s.Done():
+			delete(m.subs, s)
+		default:
+		}
+	}
+
+	for _, s := range m.stores {
+		s.Subscribe(sub)
+	}
+	m.subs[sub] = struct{}{}
+}
+
+func (m *migration) retire(s *ttlstore.MapStore[string, string]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retired = append(m.retired, s)
+}
+
+// takeRetired - removes retired stores from stores in use and returns them
+func (m *migration) takeRetired() []*ttlstore.MapStore[string, string] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	retired := m.retired
+	m.retired = nil
+
+	stores := m.stores[:0]
+	for _, s := range m.stores {
+		drop := false
+		for _, r := range retired {
+			if s == r {
+				drop = true
+				break
+			}
+		}
+
+		if !drop {
+			stores = append(stores, s)
+		}
+	}
+	m.stores = stores
+
+	return retired
+}
+
+// SetStoreProvider - sets provider of stores for Reshard. Without provider, number of workers can not grow,
+// and stores of retired workers are only closed.
+func (wm *WorkerManager) SetStoreProvider(p StoreProvider) {
+	wm.provider = p
+}
+
+// Reshard - changes number of workers to n without stopping the manager. Keys are routed by new layout at once,
+// then keys, that changed owner, are moved in background. Rendezvous hashing moves only keys of added
+// or removed workers. Until migration is finished, missing keys are looked up in every store.
+func (wm *WorkerManager) Reshard(n uint) error {
+	if n == 0 {
+		return ErrInvalidWorkerNum
+	}
+
+	if !wm.mig.running.CompareAndSwap(false, true) {
+		return ErrReshardInProgress
+	}
+
+	wm.mu.Lock()
+	cur := len(wm.workers)
+
+	added := make([]*Worker, 0)
+	for idx := cur; idx < int(n); idx++ {
+		if wm.provider == nil {
+			wm.mu.Unlock()
+			wm.mig.running.Store(false)
+			return ErrNoStoreProvider
+		}
+
+		store, err := wm.provider.Create(idx)
+		if err != nil {
+			// Stores, that were already created, are empty, they are dropped by migration
+			for _, w := range added {
+				wm.mig.add(w.store)
+				wm.mig.retire(w.store)
+			}
+			wm.mu.Unlock()
+
+			wm.mig.settled.Store(false)
+			wm.mig.active.Store(true)
+			wm.waitG.Add(1)
+			go wm.migrate(nil)
+			return err
+		}
+
+		added = append(added, wm.newWorker(idx, store))
+	}
+
+	var retired []*Worker
+	if int(n) < cur {
+		retired = wm.workers[n:]
+	}
+
+	wm.logger.Infof("Resharding from %d to %d workers.", cur, n)
+
+	wm.mig.settled.Store(false)
+	wm.mig.active.Store(true)
+	wm.mig.moved.Store(0)
+
+	for _, w := range added {
+		wm.mig.add(w.store)
+		wm.listen(w)
+	}
+
+	for _, w := range retired {
+		wm.mig.retire(w.store)
+	}
+
+	if len(retired) > 0 {
+		wm.workers = wm.workers[:n]
+	}
+	wm.workers = append(wm.workers, added...)
+	wm.shards = newShards(len(wm.workers))
+	wm.mu.Unlock()
+
+	// No task can be queued to retired workers anymore, they stop after queued tasks are done
+	for _, w := range retired {
+		close(w.tasks)
+	}
+
+	wm.waitG.Add(1)
+	go wm.migrate(retired)
+
+	return nil
+}
+
+// Status - current number of workers and progress of migration
+func (wm *WorkerManager) Status() ReshardStatus {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return ReshardStatus{
+		Workers:   len(wm.workers),
+		Migrating: wm.mig.running.Load(),
+		Moved:     wm.mig.moved.Load(),
+	}
+}
+
+// migrate - moves every key to its owner, then drops retired stores. Keys are moved by workers, that own them,
+// so moves are serialized with writes. Migration is repeatable, if process stops, it is resumed by next Run.
+// WARNING: waitG.Add, mig.running and mig.active MUST be set before migrate is started
+func (wm *WorkerManager) migrate(retired []*Worker) {
+	defer wm.waitG.Done()
+	defer wm.mig.running.Store(false)
+
+	for _, w := range retired {
+		select {
+		case <-w.done:
+		case <-wm.ctx.Done():
+			return
+		}
+	}
+
+	// Tasks, queued by old layout, can write keys to stores, that are already drained
+	wm.mu.RLock()
+	barriers := make([]*Task, 0, len(wm.workers))
+	for _, w := range wm.workers {
+		t := &Task{
+			DoneChan: make(chan error, 1),
+			Type:     BarrierTask,
+		}
+
+		if err := wm.enqueue(wm.ctx, w, t); err != nil {
+			wm.mu.RUnlock()
+			return
+		}
+		barriers = append(barriers, t)
+	}
+	wm.mu.RUnlock()
+
+	if err := wm.wait(wm.ctx, barriers); err != nil {
+		return
+	}
+	wm.mig.settled.Store(true)
+
+	for _, s := range wm.mig.all() {
+		if err := wm.drain(s); err != nil {
+			wm.logger.Errorf("got error while migrating keys: %s", err.Error())
+			return
+		}
+	}
+
+	for _, s := range wm.mig.takeRetired() {
+		wm.logger.Infof("Dropping retired store: %s", s.Path())
+
+		var err error
+		if wm.provider != nil {
+			err = wm.provider.Drop(s)
+		} else {
+			err = s.Close()
+		}
+
+		if err != nil {
+			wm.logger.Errorf("got error while dropping store: %s", err.Error())
+		}
+	}
+
+	wm.mig.active.Store(false)
+
+	if moved := wm.mig.moved.Load(); moved > 0 {
+		wm.logger.Infof("Migration finished, %d keys moved.", moved)
+	}
+}
+
+// drain - moves keys, that are not owned by worker of store, to their owners
+func (wm *WorkerManager) drain(s *ttlstore.MapStore[string, string]) error {
+	var err error
+	tasks := make([]*Task, 0)
+	stray := make(map[*Worker][]string)
+
+	flush := func(w *Worker) {
+		t := &Task{
+			Keys:     stray[w],
+			From:     s,
+			DoneChan: make(chan error, 1),
+			Type:     MigrateTask,
+		}
+
+		if err = wm.enqueue(wm.ctx, w, t); err == nil {
+			tasks = append(tasks, t)
+		}
+		delete(stray, w)
+	}
+
+	wm.mu.RLock()
+	s.Snapshot().Range(func(key string, _ string) bool {
+		if o := wm.owner(key); o.store != s {
+			stray[o] = append(stray[o], key)
+			if len(stray[o]) >= migrateBatch {
+				flush(o)
+			}
+		}
+		return err == nil
+	})
+
+	for w := range stray {
+		if err == nil {
+			flush(w)
+		}
+	}
+	wm.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := wm.wait(wm.ctx, tasks); err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		wm.mig.moved.Add(uint64(len(t.Keys)))
+	}
+	return nil
+}
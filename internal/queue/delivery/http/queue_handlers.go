@@ -0,0 +1,185 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/BON4/timedQ/internal/queue"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type queueEnqueueRequest struct {
+	Payload string `json:"payload" binding:"required"`
+	// Delay - duration string, for example 15s or 10m
+	Delay string `json:"delay"`
+}
+
+type queueEnqueueResponse struct {
+	ID string `json:"id"`
+}
+
+type queueMessageResponse struct {
+	ID         string `json:"id"`
+	Queue      string `json:"queue"`
+	Payload    string `json:"payload"`
+	Deliveries int    `json:"deliveries"`
+}
+
+type queueHandler struct {
+	logger *logrus.Entry
+	broker *queue.Broker
+	cfg    queue.QueueConfig
+}
+
+// parseDuration - parses optional duration, empty string is zero duration
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// @Summary      Enqueue
+// @Description  adds message to queue, message becomes visible for dequeue after delay
+// @Tags         queues
+// @Accept       json
+// @Produce      json
+// @Param        name   path      string               true  "queue name"
+// @Param        input  body      queueEnqueueRequest  true  "message"
+// @Success      200    {object}  queueEnqueueResponse
+// @Failure      400    {object}  error
+// @Failure      500    {object}  error
+// @Router       /queues/{name} [post]
+func (q *queueHandler) Enqueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &queueEnqueueRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		delay, err := parseDuration(req.Delay)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		id, err := q.broker.Enqueue(c.Request.Context(), c.Param("name"), req.Payload, delay)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, queueEnqueueResponse{
+			ID: id,
+		})
+	}
+}
+
+// @Summary      Dequeue
+// @Description  waits for visible message up to wait duration. Message is hidden for visibility timeout,
+// @Description  if it is not acknowledged in time, it will be delivered again
+// @Tags         queues
+// @Produce      json
+// @Param        name        path      string  true   "queue name"
+// @Param        visibility  query     string  false  "visibility timeout, for example 30s"
+// @Param        wait        query     string  false  "how long to wait for message, for example 10s"
+// @Success      200         {object}  queueMessageResponse
+// @Success      204
+// @Failure      400         {object}  error
+// @Failure      500         {object}  error
+// @Router       /queues/{name}/dequeue [post]
+func (q *queueHandler) Dequeue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		visibility, err := parseDuration(c.Query("visibility"))
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		wait, err := parseDuration(c.Query("wait"))
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if wait <= 0 || wait > q.cfg.MaxWait {
+			wait = q.cfg.MaxWait
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), wait)
+		defer cancel()
+
+		m, err := q.broker.Dequeue(ctx, c.Param("name"), visibility)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			c.Status(http.StatusNoContent)
+			return
+		} else if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, queueMessageResponse{
+			ID:         m.ID,
+			Queue:      m.Queue,
+			Payload:    m.Payload,
+			Deliveries: m.Deliveries,
+		})
+	}
+}
+
+// @Summary      Ack
+// @Description  removes delivered message from queue. Message, that is in other queue, is not found
+// @Tags         queues
+// @Param        name  path  string  true  "queue name"
+// @Param        id    path  string  true  "message id"
+// @Success      204
+// @Failure      404   {object}  error
+// @Failure      500   {object}  error
+// @Router       /queues/{name}/ack/{id} [post]
+func (q *queueHandler) Ack() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q.respond(c, q.broker.Ack(c.Request.Context(), c.Param("name"), c.Param("id")))
+	}
+}
+
+// @Summary      Nack
+// @Description  makes delivered message visible right away, or moves it to dead letter queue <name>:dead,
+// @Description  if it has been delivered too many times. Message, that has not been delivered yet, can not be nacked
+// @Tags         queues
+// @Param        name  path  string  true  "queue name"
+// @Param        id    path  string  true  "message id"
+// @Success      204
+// @Failure      404   {object}  error
+// @Failure      409   {object}  error
+// @Failure      500   {object}  error
+// @Router       /queues/{name}/nack/{id} [post]
+func (q *queueHandler) Nack() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q.respond(c, q.broker.Nack(c.Request.Context(), c.Param("name"), c.Param("id")))
+	}
+}
+
+func (q *queueHandler) respond(c *gin.Context, err error) {
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, queue.ErrNotFound):
+		c.AbortWithError(http.StatusNotFound, err)
+	case errors.Is(err, queue.ErrNotDelivered):
+		c.AbortWithError(http.StatusConflict, err)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+func NewQueueHandler(broker *queue.Broker, cfg queue.QueueConfig, logger *logrus.Entry) *queueHandler {
+	return &queueHandler{
+		logger: logger,
+		broker: broker,
+		cfg:    cfg,
+	}
+}
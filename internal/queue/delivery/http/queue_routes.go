@@ -0,0 +1,10 @@
+package http
+
+import "github.com/gin-gonic/gin"
+
+func NewQueueRoutes(group *gin.RouterGroup, h *queueHandler) {
+	group.POST("/:name", h.Enqueue())
+	group.POST("/:name/dequeue", h.Dequeue())
+	group.POST("/:name/ack/:id", h.Ack())
+	group.POST("/:name/nack/:id", h.Nack())
+}
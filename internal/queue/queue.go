@@ -0,0 +1,328 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DEAD_LETTER_SUFFIX = ":dead"
+
+	msgPrefix   = "msg:"
+	timerPrefix = "timer:"
+
+	watchBuffer = 1024
+)
+
+var (
+	ErrNotFound     = errors.New("message not found")
+	ErrNotDelivered = errors.New("message has not been delivered yet")
+)
+
+type Message struct {
+	ID         string
+	Queue      string
+	Payload    string
+	Deliveries int
+	Enqueued   int64
+}
+
+// readyQueue - ids of messages, that are visible for Dequeue, in order they became visible
+type readyQueue struct {
+	ids []string
+	// notify - closed and replaced, when new id is pushed
+	notify chan struct{}
+}
+
+// Broker - timed queues on top of ttlstore. Every message is stored under msg:<id> key.
+// While message is delayed or in flight, it also has timer:<id> key with ttl,
+// when timer key expires, message becomes visible again.
+type Broker struct {
+	store  *ttlstore.MapStore[string, Message]
+	cfg    QueueConfig
+	logger *logrus.Entry
+
+	mu     *sync.Mutex
+	queues map[string]*readyQueue
+	queued map[string]struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+func NewBroker(ctx context.Context, store *ttlstore.MapStore[string, Message], logger *logrus.Entry, cfg QueueConfig) *Broker {
+	b := &Broker{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+		mu:     &sync.Mutex{},
+		queues: make(map[string]*readyQueue),
+		queued: make(map[string]struct{}),
+		wg:     &sync.WaitGroup{},
+	}
+
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	return b
+}
+
+func msgKey(id string) string {
+	return msgPrefix + id
+}
+
+func timerKey(id string) string {
+	return timerPrefix + id
+}
+
+func isDeadLetter(queue string) bool {
+	return strings.HasSuffix(queue, DEAD_LETTER_SUFFIX)
+}
+
+// readyQueue - MUST be called under b.mu
+func (b *Broker) readyQueue(queue string) *readyQueue {
+	rq, ok := b.queues[queue]
+	if !ok {
+		rq = &readyQueue{
+			notify: make(chan struct{}),
+		}
+		b.queues[queue] = rq
+	}
+	return rq
+}
+
+// push - makes message visible for Dequeue
+func (b *Broker) push(queue string, id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.queued[id]; ok {
+		return
+	}
+
+	b.queued[id] = struct{}{}
+
+	rq := b.readyQueue(queue)
+	rq.ids = append(rq.ids, id)
+	close(rq.notify)
+	rq.notify = make(chan struct{})
+}
+
+// pop - returns first visible id, or channel that will be closed when next id is pushed
+func (b *Broker) pop(queue string) (string, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rq := b.readyQueue(queue)
+	if len(rq.ids) == 0 {
+		return "", rq.notify
+	}
+
+	id := rq.ids[0]
+	rq.ids = rq.ids[1:]
+	delete(b.queued, id)
+
+	return id, nil
+}
+
+// Enqueue - adds message to queue, message becomes visible after delay
+func (b *Broker) Enqueue(ctx context.Context, queue string, payload string, delay time.Duration) (string, error) {
+	m := Message{
+		ID:       uuid.New().String(),
+		Queue:    queue,
+		Payload:  payload,
+		Enqueued: time.Now().UnixNano(),
+	}
+
+	tx := b.store.Multi().Set(msgKey(m.ID), m, -1)
+	if delay > 0 {
+		tx.Set(timerKey(m.ID), Message{ID: m.ID}, delay)
+	}
+
+	if err := tx.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	if delay <= 0 {
+		b.push(queue, m.ID)
+	}
+
+	return m.ID, nil
+}
+
+// Dequeue - blocks until message is visible in queue, or ctx is done.
+// Message stays invisible for visibility timeout, and becomes visible again if it was not acknowledged.
+func (b *Broker) Dequeue(ctx context.Context, queue string, visibility time.Duration) (Message, error) {
+	if visibility <= 0 {
+		visibility = b.cfg.VisibilityTimeout
+	}
+
+	for {
+		id, notify := b.pop(queue)
+		if notify != nil {
+			select {
+			case <-ctx.Done():
+				return Message{}, ctx.Err()
+			case <-b.ctx.Done():
+				return Message{}, b.ctx.Err()
+			case <-notify:
+				continue
+			}
+		}
+
+		m, ok := b.store.Get(ctx, msgKey(id))
+		if !ok || m.Queue != queue {
+			// Message has been acknowledged, or moved to other queue
+			continue
+		}
+
+		if _, ok := b.store.Get(ctx, timerKey(id)); ok {
+			// Message is already in flight
+			continue
+		}
+
+		m.Deliveries++
+
+		if err := b.store.Multi().
+			Set(msgKey(id), m, -1).
+			Set(timerKey(id), Message{ID: id}, visibility).
+			Exec(ctx); err != nil {
+			return Message{}, err
+		}
+
+		return m, nil
+	}
+}
+
+// Ack - removes message from queue
+func (b *Broker) Ack(ctx context.Context, queue string, id string) error {
+	if m, ok := b.store.Get(ctx, msgKey(id)); !ok || m.Queue != queue {
+		return ErrNotFound
+	}
+
+	return b.store.MDelete(ctx, []string{msgKey(id), timerKey(id)})
+}
+
+// Nack - makes message visible right away, or moves it to dead letter queue if it has been delivered too many times.
+// Message, that has never been delivered, keeps its delay.
+func (b *Broker) Nack(ctx context.Context, queue string, id string) error {
+	m, ok := b.store.Get(ctx, msgKey(id))
+	if !ok || m.Queue != queue {
+		return ErrNotFound
+	}
+
+	if m.Deliveries == 0 {
+		return ErrNotDelivered
+	}
+
+	if err := b.store.Delete(ctx, timerKey(id)); err != nil {
+		return err
+	}
+
+	return b.release(ctx, m)
+}
+
+// release - returns message to its queue, or moves it to dead letter queue
+func (b *Broker) release(ctx context.Context, m Message) error {
+	if b.cfg.MaxDeliveries > 0 && m.Deliveries >= b.cfg.MaxDeliveries && !isDeadLetter(m.Queue) {
+		b.logger.Infof("Message %s delivered %d times, moving to dead letter queue", m.ID, m.Deliveries)
+
+		m.Queue += DEAD_LETTER_SUFFIX
+		m.Deliveries = 0
+		if err := b.store.Set(ctx, msgKey(m.ID), m, -1); err != nil {
+			return err
+		}
+	}
+
+	b.push(m.Queue, m.ID)
+	return nil
+}
+
+// recover - makes visible every message without timer, in order they were enqueued
+func (b *Broker) recover() {
+	msgs := make([]Message, 0)
+	snap := b.store.Snapshot()
+	snap.RangeBetween(msgPrefix, "", func(key string, m Message) bool {
+		if !strings.HasPrefix(key, msgPrefix) {
+			return false
+		}
+
+		if _, ok := snap.Get(timerKey(m.ID)); !ok {
+			msgs = append(msgs, m)
+		}
+		return true
+	})
+
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].Enqueued < msgs[j].Enqueued
+	})
+
+	for _, m := range msgs {
+		if err := b.release(b.ctx, m); err != nil {
+			b.logger.Errorf("got error while recovering message: %s", err.Error())
+		}
+	}
+}
+
+// Run - restores visible messages from store and starts watching timers.
+// WARNING: store shoud be loaded before Run
+func (b *Broker) Run() {
+	sub := b.watch()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			select {
+			case <-b.ctx.Done():
+				sub.Close()
+				return
+			case <-sub.Done():
+				// Too many timers expired at once, some expirations can be lost
+				b.logger.Warn("Timers subscription overflowed. Resubscribing.")
+				sub.Close()
+				sub = b.watch()
+			case e := <-sub.Events():
+				if e.Type != ttlstore.EventExpire {
+					continue
+				}
+
+				m, ok := b.store.Get(b.ctx, msgKey(strings.TrimPrefix(e.Key, timerPrefix)))
+				if !ok {
+					continue
+				}
+
+				if err := b.release(b.ctx, m); err != nil {
+					b.logger.Errorf("got error while releasing message: %s", err.Error())
+				}
+			}
+		}
+	}()
+
+	b.logger.Info("Running...")
+}
+
+// watch - subscribes to timers, then recovers messages whose timers are gone
+func (b *Broker) watch() *ttlstore.Subscription[string, Message] {
+	sub := b.store.Watch(ttlstore.WatchConfig[string]{
+		Prefix: timerPrefix,
+		Buffer: watchBuffer,
+		Policy: ttlstore.Disconnect,
+	})
+
+	b.recover()
+	return sub
+}
+
+func (b *Broker) Stop() {
+	b.logger.Info("Stoping...")
+	b.cancel()
+	b.wg.Wait()
+}
@@ -0,0 +1,19 @@
+package queue
+
+import (
+	"time"
+)
+
+type QueueConfig struct {
+	VisibilityTimeout time.Duration `yaml:"visibility-timeout"`
+	MaxDeliveries     int           `yaml:"max-deliveries"`
+	MaxWait           time.Duration `yaml:"max-wait"`
+}
+
+func newQueueConfig(VisibilityTimeout time.Duration, MaxDeliveries int, MaxWait time.Duration) QueueConfig {
+	return QueueConfig{
+		VisibilityTimeout: VisibilityTimeout,
+		MaxDeliveries:     MaxDeliveries,
+		MaxWait:           MaxWait,
+	}
+}
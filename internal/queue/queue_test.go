@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+func TestMain(m *testing.M) {
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(os.Stdout)
+	os.Exit(m.Run())
+}
+
+func newTestBroker(t *testing.T, path string, save bool, cfg QueueConfig) (*Broker, *ttlstore.MapStore[string, Message]) {
+	store := ttlstore.NewMapStore[string, Message](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, path, save))
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBroker(context.Background(), store, logger.WithField("test", t.Name()), cfg)
+	b.Run()
+	return b, store
+}
+
+func dequeue(t *testing.T, b *Broker, queue string, wait time.Duration) (Message, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+
+	m, err := b.Dequeue(ctx, queue, time.Second)
+	if err == context.DeadlineExceeded {
+		return m, false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return m, true
+}
+
+func TestQueueAck(t *testing.T) {
+	ctx := context.Background()
+
+	b, store := newTestBroker(t, "", false, newQueueConfig(time.Second, 3, time.Second))
+	defer store.Close()
+	defer b.Stop()
+
+	id, err := b.Enqueue(ctx, "jobs", "hello", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delayed, err := b.Enqueue(ctx, "jobs", "later", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := dequeue(t, b, "jobs", time.Second)
+	if !ok || m.ID != id || m.Payload != "hello" || m.Deliveries != 1 {
+		t.Fatalf("Unexpected message: %+v", m)
+	}
+
+	if err := b.Ack(ctx, "other", id); err != ErrNotFound {
+		t.Errorf("Want ErrNotFound for message of other queue, got: %v", err)
+	}
+
+	if err := b.Ack(ctx, "jobs", id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Ack(ctx, "jobs", id); err != ErrNotFound {
+		t.Errorf("Want ErrNotFound, got: %v", err)
+	}
+
+	// Delayed message can not be made visible by nack
+	if err := b.Nack(ctx, "jobs", delayed); err != ErrNotDelivered {
+		t.Errorf("Want ErrNotDelivered, got: %v", err)
+	}
+
+	// Delayed message is not visible right away
+	if m, ok := dequeue(t, b, "jobs", time.Second/2); ok {
+		t.Fatalf("Want nothing, got: %+v", m)
+	}
+
+	m, ok = dequeue(t, b, "jobs", time.Second*3)
+	if !ok || m.ID != delayed {
+		t.Fatalf("Want delayed message, got: %+v", m)
+	}
+}
+
+func TestQueueRedeliveryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+
+	b, store := newTestBroker(t, "", false, newQueueConfig(time.Second, 2, time.Second))
+	defer store.Close()
+	defer b.Stop()
+
+	id, err := b.Enqueue(ctx, "jobs", "fail", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First delivery is nacked, second one times out
+	if m, ok := dequeue(t, b, "jobs", time.Second); !ok || m.ID != id {
+		t.Fatalf("Unexpected message: %+v", m)
+	}
+
+	if err := b.Nack(ctx, "jobs", id); err != nil {
+		t.Fatal(err)
+	}
+
+	if m, ok := dequeue(t, b, "jobs", time.Second); !ok || m.ID != id || m.Deliveries != 2 {
+		t.Fatalf("Unexpected message: %+v", m)
+	}
+
+	if m, ok := dequeue(t, b, "jobs", time.Second*3); ok {
+		t.Fatalf("Want nothing, got: %+v", m)
+	}
+
+	m, ok := dequeue(t, b, "jobs"+DEAD_LETTER_SUFFIX, time.Second)
+	if !ok || m.ID != id || m.Queue != "jobs"+DEAD_LETTER_SUFFIX {
+		t.Fatalf("Want message in dead letter queue, got: %+v", m)
+	}
+}
+
+func TestQueueRestore(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#queue.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := newQueueConfig(time.Minute, 3, time.Second)
+
+	b, store := newTestBroker(t, filename, true, cfg)
+
+	ready, _ := b.Enqueue(ctx, "jobs", "ready", 0)
+	b.Enqueue(ctx, "jobs", "delayed", time.Minute)
+	b.Enqueue(ctx, "jobs", "acked", 0)
+
+	if m, ok := dequeue(t, b, "jobs", time.Second); !ok || m.ID != ready {
+		t.Fatalf("Unexpected message: %+v", m)
+	}
+
+	if m, ok := dequeue(t, b, "jobs", time.Second); !ok {
+		t.Fatal("Want message")
+	} else if err := b.Ack(ctx, "jobs", m.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Stop()
+	store.Close()
+
+	// In flight and delayed messages keep their timers after restart
+	b, store = newTestBroker(t, filename, true, cfg)
+	defer store.Close()
+	defer b.Stop()
+
+	if m, ok := dequeue(t, b, "jobs", time.Second); ok {
+		t.Fatalf("Want nothing, got: %+v", m)
+	}
+}
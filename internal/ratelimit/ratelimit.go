@@ -0,0 +1,236 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRoute - routes without own rule share buckets of default rule
+const defaultRoute = "*"
+
+var ErrInvalidLimit = errors.New("limit must have positive requests and per, or zero requests to disable it")
+
+// State - token bucket of client. Bucket expires, when it would be full again, missing bucket is full.
+type State struct {
+	Tokens float64
+	// Updated - unix nano time, when tokens were counted
+	Updated int64
+}
+
+// Result - result of request to take token
+type Result struct {
+	Allowed bool
+	// Limit - size of bucket
+	Limit int
+	// Remaining - whole tokens left in bucket
+	Remaining int
+	// Reset - time, after which bucket is full again
+	Reset time.Duration
+	// RetryAfter - time, after which next token is available, zero when request is allowed
+	RetryAfter time.Duration
+}
+
+func (l Limit) valid() bool {
+	return l.Requests == 0 || (l.Requests > 0 && l.Per > 0)
+}
+
+// rate - tokens per nanosecond
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / float64(l.Per)
+}
+
+// Limiter - token buckets of clients, stored in ttlstore bucket, so they are kept over restart and expire when full.
+// Buckets are changed in memory and written to store every cfg.Flush, so store gets one write per client
+// in period, not one per request.
+type Limiter struct {
+	store  *ttlstore.MapStore[string, State]
+	cfg    RateLimitConfig
+	logger *logrus.Entry
+	now    func() time.Time
+
+	mu *sync.Mutex
+	// pending - buckets, that are changed since last flush, guarded by mu
+	pending map[string]pendingState
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+// pendingState - bucket, that is not written to store yet
+type pendingState struct {
+	state   State
+	expires time.Time
+}
+
+func NewLimiter(ctx context.Context, store *ttlstore.MapStore[string, State], logger *logrus.Entry, cfg RateLimitConfig) (*Limiter, error) {
+	if !cfg.Default.IP.valid() || !cfg.Default.Key.valid() {
+		return nil, fmt.Errorf("default rule: %w", ErrInvalidLimit)
+	}
+
+	for route, r := range cfg.Routes {
+		if !r.IP.valid() || !r.Key.valid() {
+			return nil, fmt.Errorf("rule of %q: %w", route, ErrInvalidLimit)
+		}
+	}
+
+	if cfg.Flush <= 0 {
+		cfg.Flush = DEFAULT_FLUSH
+	}
+
+	l := &Limiter{
+		store:   store,
+		cfg:     cfg,
+		logger:  logger,
+		now:     time.Now,
+		mu:      &sync.Mutex{},
+		pending: make(map[string]pendingState),
+		wg:      &sync.WaitGroup{},
+	}
+
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	return l, nil
+}
+
+// Enabled - requests are limited
+func (l *Limiter) Enabled() bool {
+	return l.cfg.Enabled
+}
+
+// rule - rule of route, routes without own rule share buckets of default rule
+func (l *Limiter) rule(route string) (Rule, string) {
+	if rule, ok := l.cfg.Routes[route]; ok {
+		return rule, route
+	}
+	return l.cfg.Default, defaultRoute
+}
+
+// TakeIP - takes token of ip from bucket of route. Every request is limited by ip, before its api key is checked.
+// Returns false, when route does not limit ips.
+func (l *Limiter) TakeIP(ctx context.Context, route string, ip string) (Result, bool, error) {
+	rule, route := l.rule(route)
+	return l.takeLimit(ctx, route+" ip:"+ip, rule.IP)
+}
+
+// TakeKey - takes token of authenticated api key from bucket of route. Returns false, when route does not limit keys.
+func (l *Limiter) TakeKey(ctx context.Context, route string, key string) (Result, bool, error) {
+	rule, route := l.rule(route)
+	return l.takeLimit(ctx, route+" key:"+key, rule.Key)
+}
+
+func (l *Limiter) takeLimit(ctx context.Context, bucket string, limit Limit) (Result, bool, error) {
+	if limit.Requests == 0 {
+		return Result{}, false, nil
+	}
+
+	res, err := l.take(ctx, bucket, limit)
+	return res, true, err
+}
+
+// take - refills bucket by time passed since last request, then takes one token from it
+func (l *Limiter) take(ctx context.Context, bucket string, limit Limit) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+
+	res := Result{Limit: limit.Requests}
+
+	p, ok := l.pending[bucket]
+	st := p.state
+	if !ok {
+		if st, ok = l.store.Get(ctx, bucket); !ok {
+			st = State{Tokens: capacity, Updated: now.UnixNano()}
+		}
+	}
+
+	if elapsed := now.UnixNano() - st.Updated; elapsed > 0 {
+		st.Tokens = math.Min(capacity, st.Tokens+float64(elapsed)*rate)
+	}
+	st.Updated = now.UnixNano()
+
+	if st.Tokens >= 1 {
+		st.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - st.Tokens) / rate))
+	}
+
+	res.Remaining = int(st.Tokens)
+	res.Reset = time.Duration(math.Ceil((capacity - st.Tokens) / rate))
+
+	// Ttl of store is counted in whole seconds, bucket, that expires later than it is full, is still correct
+	l.pending[bucket] = pendingState{state: st, expires: now.Add(res.Reset + time.Second)}
+	return res, nil
+}
+
+// flush - writes pending buckets to store in one update. Bucket, that is full again, is not written.
+func (l *Limiter) flush(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) == 0 {
+		return nil
+	}
+
+	now := l.now()
+	err := l.store.Update(ctx, func(_ func(key string) (State, bool), tx *ttlstore.Tx[string, State]) error {
+		for bucket, p := range l.pending {
+			if ttl := p.expires.Sub(now); ttl > 0 {
+				tx.Set(bucket, p.state, ttl)
+			}
+		}
+		return nil
+	})
+
+	// Buckets are kept in memory, until they are written
+	if err == nil {
+		l.pending = make(map[string]pendingState)
+	}
+	return err
+}
+
+// Listen - writes pending buckets every cfg.Flush until ctx is done, then writes buckets, that are left
+func (l *Limiter) Listen(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(l.cfg.Flush)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.flush(ctx); err != nil {
+				l.logger.Errorf("got error while writing rate limit buckets: %s", err.Error())
+			}
+		case <-ctx.Done():
+			if err := l.flush(context.Background()); err != nil {
+				l.logger.Errorf("got error while writing rate limit buckets: %s", err.Error())
+			}
+			return
+		}
+	}
+}
+
+func (l *Limiter) Run() {
+	l.wg.Add(1)
+	go l.Listen(l.ctx, l.wg)
+
+	l.logger.Info("Running...")
+}
+
+// Stop - stops flushing, pending buckets are written to store
+func (l *Limiter) Stop() {
+	l.logger.Info("Stoping...")
+	l.cancel()
+	l.wg.Wait()
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+const DEFAULT_FLUSH = time.Second
+
+// Limit - token bucket: Requests tokens, that are refilled evenly during Per. Zero Requests disables limit.
+type Limit struct {
+	// Requests - max number of requests in burst
+	Requests int `yaml:"requests"`
+	// Per - time, in which empty bucket is refilled
+	Per time.Duration `yaml:"per"`
+}
+
+// Rule - limits of route. Every request is limited by IP, requests with valid api key are limited by Key too.
+type Rule struct {
+	IP  Limit `yaml:"ip"`
+	Key Limit `yaml:"key"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Flush - period, after which changed buckets are written to store. Changes of last period are lost on crash
+	Flush time.Duration `yaml:"flush"`
+	// Default - rule of routes without own rule. Its buckets are shared by all such routes.
+	Default Rule `yaml:"default"`
+	// Routes - rules by method and route, as it is registered, for example "POST /v1/" or "GET /v1/:key"
+	Routes map[string]Rule `yaml:"routes"`
+}
+
+func newRateLimitConfig(Enabled bool, Flush time.Duration, Default Rule, Routes map[string]Rule) RateLimitConfig {
+	return RateLimitConfig{
+		Enabled: Enabled,
+		Flush:   Flush,
+		Default: Default,
+		Routes:  Routes,
+	}
+}
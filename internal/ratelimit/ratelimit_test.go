@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+func newTestStore(t *testing.T, path string, save bool) *ttlstore.MapStore[string, State] {
+	store := ttlstore.NewMapStore[string, State](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, path, save))
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func newTestLimiter(t *testing.T, cfg RateLimitConfig) (*Limiter, *time.Time, func()) {
+	store := newTestStore(t, "", false)
+
+	l, err := NewLimiter(context.Background(), store, logger.WithField("test", t.Name()), cfg)
+	if err != nil {
+		store.Close()
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	return l, &now, func() { store.Close() }
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	cfg := newRateLimitConfig(true, time.Second, Rule{IP: Limit{Requests: 3, Per: 3 * time.Second}}, nil)
+
+	l, now, stop := newTestLimiter(t, cfg)
+	defer stop()
+
+	ctx := context.Background()
+	take := func() Result {
+		res, limited, err := l.TakeIP(ctx, "GET /v1/:key", "10.0.0.1")
+		if err != nil || !limited {
+			t.Fatalf("Want limited request, got: %v, %v", limited, err)
+		}
+		return res
+	}
+
+	for i := 2; i >= 0; i-- {
+		if res := take(); !res.Allowed || res.Remaining != i || res.Limit != 3 {
+			t.Errorf("Want allowed request with %d remaining, got: %+v", i, res)
+		}
+	}
+
+	res := take()
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("Want rejected request, that can retry after 1s, got: %+v", res)
+	}
+
+	// One token is refilled per second
+	*now = now.Add(time.Second)
+	if res := take(); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Want allowed request after refill, got: %+v", res)
+	}
+
+	if res := take(); res.Allowed {
+		t.Errorf("Want rejected request, got: %+v", res)
+	}
+
+	// Bucket is never fuller than its size
+	*now = now.Add(time.Hour)
+	if res := take(); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("Want full bucket, got: %+v", res)
+	}
+
+	// Other client has own bucket
+	if res, _, _ := l.TakeIP(ctx, "GET /v1/:key", "10.0.0.2"); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("Want full bucket of other client, got: %+v", res)
+	}
+}
+
+func TestRateLimitRules(t *testing.T) {
+	cfg := newRateLimitConfig(true, time.Second, Rule{IP: Limit{Requests: 1, Per: time.Minute}}, map[string]Rule{
+		"POST /v1/": {
+			IP:  Limit{Requests: 2, Per: time.Minute},
+			Key: Limit{Requests: 5, Per: time.Minute},
+		},
+		"GET /v1/:key": {},
+	})
+
+	l, _, stop := newTestLimiter(t, cfg)
+	defer stop()
+
+	ctx := context.Background()
+
+	if res, limited, _ := l.TakeIP(ctx, "POST /v1/", "10.0.0.1"); !limited || res.Limit != 2 {
+		t.Errorf("Want ip limit of route, got: %+v", res)
+	}
+
+	// Request with api key is limited by ip and by key, ip bucket is not used by key
+	if res, limited, _ := l.TakeKey(ctx, "POST /v1/", "ci"); !limited || res.Limit != 5 || res.Remaining != 4 {
+		t.Errorf("Want key limit of route, got: %+v", res)
+	}
+
+	if res, _, _ := l.TakeIP(ctx, "POST /v1/", "10.0.0.1"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Want ip bucket with 0 remaining, got: %+v", res)
+	}
+
+	// Route with empty rule is not limited
+	if _, limited, _ := l.TakeIP(ctx, "GET /v1/:key", "10.0.0.1"); limited {
+		t.Error("Want route without ip limit")
+	}
+
+	if _, limited, _ := l.TakeKey(ctx, "GET /v1/:key", "ci"); limited {
+		t.Error("Want route without key limit")
+	}
+
+	// Routes without rule share default bucket, default rule does not limit keys
+	if _, limited, _ := l.TakeKey(ctx, "GET /v1/keys", "ci"); limited {
+		t.Error("Want default rule without key limit")
+	}
+
+	if res, _, _ := l.TakeIP(ctx, "GET /v1/keys", "10.0.0.1"); !res.Allowed || res.Limit != 1 {
+		t.Errorf("Want allowed request by default ip limit, got: %+v", res)
+	}
+
+	if res, _, _ := l.TakeIP(ctx, "DELETE /v1/:key", "10.0.0.1"); res.Allowed {
+		t.Errorf("Want default bucket to be shared, got: %+v", res)
+	}
+
+	for _, r := range []Rule{
+		{IP: Limit{Requests: 1}},
+		{Key: Limit{Requests: -1, Per: time.Second}},
+	} {
+		if _, err := NewLimiter(context.Background(), nil, nil, newRateLimitConfig(true, time.Second, Rule{}, map[string]Rule{"GET /": r})); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("Want ErrInvalidLimit for %+v, got: %v", r, err)
+		}
+	}
+}
+
+func TestRateLimitExpire(t *testing.T) {
+	cfg := newRateLimitConfig(true, time.Second, Rule{IP: Limit{Requests: 1, Per: time.Second}}, nil)
+
+	store := newTestStore(t, "", false)
+	defer store.Close()
+
+	l, err := NewLimiter(context.Background(), store, logger.WithField("test", t.Name()), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, _, _ := l.TakeIP(context.Background(), "GET /", "10.0.0.1"); !res.Allowed {
+		t.Errorf("Want allowed request, got: %+v", res)
+	}
+
+	// Bucket is written on flush, not on request
+	if keys, _ := store.Scan("", "", 10); len(keys) != 0 {
+		t.Errorf("Want bucket not written before flush, got: %v", keys)
+	}
+
+	if err := l.flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, _ := store.Scan("", "", 10); len(keys) != 1 {
+		t.Errorf("Want bucket of client, got: %v", keys)
+	}
+
+	// Bucket is dropped, when it is full again
+	time.Sleep(3 * time.Second)
+	if keys, _ := store.Scan("", "", 10); len(keys) != 0 {
+		t.Errorf("Want full bucket to expire, got: %v", keys)
+	}
+}
+
+func TestRateLimitPersist(t *testing.T) {
+	cfg := newRateLimitConfig(true, time.Hour, Rule{IP: Limit{Requests: 2, Per: time.Minute}}, nil)
+
+	filename := "#ratelimit_test.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	ctx := context.Background()
+
+	store := newTestStore(t, filename, true)
+	l, err := NewLimiter(ctx, store, logger.WithField("test", t.Name()), cfg)
+	if err != nil {
+		store.Close()
+		t.Fatal(err)
+	}
+	l.Run()
+
+	for i := 0; i < 2; i++ {
+		if res, _, _ := l.TakeIP(ctx, "GET /", "10.0.0.1"); !res.Allowed {
+			t.Errorf("Want allowed request, got: %+v", res)
+		}
+	}
+
+	// Flush period is not passed, buckets are written on stop
+	l.Stop()
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = newTestStore(t, filename, true)
+	defer store.Close()
+
+	l, err = NewLimiter(ctx, store, logger.WithField("test", t.Name()), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, _, _ := l.TakeIP(ctx, "GET /", "10.0.0.1"); res.Allowed {
+		t.Errorf("Want empty bucket after restart, got: %+v", res)
+	}
+}
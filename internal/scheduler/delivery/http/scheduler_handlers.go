@@ -0,0 +1,173 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/BON4/timedQ/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type scheduleRequest struct {
+	URL         string `json:"url" binding:"required"`
+	Payload     string `json:"payload"`
+	ContentType string `json:"content_type"`
+	// Delay - duration string, for example 15m. Ignored if At is set
+	Delay string `json:"delay"`
+	// At - RFC3339 time, when webhook shoud be sent
+	At string `json:"at"`
+}
+
+type scheduleResponse struct {
+	ID string `json:"id"`
+}
+
+type attemptResponse struct {
+	Time   int64  `json:"time"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type jobResponse struct {
+	ID          string            `json:"id"`
+	URL         string            `json:"url"`
+	Payload     string            `json:"payload"`
+	ContentType string            `json:"content_type"`
+	DueAt       int64             `json:"due_at"`
+	State       string            `json:"state"`
+	Attempts    []attemptResponse `json:"attempts"`
+}
+
+type schedulerHandler struct {
+	logger *logrus.Entry
+	sched  *scheduler.Scheduler
+}
+
+// delay - time left until At, or parsed Delay
+func (r *scheduleRequest) delay() (time.Duration, error) {
+	if r.At != "" {
+		at, err := time.Parse(time.RFC3339, r.At)
+		if err != nil {
+			return 0, err
+		}
+		return time.Until(at), nil
+	}
+
+	if r.Delay == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.Delay)
+}
+
+// @Summary      Schedule
+// @Description  schedules POST of payload to url after delay or at set time.
+// @Description  Failed deliveries are retried with exponential backoff.
+// @Description  Urls on blocked hosts (scheduler.blocked-hosts), loopback and private addresses are rejected
+// @Tags         schedules
+// @Accept       json
+// @Produce      json
+// @Param        input  body      scheduleRequest  true  "webhook"
+// @Success      200    {object}  scheduleResponse
+// @Failure      400    {object}  error
+// @Failure      500    {object}  error
+// @Router       /schedules [post]
+func (s *schedulerHandler) Schedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &scheduleRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		delay, err := req.delay()
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		id, err := s.sched.Schedule(c.Request.Context(), req.URL, req.Payload, req.ContentType, delay)
+		if errors.Is(err, scheduler.ErrInvalidURL) || errors.Is(err, scheduler.ErrHostBlocked) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, scheduleResponse{
+			ID: id,
+		})
+	}
+}
+
+// @Summary      Get schedule
+// @Description  returns job with its delivery log
+// @Tags         schedules
+// @Produce      json
+// @Param        id   path      string  true  "job id"
+// @Success      200  {object}  jobResponse
+// @Failure      404  {object}  error
+// @Router       /schedules/{id} [get]
+func (s *schedulerHandler) Get() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := s.sched.Get(c.Request.Context(), c.Param("id"))
+		if !ok {
+			c.AbortWithError(http.StatusNotFound, scheduler.ErrNotFound)
+			return
+		}
+
+		resp := jobResponse{
+			ID:          job.ID,
+			URL:         job.URL,
+			Payload:     job.Payload,
+			ContentType: job.ContentType,
+			DueAt:       job.DueAt,
+			State:       job.State.String(),
+			Attempts:    make([]attemptResponse, len(job.Attempts)),
+		}
+
+		for i, a := range job.Attempts {
+			resp.Attempts[i] = attemptResponse{
+				Time:   a.Time,
+				Status: a.Status,
+				Error:  a.Error,
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// @Summary      Cancel schedule
+// @Description  cancels job, that has not been delivered yet
+// @Tags         schedules
+// @Param        id   path  string  true  "job id"
+// @Success      204
+// @Failure      404  {object}  error
+// @Failure      409  {object}  error
+// @Failure      500  {object}  error
+// @Router       /schedules/{id} [delete]
+func (s *schedulerHandler) Cancel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := s.sched.Cancel(c.Request.Context(), c.Param("id"))
+		switch {
+		case err == nil:
+			c.Status(http.StatusNoContent)
+		case errors.Is(err, scheduler.ErrNotFound):
+			c.AbortWithError(http.StatusNotFound, err)
+		case errors.Is(err, scheduler.ErrNotPending):
+			c.AbortWithError(http.StatusConflict, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+	}
+}
+
+func NewSchedulerHandler(sched *scheduler.Scheduler, logger *logrus.Entry) *schedulerHandler {
+	return &schedulerHandler{
+		logger: logger,
+		sched:  sched,
+	}
+}
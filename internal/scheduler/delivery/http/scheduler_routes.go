@@ -0,0 +1,9 @@
+package http
+
+import "github.com/gin-gonic/gin"
+
+func NewSchedulerRoutes(group *gin.RouterGroup, h *schedulerHandler) {
+	group.POST("", h.Schedule())
+	group.GET("/:id", h.Get())
+	group.DELETE("/:id", h.Cancel())
+}
@@ -0,0 +1,459 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type JobState uint8
+
+const (
+	JobScheduled JobState = iota
+	JobDelivered
+	JobFailed
+	JobCancelled
+)
+
+func (js JobState) String() string {
+	switch js {
+	case JobScheduled:
+		return "scheduled"
+	case JobDelivered:
+		return "delivered"
+	case JobFailed:
+		return "failed"
+	case JobCancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+const (
+	jobPrefix = "job:"
+	duePrefix = "due:"
+
+	watchBuffer = 1024
+)
+
+var (
+	ErrNotFound    = errors.New("job not found")
+	ErrNotPending  = errors.New("job is not scheduled anymore")
+	ErrInvalidURL  = errors.New("url must be absolute http or https url")
+	ErrHostBlocked = errors.New("host of url is blocked")
+)
+
+// Attempt - single delivery of webhook
+type Attempt struct {
+	// Time - unix nano time of attempt
+	Time   int64
+	Status int
+	Error  string
+}
+
+// Job - webhook, that is sent when job is due. Attempts is delivery log, saved together with job.
+type Job struct {
+	ID          string
+	URL         string
+	Payload     string
+	ContentType string
+	DueAt       int64
+	State       JobState
+	Attempts    []Attempt
+}
+
+// Scheduler - webhooks on top of ttlstore. Every job is stored under job:<id> key,
+// while job waits for delivery or retry, it also has due:<id> key with ttl. When due key expires, webhook is sent.
+// Finished jobs are kept for retention time, so delivery log can be inspected.
+type Scheduler struct {
+	store  *ttlstore.MapStore[string, Job]
+	cfg    SchedulerConfig
+	logger *logrus.Entry
+	client *http.Client
+
+	// mu - serializes changes of job state
+	mu *sync.Mutex
+	// inflight - jobs, that are being delivered right now
+	inflight map[string]struct{}
+	sema     chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+func NewScheduler(ctx context.Context, store *ttlstore.MapStore[string, Job], logger *logrus.Entry, cfg SchedulerConfig) *Scheduler {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = DEFAULT_BACKOFF_BASE
+	}
+
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = DEFAULT_BACKOFF_MAX
+	}
+
+	if cfg.BackoffMax < cfg.BackoffBase {
+		cfg.BackoffMax = cfg.BackoffBase
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DEFAULT_TIMEOUT
+	}
+
+	// Zero ttl does not change entry in store, so finished jobs would stay scheduled
+	if cfg.Retention <= 0 {
+		cfg.Retention = DEFAULT_RETENTION
+	}
+
+	s := &Scheduler{
+		store:    store,
+		cfg:      cfg,
+		logger:   logger,
+		mu:       &sync.Mutex{},
+		inflight: make(map[string]struct{}),
+		sema:     make(chan struct{}, cfg.Workers),
+		wg:       &sync.WaitGroup{},
+	}
+
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: s.checkAddr,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	s.client = &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return s.checkHost(req.URL.Hostname())
+		},
+	}
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s
+}
+
+// privateIP - loopback, private, link local or unspecified address, that webhook must not reach from outside
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// checkHost - checks, that host is not blocked and, if it is ip address, that it is public
+func (s *Scheduler) checkHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, blocked := range s.cfg.BlockedHosts {
+		blocked = strings.ToLower(blocked)
+		if host == blocked || strings.HasSuffix(host, "."+blocked) {
+			return ErrHostBlocked
+		}
+	}
+
+	if s.cfg.AllowPrivate {
+		return nil
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrHostBlocked
+	}
+
+	if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+		return ErrHostBlocked
+	}
+	return nil
+}
+
+// checkAddr - checks address, that names of webhook hosts are resolved to, right before connecting,
+// so host can not pass Schedule with public address and resolve to private one later
+func (s *Scheduler) checkAddr(_ string, address string, _ syscall.RawConn) error {
+	if s.cfg.AllowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return ErrHostBlocked
+	}
+	return nil
+}
+
+func jobKey(id string) string {
+	return jobPrefix + id
+}
+
+func dueKey(id string) string {
+	return duePrefix + id
+}
+
+// Schedule - schedules POST of payload to url after delay
+func (s *Scheduler) Schedule(ctx context.Context, rawURL string, payload string, contentType string, delay time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	if err := s.checkHost(u.Hostname()); err != nil {
+		return "", err
+	}
+
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	job := Job{
+		ID:          uuid.New().String(),
+		URL:         rawURL,
+		Payload:     payload,
+		ContentType: contentType,
+		DueAt:       time.Now().Add(delay).Unix(),
+		State:       JobScheduled,
+	}
+
+	tx := s.store.Multi().Set(jobKey(job.ID), job, -1)
+	if delay > 0 {
+		tx.Set(dueKey(job.ID), Job{ID: job.ID}, delay)
+	}
+
+	if err := tx.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	if delay <= 0 {
+		s.dispatch(job.ID)
+	}
+
+	return job.ID, nil
+}
+
+func (s *Scheduler) Get(ctx context.Context, id string) (Job, bool) {
+	return s.store.Get(ctx, jobKey(id))
+}
+
+// Cancel - cancels job, that has not been delivered yet
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.store.Get(ctx, jobKey(id))
+	if !ok {
+		return ErrNotFound
+	}
+
+	if job.State != JobScheduled {
+		return ErrNotPending
+	}
+
+	job.State = JobCancelled
+
+	return s.store.Multi().
+		Delete(dueKey(id)).
+		Set(jobKey(id), job, s.cfg.Retention).
+		Exec(ctx)
+}
+
+// backoff - delay before next attempt, doubles with every failed attempt
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	d := s.cfg.BackoffBase
+	for i := 1; i < attempts && d < s.cfg.BackoffMax; i++ {
+		d *= 2
+	}
+
+	if d > s.cfg.BackoffMax {
+		d = s.cfg.BackoffMax
+	}
+	return d
+}
+
+// dispatch - delivers job in background, number of concurrent deliveries is limited by cfg.Workers
+func (s *Scheduler) dispatch(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inflight[id]; ok {
+		return
+	}
+	s.inflight[id] = struct{}{}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, id)
+			s.mu.Unlock()
+		}()
+
+		select {
+		case s.sema <- struct{}{}:
+		case <-s.ctx.Done():
+			return
+		}
+		defer func() { <-s.sema }()
+
+		s.deliver(id)
+	}()
+}
+
+func (s *Scheduler) deliver(id string) {
+	job, ok := s.store.Get(s.ctx, jobKey(id))
+	if !ok || job.State != JobScheduled {
+		return
+	}
+
+	attempt := s.post(job)
+	if s.ctx.Err() != nil {
+		// Scheduler is stopping, job without due key will be recovered on next Run
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Job can be cancelled, while webhook was sending
+	job, ok = s.store.Get(s.ctx, jobKey(id))
+	if !ok {
+		return
+	}
+
+	job.Attempts = append(job.Attempts, attempt)
+
+	tx := s.store.Multi()
+	switch {
+	case job.State != JobScheduled:
+		tx.Set(jobKey(id), job, s.cfg.Retention)
+	case attempt.Error == "":
+		job.State = JobDelivered
+		tx.Set(jobKey(id), job, s.cfg.Retention)
+	case len(job.Attempts) >= s.cfg.MaxAttempts:
+		s.logger.Warnf("Job %s failed after %d attempts: %s", id, len(job.Attempts), attempt.Error)
+		job.State = JobFailed
+		tx.Set(jobKey(id), job, s.cfg.Retention)
+	default:
+		tx.Set(jobKey(id), job, -1)
+		tx.Set(dueKey(id), Job{ID: id}, s.backoff(len(job.Attempts)))
+	}
+
+	if err := tx.Exec(s.ctx); err != nil {
+		s.logger.Errorf("got error while saving job: %s", err.Error())
+	}
+}
+
+// post - sends webhook once, non 2xx status is an error
+func (s *Scheduler) post(job Job) Attempt {
+	attempt := Attempt{
+		Time: time.Now().UnixNano(),
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, job.URL, bytes.NewBufferString(job.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req.Header.Set("Content-Type", job.ContentType)
+	req.Header.Set("X-Schedule-Id", job.ID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.Status = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status: %d", resp.StatusCode)
+	}
+	return attempt
+}
+
+// recover - dispatches every scheduled job without due key
+func (s *Scheduler) recover() {
+	snap := s.store.Snapshot()
+	snap.RangeBetween(jobPrefix, "", func(key string, job Job) bool {
+		if !strings.HasPrefix(key, jobPrefix) {
+			return false
+		}
+
+		if _, ok := snap.Get(dueKey(job.ID)); !ok && job.State == JobScheduled {
+			s.dispatch(job.ID)
+		}
+		return true
+	})
+}
+
+// Run - dispatches jobs, that became due while scheduler was stopped, and starts watching due keys.
+// WARNING: store shoud be loaded before Run
+func (s *Scheduler) Run() {
+	sub := s.watch()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.ctx.Done():
+				sub.Close()
+				return
+			case <-sub.Done():
+				// Too many jobs became due at once, some expirations can be lost
+				s.logger.Warn("Due subscription overflowed. Resubscribing.")
+				sub.Close()
+				sub = s.watch()
+			case e := <-sub.Events():
+				if e.Type == ttlstore.EventExpire {
+					s.dispatch(strings.TrimPrefix(e.Key, duePrefix))
+				}
+			}
+		}
+	}()
+
+	s.logger.Info("Running...")
+}
+
+// watch - subscribes to due keys, then recovers jobs whose due keys are gone
+func (s *Scheduler) watch() *ttlstore.Subscription[string, Job] {
+	sub := s.store.Watch(ttlstore.WatchConfig[string]{
+		Prefix: duePrefix,
+		Buffer: watchBuffer,
+		Policy: ttlstore.Disconnect,
+	})
+
+	s.recover()
+	return sub
+}
+
+func (s *Scheduler) Stop() {
+	s.logger.Info("Stoping...")
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"time"
+)
+
+const (
+	DEFAULT_MAX_ATTEMPTS = 5
+	DEFAULT_BACKOFF_BASE = time.Second
+	DEFAULT_BACKOFF_MAX  = 5 * time.Minute
+	DEFAULT_TIMEOUT      = 10 * time.Second
+	DEFAULT_RETENTION    = 24 * time.Hour
+)
+
+type SchedulerConfig struct {
+	MaxAttempts int           `yaml:"max-attempts"`
+	BackoffBase time.Duration `yaml:"backoff-base"`
+	// BackoffMax - max delay between attempts, it is never less than BackoffBase
+	BackoffMax time.Duration `yaml:"backoff-max"`
+	Timeout    time.Duration `yaml:"timeout"`
+	// Retention - time, finished jobs are kept for
+	Retention time.Duration `yaml:"retention"`
+	Workers   int           `yaml:"workers"`
+	// BlockedHosts - webhooks to these hosts and their subdomains are rejected, for example host of service itself
+	BlockedHosts []string `yaml:"blocked-hosts"`
+	// AllowPrivate - allows webhooks to loopback, private and link local addresses
+	AllowPrivate bool `yaml:"allow-private"`
+}
+
+func newSchedulerConfig(MaxAttempts int, BackoffBase, BackoffMax, Timeout, Retention time.Duration, Workers int) SchedulerConfig {
+	return SchedulerConfig{
+		MaxAttempts: MaxAttempts,
+		BackoffBase: BackoffBase,
+		BackoffMax:  BackoffMax,
+		Timeout:     Timeout,
+		Retention:   Retention,
+		Workers:     Workers,
+	}
+}
@@ -0,0 +1,307 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+func TestMain(m *testing.M) {
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(os.Stdout)
+	os.Exit(m.Run())
+}
+
+func newTestScheduler(t *testing.T, path string, save bool, cfg SchedulerConfig) (*Scheduler, *ttlstore.MapStore[string, Job]) {
+	store := ttlstore.NewMapStore[string, Job](context.Background(), ttlstore.NewMapStoreConfig(time.Second/3, 1, path, save))
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Receivers of tests listen on loopback
+	cfg.AllowPrivate = true
+
+	s := NewScheduler(context.Background(), store, logger.WithField("test", t.Name()), cfg)
+	s.Run()
+	return s, store
+}
+
+// newReceiver - webhook receiver, that responds with 500 to first fails requests
+func newReceiver(fails int32) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Schedule-Id") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if calls.Add(1) <= fails {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return srv, calls
+}
+
+// waitState - polls job until it is in state, or timeout is reached
+func waitState(t *testing.T, s *Scheduler, id string, state JobState, timeout time.Duration) Job {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, ok := s.Get(context.Background(), id)
+		if ok && job.State == state {
+			return job
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Want job in state %s, got: %+v", state, job)
+		}
+		time.Sleep(time.Second / 10)
+	}
+}
+
+func TestSchedulerDeliver(t *testing.T) {
+	ctx := context.Background()
+
+	srv, calls := newReceiver(0)
+	defer srv.Close()
+
+	s, store := newTestScheduler(t, "", false, newSchedulerConfig(3, time.Second, time.Second*4, time.Second, time.Minute, 2))
+	defer store.Close()
+	defer s.Stop()
+
+	if _, err := s.Schedule(ctx, "ftp://example.com", "", "", 0); err != ErrInvalidURL {
+		t.Errorf("Want ErrInvalidURL, got: %v", err)
+	}
+
+	id, err := s.Schedule(ctx, srv.URL, `{"hello":"world"}`, "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second / 2)
+	if calls.Load() != 0 {
+		t.Fatal("Webhook was sent before job is due")
+	}
+
+	job := waitState(t, s, id, JobDelivered, time.Second*3)
+	if len(job.Attempts) != 1 || job.Attempts[0].Status != http.StatusOK {
+		t.Errorf("Unexpected delivery log: %+v", job.Attempts)
+	}
+
+	if err := s.Cancel(ctx, id); err != ErrNotPending {
+		t.Errorf("Want ErrNotPending, got: %v", err)
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	ctx := context.Background()
+
+	srv, calls := newReceiver(2)
+	defer srv.Close()
+
+	s, store := newTestScheduler(t, "", false, newSchedulerConfig(3, time.Second, time.Second*4, time.Second, time.Minute, 2))
+	defer store.Close()
+	defer s.Stop()
+
+	id, err := s.Schedule(ctx, srv.URL, "retry", "text/plain", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := waitState(t, s, id, JobDelivered, time.Second*8)
+	if len(job.Attempts) != 3 || calls.Load() != 3 {
+		t.Fatalf("Want 3 attempts, got: %+v", job.Attempts)
+	}
+
+	// Second retry waits twice as long as first one
+	if job.Attempts[2].Time-job.Attempts[1].Time <= job.Attempts[1].Time-job.Attempts[0].Time {
+		t.Errorf("Want exponential backoff, got: %+v", job.Attempts)
+	}
+
+	failing, _ := newReceiver(100)
+	defer failing.Close()
+
+	id, err = s.Schedule(ctx, failing.URL, "fail", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job = waitState(t, s, id, JobFailed, time.Second*8)
+	if len(job.Attempts) != 3 || job.Attempts[2].Status != http.StatusInternalServerError {
+		t.Errorf("Unexpected delivery log: %+v", job.Attempts)
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	ctx := context.Background()
+
+	srv, calls := newReceiver(0)
+	defer srv.Close()
+
+	s, store := newTestScheduler(t, "", false, newSchedulerConfig(3, time.Second, time.Second*4, time.Second, time.Minute, 2))
+	defer store.Close()
+	defer s.Stop()
+
+	id, err := s.Schedule(ctx, srv.URL, "", "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Cancel(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Cancel(ctx, "missing"); err != ErrNotFound {
+		t.Errorf("Want ErrNotFound, got: %v", err)
+	}
+
+	time.Sleep(time.Second * 2)
+	if calls.Load() != 0 {
+		t.Fatal("Cancelled webhook was sent")
+	}
+
+	waitState(t, s, id, JobCancelled, 0)
+}
+
+func TestSchedulerRestore(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#schedules.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	srv, calls := newReceiver(0)
+	defer srv.Close()
+
+	cfg := newSchedulerConfig(3, time.Second, time.Second*4, time.Second, time.Minute, 2)
+
+	s, store := newTestScheduler(t, filename, true, cfg)
+
+	delivered, _ := s.Schedule(ctx, srv.URL, "now", "", 0)
+	waitState(t, s, delivered, JobDelivered, time.Second*2)
+
+	due, _ := s.Schedule(ctx, srv.URL, "later", "", time.Second)
+
+	s.Stop()
+	store.Close()
+
+	// Job becomes due while scheduler is stopped
+	time.Sleep(time.Second * 2)
+
+	s, store = newTestScheduler(t, filename, true, cfg)
+	defer store.Close()
+	defer s.Stop()
+
+	if job := waitState(t, s, delivered, JobDelivered, 0); len(job.Attempts) != 1 {
+		t.Errorf("Delivery log was not restored: %+v", job)
+	}
+
+	waitState(t, s, due, JobDelivered, time.Second*3)
+	if calls.Load() != 2 {
+		t.Errorf("Want 2 webhooks, got: %d", calls.Load())
+	}
+}
+
+func TestSchedulerZeroConfig(t *testing.T) {
+	ctx := context.Background()
+
+	srv, calls := newReceiver(1)
+	defer srv.Close()
+
+	s, store := newTestScheduler(t, "", false, newSchedulerConfig(0, 0, 0, 0, 0, 0))
+	defer store.Close()
+	defer s.Stop()
+
+	if s.cfg.Retention != DEFAULT_RETENTION || s.cfg.BackoffBase != DEFAULT_BACKOFF_BASE || s.cfg.BackoffMax != DEFAULT_BACKOFF_MAX ||
+		s.cfg.MaxAttempts != DEFAULT_MAX_ATTEMPTS || s.cfg.Timeout != DEFAULT_TIMEOUT {
+		t.Errorf("Want defaults, got: %+v", s.cfg)
+	}
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 4: time.Second * 8, 100: DEFAULT_BACKOFF_MAX} {
+		if d := s.backoff(attempts); d != want {
+			t.Errorf("Backoff of %d attempts: want %s, got: %s", attempts, want, d)
+		}
+	}
+
+	// Failed delivery is retried after default backoff
+	delivered, err := s.Schedule(ctx, srv.URL, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job := waitState(t, s, delivered, JobDelivered, time.Second*4); len(job.Attempts) != 2 || calls.Load() != 2 {
+		t.Errorf("Want delivery on second attempt, got: %+v", job)
+	}
+
+	// Finished job is saved with default retention
+	cancelled, err := s.Schedule(ctx, srv.URL, "", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Cancel(ctx, cancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	waitState(t, s, cancelled, JobCancelled, 0)
+	if err := s.Cancel(ctx, cancelled); err != ErrNotPending {
+		t.Errorf("Want ErrNotPending, got: %v", err)
+	}
+}
+
+func TestSchedulerBackoffMax(t *testing.T) {
+	s := NewScheduler(context.Background(), nil, logger.WithField("test", t.Name()), newSchedulerConfig(3, time.Second*10, time.Second, time.Second, time.Minute, 1))
+
+	// Max less than base is raised to base
+	if d := s.backoff(3); d != time.Second*10 {
+		t.Errorf("Want 10s, got: %s", d)
+	}
+}
+
+func TestSchedulerBlockedHosts(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := newSchedulerConfig(3, time.Second, time.Second*4, time.Second, time.Minute, 1)
+	cfg.BlockedHosts = []string{"example.com"}
+
+	s := NewScheduler(ctx, nil, logger.WithField("test", t.Name()), cfg)
+
+	for _, u := range []string{
+		"http://example.com/hook",
+		"https://API.example.com./hook",
+		"http://localhost:8080/",
+		"http://127.0.0.1/",
+		"http://[::1]/",
+		"http://10.1.2.3/",
+		"http://192.168.0.1/",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/",
+	} {
+		if _, err := s.Schedule(ctx, u, "", "", 0); err != ErrHostBlocked {
+			t.Errorf("Url %s: want ErrHostBlocked, got: %v", u, err)
+		}
+	}
+
+	// Name, that resolves to private address, is rejected on connect
+	if err := s.checkAddr("tcp", "127.0.0.1:80", nil); err != ErrHostBlocked {
+		t.Errorf("Want ErrHostBlocked, got: %v", err)
+	}
+
+	if err := s.checkAddr("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("Want public address allowed, got: %v", err)
+	}
+}
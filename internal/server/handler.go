@@ -1,10 +1,23 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/BON4/timedQ/docs"
+	"github.com/BON4/timedQ/internal/auth"
+	authHttp "github.com/BON4/timedQ/internal/auth/delivery/http"
+	channelHttp "github.com/BON4/timedQ/internal/channel/delivery/http"
+	lockHttp "github.com/BON4/timedQ/internal/lock/delivery/http"
+	queueHttp "github.com/BON4/timedQ/internal/queue/delivery/http"
+	"github.com/BON4/timedQ/internal/ratelimit"
+	schedulerHttp "github.com/BON4/timedQ/internal/scheduler/delivery/http"
 	serviceHttp "github.com/BON4/timedQ/internal/service/delivery/http"
+	statsHttp "github.com/BON4/timedQ/internal/stats/delivery/http"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	swaggerfiles "github.com/swaggo/files"
@@ -49,15 +62,195 @@ func LoggerToFile(logger *logrus.Logger) gin.HandlerFunc {
 	}
 }
 
+// routeAccess - what request to route of api needs. Routes outside /v1 are public.
+// Name of request is key, or name of queue, channel or lock, request to many keys has prefix of keys or no name.
+func routeAccess(c *gin.Context) (auth.Access, bool) {
+	if !strings.HasPrefix(c.FullPath(), "/v1/") {
+		return auth.Access{}, false
+	}
+	route := strings.TrimPrefix(c.FullPath(), "/v1/")
+
+	perm := auth.PermWrite
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		perm = auth.PermRead
+	}
+
+	switch bucket, _, _ := strings.Cut(route, "/"); bucket {
+	case auth.BucketAdmin:
+		return auth.Access{Permission: auth.PermAdmin, Bucket: bucket}, true
+	case auth.BucketQueues, auth.BucketSchedules, auth.BucketChannels, auth.BucketLocks:
+		return auth.Access{Permission: perm, Bucket: bucket, Name: c.Param("name")}, true
+	}
+
+	// Name is taken only from parameter, route is served by, so scope of key can not be passed by other parameter.
+	// Batch, transaction and link creation write keys from body, they have no name.
+	var name string
+	switch route {
+	case "keys":
+		name = c.Query("prefix")
+	case "watch":
+		// Watch of single key ignores prefix
+		if name = c.Query("key"); name == "" {
+			name = c.Query("prefix")
+		}
+	case "", "batch", "tx":
+	default:
+		name = c.Param("key")
+	}
+	return auth.Access{Permission: perm, Bucket: auth.BucketKeys, Name: name}, true
+}
+
+// apiKey - token from X-API-Key or Authorization: Bearer header
+func apiKey(c *gin.Context) string {
+	if token := c.GetHeader("X-API-Key"); token != "" {
+		return token
+	}
+
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return ""
+}
+
+// Auth - lets request in, only if its api key allows access to route. Rejected requests are recorded in audit trail.
+func Auth(keyring *auth.Keyring, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !keyring.Enabled() {
+			return
+		}
+
+		access, ok := routeAccess(c)
+		if !ok {
+			return
+		}
+
+		audit := logger.WithFields(logrus.Fields{
+			"audit":  "denied",
+			"ip":     c.ClientIP(),
+			"method": c.Request.Method,
+			"route":  c.FullPath(),
+		})
+
+		token := apiKey(c)
+		key, err := keyring.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			// Only id part of token is logged, secret never is
+			id, _, _ := strings.Cut(token, ".")
+			audit.WithField("key", id).Warn(err.Error())
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(auth.ContextKey, key.ID)
+
+		if !key.Allows(access) {
+			audit.WithField("key", key.ID).Warnf("api key has no %s permission for %q in %s", access.Permission, access.Name, access.Bucket)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key is not allowed to access this route"})
+			return
+		}
+	}
+}
+
+var errRateLimited = errors.New("rate limit exceeded, retry later")
+
+// ceilSeconds - duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// rateLimit - answers request with 429, when client has used up limit of route.
+// X-RateLimit-Reset is number of seconds, after which limit is fully restored.
+func rateLimit(c *gin.Context, logger *logrus.Logger, take func(ctx context.Context, route string) (ratelimit.Result, bool, error)) {
+	route := c.Request.Method + " " + c.FullPath()
+	res, limited, err := take(c.Request.Context(), route)
+	if err != nil {
+		// Broken limiter must not make service unavailable
+		logger.Errorf("got error while taking rate limit token: %s", err.Error())
+		return
+	}
+
+	if !limited {
+		return
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("X-RateLimit-Reset", ceilSeconds(res.Reset))
+
+	if !res.Allowed {
+		c.Header("Retry-After", ceilSeconds(res.RetryAfter))
+		logger.WithFields(logrus.Fields{
+			"route": route,
+			"key":   c.GetString(auth.ContextKey),
+			"ip":    c.ClientIP(),
+		}).Warn("rate limit exceeded")
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": errRateLimited.Error()})
+	}
+}
+
+// RateLimitIP - limits every request by ip. It runs before Auth, so api keys can not be guessed without limit.
+func RateLimitIP(limiter *ratelimit.Limiter, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Enabled() {
+			return
+		}
+
+		rateLimit(c, logger, func(ctx context.Context, route string) (ratelimit.Result, bool, error) {
+			return limiter.TakeIP(ctx, route, c.ClientIP())
+		})
+	}
+}
+
+// RateLimitKey - limits request by api key, set by Auth. Request without key is limited only by ip.
+func RateLimitKey(limiter *ratelimit.Limiter, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetString(auth.ContextKey)
+		if !limiter.Enabled() || key == "" {
+			return
+		}
+
+		rateLimit(c, logger, func(ctx context.Context, route string) (ratelimit.Result, bool, error) {
+			return limiter.TakeKey(ctx, route, key)
+		})
+	}
+}
+
 func (s *Server) MapHandlers() error {
-	s.g.Use(LoggerToFile(s.logger), gin.Recovery())
+	s.g.Use(LoggerToFile(s.logger), gin.Recovery(), RateLimitIP(s.limiter, s.logger), Auth(s.keyring, s.logger), RateLimitKey(s.limiter, s.logger))
 
 	v1 := s.g.Group("/v1")
 
-	srvHand := serviceHttp.NewServiceHandler(s.wM, s.logger.WithField("service", "service-name"))
+	srvHand := serviceHttp.NewServiceHandler(s.wM, s.shortener, s.recorder, s.logger.WithField("service", "service-name"))
 
 	serviceHttp.NewServiceRoutes(v1, srvHand)
 
+	serviceHttp.NewRedirectRoutes(s.g.Group("/r"), srvHand)
+
+	statsHand := statsHttp.NewStatsHandler(s.recorder, s.logger.WithField("service", "stats"))
+
+	statsHttp.NewStatsRoutes(v1, statsHand)
+
+	queueHand := queueHttp.NewQueueHandler(s.broker, s.cfg.QueueCfg, s.logger.WithField("service", "queue"))
+
+	queueHttp.NewQueueRoutes(v1.Group("/queues"), queueHand)
+
+	schedulerHand := schedulerHttp.NewSchedulerHandler(s.sched, s.logger.WithField("service", "scheduler"))
+
+	schedulerHttp.NewSchedulerRoutes(v1.Group("/schedules"), schedulerHand)
+
+	channelHand := channelHttp.NewChannelHandler(s.hub, s.logger.WithField("service", "channel"))
+
+	channelHttp.NewChannelRoutes(v1.Group("/channels"), channelHand)
+
+	lockHand := lockHttp.NewLockHandler(s.locker, s.logger.WithField("service", "lock"))
+
+	lockHttp.NewLockRoutes(v1.Group("/locks"), lockHand)
+
+	authHand := authHttp.NewAuthHandler(s.keyring, s.logger.WithField("service", "auth"))
+
+	authHttp.NewAuthRoutes(v1.Group("/admin/keys"), authHand)
+
 	//Swagger
 	s.g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
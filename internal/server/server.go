@@ -6,11 +6,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/BON4/timedQ/internal/auth"
+	"github.com/BON4/timedQ/internal/channel"
+	"github.com/BON4/timedQ/internal/link"
+	"github.com/BON4/timedQ/internal/lock"
 	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/internal/queue"
+	"github.com/BON4/timedQ/internal/ratelimit"
+	"github.com/BON4/timedQ/internal/scheduler"
+	"github.com/BON4/timedQ/internal/stats"
 	"github.com/BON4/timedQ/pkg/ttlstore"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -43,12 +52,101 @@ func setUpLogger(fileName string) (*logrus.Logger, error) {
 	return logger, nil
 }
 
-type Server struct {
-	g      *gin.Engine
+// bucket - store with its own file, that is not served by workers
+type bucket interface {
+	Load() error
+	Run() error
+	Close() error
+}
+
+// bucketConfig - config of store, saved next to worker stores in #<name>.db file
+func bucketConfig(cfg ttlstore.TTLStoreConfig, name string) ttlstore.TTLStoreConfig {
+	cfg.SavePath = strings.TrimRight(cfg.SavePath, "/") + fmt.Sprintf("/#%s.db", name)
+	return cfg
+}
+
+// storeConfig - config of worker store, saved in #store<index>.db file
+func storeConfig(cfg ttlstore.TTLStoreConfig, index int) ttlstore.TTLStoreConfig {
+	cfg.SavePath = strings.TrimRight(cfg.SavePath, "/") + fmt.Sprintf("/#store%d.db", index)
+	return cfg
+}
+
+// storeCount - number of worker stores, that are saved in files. Zero when stores are not saved.
+func storeCount(cfg ttlstore.TTLStoreConfig) int {
+	if !cfg.Save {
+		return 0
+	}
+
+	files, err := filepath.Glob(strings.TrimRight(cfg.SavePath, "/") + "/#store*.db")
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, f := range files {
+		var index int
+		if _, err := fmt.Sscanf(filepath.Base(f), "#store%d.db", &index); err == nil && index+1 > count {
+			count = index + 1
+		}
+	}
+	return count
+}
+
+// storeProvider - creates worker stores, when workers are added at runtime, and removes files of retired stores
+type storeProvider struct {
+	ctx    context.Context
+	cfg    ttlstore.TTLStoreConfig
 	logger *logrus.Logger
-	wM     *manager.WorkerManager
-	cfg    ServerConfig
-	stores []*ttlstore.MapStore[string, string]
+}
+
+func (p *storeProvider) Create(index int) (*ttlstore.MapStore[string, string], error) {
+	cfg := storeConfig(p.cfg, index)
+	p.logger.Infof("Creating db file in: %s", cfg.SavePath)
+
+	store := ttlstore.NewMapStore[string, string](p.ctx, cfg)
+	if err := store.Load(); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	if err := store.Run(); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (p *storeProvider) Drop(store *ttlstore.MapStore[string, string]) error {
+	if err := store.Close(); err != nil {
+		return err
+	}
+
+	if !p.cfg.Save {
+		return nil
+	}
+
+	if err := os.Remove(store.Path()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type Server struct {
+	g         *gin.Engine
+	logger    *logrus.Logger
+	wM        *manager.WorkerManager
+	broker    *queue.Broker
+	sched     *scheduler.Scheduler
+	hub       *channel.Hub
+	locker    *lock.Locker
+	shortener *link.Shortener
+	recorder  *stats.Recorder
+	keyring   *auth.Keyring
+	limiter   *ratelimit.Limiter
+	cfg       ServerConfig
+	stores    []*ttlstore.MapStore[string, string]
+	buckets   []bucket
 }
 
 func NewServer(configPath string) (*Server, error) {
@@ -67,11 +165,19 @@ func NewServer(configPath string) (*Server, error) {
 
 	log.Infof("Loaded config: %+v", cfg)
 
-	//Construct maps
-	stores := make([]*ttlstore.MapStore[string, string], cfg.ManagerCfg.WorkerNum)
-	for i := uint(0); i < cfg.ManagerCfg.WorkerNum; i++ {
-		ttlCfg := cfg.StoreCfg
-		ttlCfg.SavePath = strings.TrimRight(ttlCfg.SavePath, "/") + fmt.Sprintf("/#store%d.db", i)
+	//Construct maps. Stores, left by run with greater number of workers, are drained by manager
+	count := storeCount(cfg.StoreCfg)
+	if count > 0 && count != int(cfg.ManagerCfg.WorkerNum) {
+		log.Infof("Number of workers changed from %d to %d, keys will be migrated.", count, cfg.ManagerCfg.WorkerNum)
+	}
+
+	if count < int(cfg.ManagerCfg.WorkerNum) {
+		count = int(cfg.ManagerCfg.WorkerNum)
+	}
+
+	stores := make([]*ttlstore.MapStore[string, string], count)
+	for i := range stores {
+		ttlCfg := storeConfig(cfg.StoreCfg, i)
 
 		log.Infof("Creating db file in: %s", ttlCfg.SavePath)
 
@@ -79,13 +185,79 @@ func NewServer(configPath string) (*Server, error) {
 	}
 
 	wM := manager.NewWorkerManager(ctx, stores, log, cfg.ManagerCfg)
+	wM.SetStoreProvider(&storeProvider{
+		ctx:    ctx,
+		cfg:    cfg.StoreCfg,
+		logger: log,
+	})
+
+	queueCfg := bucketConfig(cfg.StoreCfg, "queues")
+	log.Infof("Creating db file in: %s", queueCfg.SavePath)
+	queueStore := ttlstore.NewMapStore[string, queue.Message](ctx, queueCfg)
+	broker := queue.NewBroker(ctx, queueStore, log.WithField("service", "queue"), cfg.QueueCfg)
+
+	schedCfg := bucketConfig(cfg.StoreCfg, "schedules")
+	log.Infof("Creating db file in: %s", schedCfg.SavePath)
+	schedStore := ttlstore.NewMapStore[string, scheduler.Job](ctx, schedCfg)
+	sched := scheduler.NewScheduler(ctx, schedStore, log.WithField("service", "scheduler"), cfg.SchedulerCfg)
+
+	channelCfg := bucketConfig(cfg.StoreCfg, "channels")
+	log.Infof("Creating db file in: %s", channelCfg.SavePath)
+	channelStore := ttlstore.NewMapStore[string, channel.Message](ctx, channelCfg)
+	hub := channel.NewHub(ctx, channelStore, wM, log.WithField("service", "channel"), cfg.ChannelCfg)
+
+	lockCfg := bucketConfig(cfg.StoreCfg, "locks")
+	log.Infof("Creating db file in: %s", lockCfg.SavePath)
+	lockStore := ttlstore.NewMapStore[string, lock.Lease](ctx, lockCfg)
+	locker := lock.NewLocker(lockStore, cfg.LockCfg)
+
+	linkCfg := bucketConfig(cfg.StoreCfg, "links")
+	log.Infof("Creating db file in: %s", linkCfg.SavePath)
+	linkStore := ttlstore.NewMapStore[string, string](ctx, linkCfg)
+	shortener, err := link.NewShortener(wM, linkStore, cfg.LinkCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	statsCfg := bucketConfig(cfg.StoreCfg, "stats")
+	log.Infof("Creating db file in: %s", statsCfg.SavePath)
+	statsStore := ttlstore.NewMapStore[string, uint64](ctx, statsCfg)
+	recorder, err := stats.NewRecorder(ctx, statsStore, log.WithField("service", "stats"), cfg.StatsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	authCfg := bucketConfig(cfg.StoreCfg, "auth")
+	log.Infof("Creating db file in: %s", authCfg.SavePath)
+	authStore := ttlstore.NewMapStore[string, auth.Key](ctx, authCfg)
+	keyring, err := auth.NewKeyring(authStore, cfg.AuthCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitCfg := bucketConfig(cfg.StoreCfg, "ratelimit")
+	log.Infof("Creating db file in: %s", rateLimitCfg.SavePath)
+	rateLimitStore := ttlstore.NewMapStore[string, ratelimit.State](ctx, rateLimitCfg)
+	limiter, err := ratelimit.NewLimiter(ctx, rateLimitStore, log.WithField("service", "ratelimit"), cfg.RateLimitCfg)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Server{
-		g:      g,
-		logger: log,
-		stores: stores,
-		wM:     wM,
-		cfg:    cfg,
+		g:         g,
+		logger:    log,
+		stores:    stores,
+		buckets:   []bucket{queueStore, schedStore, channelStore, lockStore, linkStore, statsStore, authStore, rateLimitStore},
+		wM:        wM,
+		broker:    broker,
+		sched:     sched,
+		hub:       hub,
+		locker:    locker,
+		shortener: shortener,
+		recorder:  recorder,
+		keyring:   keyring,
+		limiter:   limiter,
+		cfg:       cfg,
 	}, nil
 }
 
@@ -108,9 +280,34 @@ func (s *Server) Run() error {
 		}
 	}
 
+	for _, b := range s.buckets {
+		if err := b.Load(); err != nil {
+			s.logger.Errorf("Error while load bucket: %s", err.Error())
+		}
+
+		if err := b.Run(); err != nil {
+			s.logger.Errorf("Error while start bucket: %s", err.Error())
+		}
+	}
+
 	//start manager
 	s.wM.Run()
 
+	//start queues
+	s.broker.Run()
+
+	//start scheduler
+	s.sched.Run()
+
+	//start channels
+	s.hub.Run()
+
+	//start stats
+	s.recorder.Run()
+
+	//start rate limit
+	s.limiter.Run()
+
 	if err := s.MapHandlers(); err != nil {
 		return err
 	}
@@ -125,7 +322,7 @@ func (s *Server) Run() error {
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscanll.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall. SIGKILL but can"t be catch, so don't need add it
@@ -135,13 +332,34 @@ func (s *Server) Run() error {
 	// Stop manager
 	s.wM.Stop()
 
-	// Stop every store
-	for _, st := range s.stores {
+	// Stop queues
+	s.broker.Stop()
+
+	// Stop scheduler
+	s.sched.Stop()
+
+	// Stop channels
+	s.hub.Stop()
+
+	// Stop stats, recorded hits are written
+	s.recorder.Stop()
+
+	// Stop rate limit, changed buckets are written
+	s.limiter.Stop()
+
+	// Stop every store, that is still used by manager
+	for _, st := range s.wM.Stores() {
 		if err := st.Close(); err != nil {
 			s.logger.Errorf("Error while closing store: %s", err.Error())
 		}
 	}
 
+	for _, b := range s.buckets {
+		if err := b.Close(); err != nil {
+			s.logger.Errorf("Error while closing bucket: %s", err.Error())
+		}
+	}
+
 	s.logger.Info("Shutdown Server ...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
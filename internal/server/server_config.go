@@ -3,7 +3,15 @@ package server
 import (
 	"os"
 
+	"github.com/BON4/timedQ/internal/auth"
+	"github.com/BON4/timedQ/internal/channel"
+	"github.com/BON4/timedQ/internal/link"
+	"github.com/BON4/timedQ/internal/lock"
 	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/internal/queue"
+	"github.com/BON4/timedQ/internal/ratelimit"
+	"github.com/BON4/timedQ/internal/scheduler"
+	"github.com/BON4/timedQ/internal/stats"
 	"github.com/BON4/timedQ/pkg/ttlstore"
 	"gopkg.in/yaml.v2"
 )
@@ -14,8 +22,16 @@ type ServerConfig struct {
 		LogFile string `yaml:"log-file"`
 	} `yaml:"app"`
 
-	ManagerCfg manager.ManagerConfig   `yaml:"manager"`
-	StoreCfg   ttlstore.TTLStoreConfig `yaml:"store"`
+	ManagerCfg   manager.ManagerConfig     `yaml:"manager"`
+	StoreCfg     ttlstore.TTLStoreConfig   `yaml:"store"`
+	QueueCfg     queue.QueueConfig         `yaml:"queue"`
+	SchedulerCfg scheduler.SchedulerConfig `yaml:"scheduler"`
+	ChannelCfg   channel.ChannelConfig     `yaml:"channels"`
+	LockCfg      lock.LockConfig           `yaml:"locks"`
+	LinkCfg      link.LinkConfig           `yaml:"links"`
+	StatsCfg     stats.StatsConfig         `yaml:"stats"`
+	AuthCfg      auth.AuthConfig           `yaml:"auth"`
+	RateLimitCfg ratelimit.RateLimitConfig `yaml:"rate-limit"`
 }
 
 func LoadServerConfig(path string) (ServerConfig, error) {
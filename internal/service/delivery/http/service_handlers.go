@@ -1,55 +1,511 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/BON4/timedQ/internal/link"
 	"github.com/BON4/timedQ/internal/manager"
+	"github.com/BON4/timedQ/internal/stats"
+	"github.com/BON4/timedQ/internal/value"
+	"github.com/BON4/timedQ/pkg/buffpool"
+	"github.com/BON4/timedQ/pkg/ttlstore"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 type serviceSetRequest struct {
 	Redirect string `json:"redirect" binding:"required"`
+	// Alias - custom code of link, 3 to 64 letters, digits, '-' or '_'. Code is generated, when alias is empty
+	Alias string `json:"alias"`
+	// Status - redirect status: 301, 302, 307 or 308, links.status from config by default
+	Status int `json:"status" binding:"omitempty,oneof=301 302 307 308"`
+	// TTL - duration string, for example 24h. Expired link is answered with 410 for 24h, then with 404. Link without ttl never expires, reads do not change ttl of link
+	TTL string `json:"ttl"`
+	// WriteConcern - async, applied (default) or durable. Durable needs stores saved to file.
+	// Default is applied, so created link can be read at once, earlier versions answered before write like async.
+	WriteConcern string `json:"write_concern" binding:"omitempty,oneof=async applied durable"`
 }
 
 type serviceSetResponse struct {
 	EncodeURL string `json:"encode_url"`
 }
 
-type serviceGetResponse struct {
-	DecodeURL string `json:"decode_url"`
+type serviceErrorResponse struct {
+	Error string `json:"error"`
 }
 
+type serviceKeysResponse struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor"`
+}
+
+type serviceKV struct {
+	Key string `json:"key" binding:"required"`
+	Val string `json:"val"`
+}
+
+type serviceBatchRequest struct {
+	Set    []serviceKV `json:"set" binding:"dive"`
+	Delete []string    `json:"delete"`
+	Get    []string    `json:"get"`
+}
+
+type serviceBatchResponse struct {
+	Values  map[string]string `json:"values"`
+	Missing []string          `json:"missing"`
+}
+
+type serviceTxOp struct {
+	Op  string `json:"op" binding:"required,oneof=set delete"`
+	Key string `json:"key" binding:"required"`
+	Val string `json:"val"`
+}
+
+type serviceTxRequest struct {
+	Ops []serviceTxOp `json:"ops" binding:"required,min=1,dive"`
+}
+
+type serviceTxResponse struct {
+	Applied int `json:"applied"`
+}
+
+type serviceWatchEvent struct {
+	Key     string `json:"key"`
+	Val     string `json:"val,omitempty"`
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+type serviceReshardRequest struct {
+	Workers uint `json:"workers" binding:"required,min=1"`
+}
+
+type serviceReshardResponse struct {
+	Workers   int    `json:"workers"`
+	Migrating bool   `json:"migrating"`
+	Moved     uint64 `json:"moved"`
+}
+
+type serviceWorkerHealth struct {
+	Index      int    `json:"index"`
+	Alive      bool   `json:"alive"`
+	Healthy    bool   `json:"healthy"`
+	Restarts   uint64 `json:"restarts"`
+	QueueDepth int    `json:"queue_depth"`
+	QueueSize  int    `json:"queue_size"`
+	// BusyMs - how long worker is processing current task, in milliseconds
+	BusyMs int64 `json:"busy_ms"`
+}
+
+type serviceWorkersResponse struct {
+	Workers  []serviceWorkerHealth `json:"workers"`
+	Rejected uint64                `json:"rejected"`
+}
+
+type serviceHotKey struct {
+	Key string `json:"key"`
+	// Reads - estimated number of reads since last decay
+	Reads uint32 `json:"reads"`
+}
+
+type serviceHotKeysResponse struct {
+	Keys []serviceHotKey `json:"keys"`
+}
+
+const (
+	defaultKeysLimit = 100
+	maxKeysLimit     = 1000
+
+	watchHeartbeat = 15 * time.Second
+
+	// bodyBufferSize - initial size of pooled buffer for request body, buffers that grew over maxPooledBuffer are not pooled
+	bodyBufferSize  = 64 * 1024
+	maxPooledBuffer = 1024 * 1024
+)
+
+var (
+	errInvalidLimit  = errors.New("limit must be in range [1, 1000]")
+	errInvalidPolicy = errors.New("policy must be drop or disconnect")
+	errKeyNotFound   = errors.New("key not found")
+)
+
+// abortWithJSON - aborts request with error, that is also sent to client as json
+func abortWithJSON(c *gin.Context, code int, err error) {
+	c.Error(err)
+	c.AbortWithStatusJSON(code, serviceErrorResponse{
+		Error: err.Error(),
+	})
+}
+
+// statusClientClosed - client closed connection, before response was ready
+const statusClientClosed = 499
+
 type serviceHandler struct {
 	logger      *logrus.Entry
 	workManager *manager.WorkerManager
+	shortener   *link.Shortener
+	recorder    *stats.Recorder
+	buffers     buffpool.BufferPool
+}
+
+// record - queues hit of key for stats, it does not block request
+func (s *serviceHandler) record(c *gin.Context, key string) {
+	s.recorder.Record(stats.Hit{
+		Key:       key,
+		Time:      time.Now(),
+		Referrer:  c.Request.Referer(),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	})
+}
+
+const pageTemplate = `<!DOCTYPE html>
+<html>
+<head><title>%[1]d %[2]s</title></head>
+<body><h1>%[1]d %[2]s</h1><p>%[3]s</p></body>
+</html>
+`
+
+// abortWithPage - aborts request with html page, redirects are opened by browsers
+func abortWithPage(c *gin.Context, code int, err error) {
+	c.Error(err)
+	page := fmt.Sprintf(pageTemplate, code, http.StatusText(code), html.EscapeString(err.Error()))
+	c.Data(code, "text/html; charset=utf-8", []byte(page))
+	c.Abort()
+}
+
+// cacheHeaders - value is fresh until its ttl ends, then cached value is revalidated with its ETag.
+// Value, that never expires, is revalidated on every read.
+func cacheHeaders(c *gin.Context, expires time.Time) {
+	maxAge := int64(time.Until(expires) / time.Second)
+	if expires.IsZero() || maxAge <= 0 {
+		c.Header("Cache-Control", "no-cache")
+		return
+	}
+
+	c.Header("Cache-Control", "max-age="+strconv.FormatInt(maxAge, 10))
+	c.Header("Expires", expires.UTC().Format(http.TimeFormat))
+}
+
+// etag - strong ETag of revision of value
+func etag(rev uint64) string {
+	return `"` + strconv.FormatUint(rev, 10) + `"`
+}
+
+// parseETags - revisions of ETags in If-Match or If-None-Match header, all is true for "*".
+// Weak ETags match only with weak comparison of If-None-Match, ETags, that are not revisions, never match.
+func parseETags(header string, weak bool) (revs []uint64, all bool) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		if rev, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64); err == nil {
+			revs = append(revs, rev)
+		}
+	}
+	return revs, false
+}
+
+// ifMatch - revisions, that key must have for PUT and DELETE with If-Match header. Request is aborted with 412,
+// when no revision can match. Returns false, when there is no If-Match header, or request was aborted.
+func ifMatch(c *gin.Context) ([]uint64, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil, false
+	}
+
+	revs, all := parseETags(header, false)
+	if !all && len(revs) == 0 {
+		abortWithJSON(c, http.StatusPreconditionFailed, manager.ErrRevMismatch)
+		return nil, false
+	}
+	return revs, true
+}
+
+// notModified - value with rev matches If-None-Match header of request
+func notModified(c *gin.Context, rev uint64) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	revs, all := parseETags(header, true)
+	if all {
+		return true
+	}
+
+	for _, r := range revs {
+		if r == rev {
+			return true
+		}
+	}
+	return false
 }
 
-// @Summary      Get redirect
-// @Description  by known key, user can get an url
+// load - gets value of key for GET and HEAD and sets headers of value. Value, that matches If-None-Match,
+// is answered with 304. Returns false, when request was aborted.
+func (s *serviceHandler) load(c *gin.Context, key string) (value.Value, bool) {
+	val, rev, expires, found, err := s.workManager.GetWithTTL(c.Request.Context(), key)
+	if err != nil {
+		s.abortWithManagerError(c, err)
+		return value.Value{}, false
+	}
+
+	if !found {
+		abortWithJSON(c, http.StatusNotFound, errKeyNotFound)
+		return value.Value{}, false
+	}
+
+	v := value.Decode(val)
+
+	cacheHeaders(c, expires)
+	c.Header("ETag", etag(rev))
+	c.Header("Content-Type", v.ContentType)
+	if v.ContentEncoding != "" {
+		c.Header("Content-Encoding", v.ContentEncoding)
+	}
+
+	if notModified(c, rev) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return value.Value{}, false
+	}
+	return v, true
+}
+
+// @Summary      Get value
+// @Description  returns raw value of key with Content-Type and Content-Encoding, it was stored with by PUT /{key}.
+// @Description  Content-Type of value without it is detected from value. Reading value refreshes its ttl.
+// @Description  Value is fresh in cache until its ttl ends, Cache-Control max-age and Expires are remaining ttl after read,
+// @Description  value, that never expires, is sent with Cache-Control: no-cache. Stale value is revalidated with If-None-Match.
+// @Description  ETag is revision of value, it changes with every write. Value, that matches If-None-Match, is answered with 304,
+// @Description  such read is not counted in stats
 // @Tags         general
-// @Produce      json
-// @Param        key  path      string  true  "decoded full url"
-// @Success      200  {object}  serviceGetResponse
+// @Produce      octet-stream
+// @Param        key            path      string  true   "key"
+// @Param        If-None-Match  header    string  false  "ETags of cached value"
+// @Success      200  {string}  string  "value"
+// @Header       200  {string}  ETag    "revision of value"
+// @Header       200  {string}  Cache-Control  "max-age of remaining ttl, or no-cache"
+// @Header       200  {string}  Expires        "time, when ttl of value ends"
+// @Success      304  {string}  string  "Not Modified"
+// @Failure      404  {object}  serviceErrorResponse
+// @Failure      429  {object}  serviceErrorResponse
+// @Failure      503  {object}  serviceErrorResponse
+// @Failure      504  {object}  serviceErrorResponse
 // @Router       /{key} [Get]
 func (s *serviceHandler) Get() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		k := c.Param("key")
-		c.JSON(http.StatusOK, gin.H{
-			"val": s.workManager.Get(k),
-		})
+
+		v, ok := s.load(c, k)
+		if !ok {
+			return
+		}
+		s.record(c, k)
+
+		// Body is streamed from value, large value is not copied
+		c.DataFromReader(http.StatusOK, int64(len(v.Body)), v.ContentType, strings.NewReader(v.Body), nil)
+	}
+}
+
+// @Summary      Head value
+// @Description  same as GET /{key}, but without body. Hit is not counted in stats
+// @Tags         general
+// @Param        key            path      string  true   "key"
+// @Param        If-None-Match  header    string  false  "ETags of cached value"
+// @Success      200  {string}  string  "headers of value"
+// @Header       200  {string}  ETag    "revision of value"
+// @Success      304  {string}  string  "Not Modified"
+// @Failure      404  {object}  serviceErrorResponse
+// @Failure      429  {object}  serviceErrorResponse
+// @Failure      503  {object}  serviceErrorResponse
+// @Failure      504  {object}  serviceErrorResponse
+// @Router       /{key} [head]
+func (s *serviceHandler) Head() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := s.load(c, c.Param("key"))
+		if !ok {
+			return
+		}
+
+		c.Header("Content-Length", strconv.Itoa(len(v.Body)))
+		c.Status(http.StatusOK)
+	}
+}
+
+// readValue - reads request body after metadata of value into buffer. Body larger than manager.max-value-size is rejected with 413.
+// Returns false, when request was aborted.
+func (s *serviceHandler) readValue(c *gin.Context, buf *bytes.Buffer) bool {
+	contentType, contentEncoding := c.GetHeader("Content-Type"), c.GetHeader("Content-Encoding")
+	value.WriteHeader(buf, contentType, contentEncoding)
+
+	body := c.Request.Body
+	if max := s.workManager.MaxValueSize(); max >= 0 {
+		body = http.MaxBytesReader(c.Writer, body, max-int64(buf.Len()))
+	}
+
+	if _, err := buf.ReadFrom(body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortWithJSON(c, http.StatusRequestEntityTooLarge, manager.ErrValueTooLarge)
+			return false
+		}
+
+		c.AbortWithError(http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+// putBuffer - returns buffer to pool, buffers of large values are left to gc
+func (s *serviceHandler) putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	s.buffers.PutBuffer(buf)
+}
+
+// @Summary      Put value
+// @Description  sets raw request body of any content type as value of key, chosen by client. Content-Type and Content-Encoding
+// @Description  of request are stored with value and returned by GET /{key}. Body larger than manager.max-value-size is rejected with 413.
+// @Description  Value expires after manager.val-ttl, if it is not read.
+// @Description  write_concern chooses, when response is sent: async - after write is queued, it can still fail,
+// @Description  applied (default) - after value is stored in memory, durable - after value is written and synced to dump file.
+// @Description  Durable is rejected with 400, when stores are not saved to file.
+// @Description  With If-Match value is set, only if key is present and its ETag matches, otherwise 412 is returned.
+// @Description  Conditional write reports ETag of new value, async write concern is not allowed for it
+// @Tags         general
+// @Accept       octet-stream
+// @Param        key            path      string  true   "key"
+// @Param        write_concern  query     string  false  "async, applied or durable"
+// @Param        If-Match       header    string  false  "ETags of current value or *"
+// @Param        input          body      string  true   "value"
+// @Success      204
+// @Header       204  {string}  ETag  "revision of new value, only for conditional write"
+// @Failure      400  {object}  error
+// @Failure      412  {object}  serviceErrorResponse
+// @Failure      413  {object}  serviceErrorResponse
+// @Failure      429  {object}  serviceErrorResponse
+// @Failure      503  {object}  serviceErrorResponse
+// @Failure      504  {object}  serviceErrorResponse
+// @Router       /{key} [put]
+func (s *serviceHandler) Put() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		wc, err := manager.ParseWriteConcern(c.Query("write_concern"))
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		revs, conditional := ifMatch(c)
+		if c.IsAborted() {
+			return
+		}
+
+		buf := s.buffers.GetBuffer()
+		defer s.putBuffer(buf)
+
+		if !s.readValue(c, buf) {
+			return
+		}
+
+		if conditional {
+			rev, err := s.workManager.CompareAndSwap(c.Request.Context(), c.Param("key"), buf.String(), wc, revs...)
+			if err != nil {
+				s.abortWithManagerError(c, err)
+				return
+			}
+
+			c.Header("ETag", etag(rev))
+			c.Status(http.StatusNoContent)
+			return
+		}
+
+		if err := s.workManager.Set(c.Request.Context(), c.Param("key"), buf.String(), wc); err != nil {
+			s.abortWithManagerError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary      Delete value
+// @Description  deletes key, deleting missing key is not an error.
+// @Description  With If-Match key is deleted, only if it is present and its ETag matches, otherwise 412 is returned
+// @Tags         general
+// @Param        key       path      string  true   "key"
+// @Param        If-Match  header    string  false  "ETags of current value or *"
+// @Success      204
+// @Failure      412  {object}  serviceErrorResponse
+// @Failure      429  {object}  serviceErrorResponse
+// @Failure      503  {object}  serviceErrorResponse
+// @Failure      504  {object}  serviceErrorResponse
+// @Router       /{key} [delete]
+func (s *serviceHandler) Delete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revs, conditional := ifMatch(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var err error
+		if conditional {
+			err = s.workManager.CompareAndDelete(c.Request.Context(), c.Param("key"), revs...)
+		} else {
+			err = s.workManager.MDelete(c.Request.Context(), []string{c.Param("key")})
+		}
+
+		if err != nil {
+			s.abortWithManagerError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
 	}
 }
 
 // @Summary      Set redirect
-// @Description  sets key-value, where user is providing value, and gets key
+// @Description  creates short link to url and returns its code, link is opened by GET /r/{code} and is stored under key link:{code} as json object.
+// @Description  Url must be absolute, with allowed scheme (links.schemes) and host, that is not blocked (links.blocked-hosts).
+// @Description  Code is alias of caller, or it is generated by links.codes: random base62 code or base62 of persisted counter.
+// @Description  Url, that already has live generated link with the same status, gets code of that link, urls are compared normalized.
+// @Description  With links.refresh-ttl existing link gets ttl of request.
+// @Description  write_concern chooses, when response is sent: applied (default) - after value is stored in memory,
+// @Description  durable - after value is written and synced to dump file, it is rejected with 400, when stores are not saved to file.
+// @Description  async is applied, because code must be checked to be free. Default is applied, earlier versions answered before write like async
 // @Tags         general
 // @Accept       json
 // @Produce      json
 // @Param        input   body      serviceSetRequest  true  "encoded short url"
 // @Success      200     {object}  serviceSetResponse
 // @Failure      400     {object}  error
+// @Failure      409     {object}  serviceErrorResponse
+// @Failure      429     {object}  serviceErrorResponse
+// @Failure      503     {object}  serviceErrorResponse
+// @Failure      504     {object}  serviceErrorResponse
 // @Router       / [post]
 func (s *serviceHandler) Set() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -59,20 +515,451 @@ func (s *serviceHandler) Set() gin.HandlerFunc {
 			return
 		}
 
-		// TODO: Change to shortten provided link
-		link := uuid.New().String()
+		wc, err := manager.ParseWriteConcern(req.WriteConcern)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		var ttl time.Duration
+		if req.TTL != "" {
+			if ttl, err = time.ParseDuration(req.TTL); err != nil {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+		}
 
-		s.workManager.Set(link, req.Redirect)
+		code, err := s.shortener.Create(c.Request.Context(), req.Redirect, link.Options{
+			Alias:   req.Alias,
+			Status:  req.Status,
+			TTL:     ttl,
+			Concern: wc,
+		})
+		switch {
+		case errors.Is(err, link.ErrInvalidURL), errors.Is(err, link.ErrSchemeNotAllowed), errors.Is(err, link.ErrHostBlocked),
+			errors.Is(err, link.ErrInvalidStatus), errors.Is(err, link.ErrInvalidTTL),
+			errors.Is(err, link.ErrInvalidAlias), errors.Is(err, link.ErrAliasReserved), errors.Is(err, link.ErrAliasProfane):
+			abortWithJSON(c, http.StatusBadRequest, err)
+			return
+		case errors.Is(err, link.ErrAliasTaken):
+			abortWithJSON(c, http.StatusConflict, err)
+			return
+		case err != nil:
+			s.abortWithManagerError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, serviceSetResponse{
-			EncodeURL: link,
+			EncodeURL: code,
+		})
+	}
+}
+
+// @Summary      Redirect
+// @Description  redirects to url of short link with status of link. Missing code is answered with 404 page, expired one with 410 page.
+// @Description  Route is public and is served without /v1 prefix
+// @Tags         general
+// @Produce      html
+// @Param        code  path      string  true  "code of short link"
+// @Success      301   {string}  string  "Moved Permanently"
+// @Success      302   {string}  string  "Found"
+// @Success      307   {string}  string  "Temporary Redirect"
+// @Success      308   {string}  string  "Permanent Redirect"
+// @Failure      404   {string}  string  "Not Found"
+// @Failure      410   {string}  string  "Gone"
+// @Failure      429   {object}  serviceErrorResponse
+// @Failure      503   {object}  serviceErrorResponse
+// @Failure      504   {object}  serviceErrorResponse
+// @Router       /r/{code} [get]
+func (s *serviceHandler) Redirect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := c.Param("code")
+
+		l, err := s.shortener.Resolve(c.Request.Context(), code)
+		switch {
+		case errors.Is(err, link.ErrNotFound):
+			abortWithPage(c, http.StatusNotFound, err)
+			return
+		case errors.Is(err, link.ErrExpired):
+			abortWithPage(c, http.StatusGone, err)
+			return
+		case err != nil:
+			s.abortWithManagerError(c, err)
+			return
+		}
+
+		// Browsers cache permanent redirects, so cache of expiring link must end with link
+		if l.Expires > 0 {
+			c.Header("Cache-Control", "max-age="+strconv.FormatInt(l.Expires-time.Now().Unix(), 10))
+		}
+
+		s.record(c, link.Key(code))
+		c.Redirect(l.Status, l.URL)
+	}
+}
+
+// @Summary      List keys
+// @Description  lists keys with provided prefix in ascending order. Pass returned cursor to get next page, empty cursor means there is no more keys
+// @Tags         general
+// @Produce      json
+// @Param        prefix  query     string  false  "key prefix"
+// @Param        cursor  query     string  false  "cursor from previous page"
+// @Param        limit   query     int     false  "page size, 100 by default, 1000 max"
+// @Success      200     {object}  serviceKeysResponse
+// @Failure      400     {object}  error
+// @Router       /keys [get]
+func (s *serviceHandler) Keys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := defaultKeysLimit
+		if l := c.Query("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil || limit <= 0 || limit > maxKeysLimit {
+				c.AbortWithError(http.StatusBadRequest, errInvalidLimit)
+				return
+			}
+		}
+
+		keys, cursor := s.workManager.Scan(c.Query("prefix"), c.Query("cursor"), limit)
+
+		c.JSON(http.StatusOK, serviceKeysResponse{
+			Keys:   keys,
+			Cursor: cursor,
+		})
+	}
+}
+
+// @Summary      Batch
+// @Description  sets, then deletes, then gets many keys in one request. Operations of different keys are not atomic.
+// @Description  Values, stored by PUT /{key}, are returned without Content-Type and Content-Encoding
+// @Tags         general
+// @Accept       json
+// @Produce      json
+// @Param        input  body      serviceBatchRequest  true  "keys to set, delete and get"
+// @Success      200    {object}  serviceBatchResponse
+// @Failure      400    {object}  error
+// @Failure      500    {object}  error
+// @Failure      429    {object}  serviceErrorResponse
+// @Failure      503    {object}  serviceErrorResponse
+// @Failure      504    {object}  serviceErrorResponse
+// @Router       /batch [post]
+func (s *serviceHandler) Batch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &serviceBatchRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if len(req.Set) > 0 {
+			keys := make([]string, len(req.Set))
+			vals := make([]string, len(req.Set))
+			for i, kv := range req.Set {
+				keys[i], vals[i] = kv.Key, kv.Val
+			}
+
+			if err := s.workManager.MSet(c.Request.Context(), keys, vals); err != nil {
+				s.abortWithManagerError(c, err)
+				return
+			}
+		}
+
+		if len(req.Delete) > 0 {
+			if err := s.workManager.MDelete(c.Request.Context(), req.Delete); err != nil {
+				s.abortWithManagerError(c, err)
+				return
+			}
+		}
+
+		resp := serviceBatchResponse{
+			Values:  make(map[string]string, len(req.Get)),
+			Missing: make([]string, 0),
+		}
+
+		if len(req.Get) > 0 {
+			vals, found, err := s.workManager.MGet(c.Request.Context(), req.Get)
+			if err != nil {
+				s.abortWithManagerError(c, err)
+				return
+			}
+
+			for i, k := range req.Get {
+				if found[i] {
+					resp.Values[k] = value.Decode(vals[i]).Body
+				} else {
+					resp.Missing = append(resp.Missing, k)
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// @Summary      Transaction
+// @Description  applies group of sets and deletes all-or-nothing. Every key MUST belong to one shard,
+// @Description  keys with the same hash tag, for example {user:1}:name and {user:1}:email, always do
+// @Tags         general
+// @Accept       json
+// @Produce      json
+// @Param        input  body      serviceTxRequest  true  "writes of transaction"
+// @Success      200    {object}  serviceTxResponse
+// @Failure      400    {object}  error
+// @Failure      500    {object}  error
+// @Failure      429    {object}  serviceErrorResponse
+// @Failure      503    {object}  serviceErrorResponse
+// @Failure      504    {object}  serviceErrorResponse
+// @Router       /tx [post]
+func (s *serviceHandler) Tx() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &serviceTxRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		tx := s.workManager.Multi()
+		for _, op := range req.Ops {
+			switch op.Op {
+			case "set":
+				tx.Set(op.Key, op.Val)
+			case "delete":
+				tx.Delete(op.Key)
+			}
+		}
+
+		if err := tx.Exec(c.Request.Context()); errors.Is(err, manager.ErrCrossShard) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			s.abortWithManagerError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, serviceTxResponse{
+			Applied: len(req.Ops),
+		})
+	}
+}
+
+// @Summary      Watch keys
+// @Description  streams changes of keys as server-sent events. Event name is one of set, refresh, delete, expire.
+// @Description  When client is too slow, events are dropped and counted in dropped field (policy=drop),
+// @Description  or stream ends with overflow event (policy=disconnect)
+// @Tags         general
+// @Produce      text/event-stream
+// @Param        prefix  query     string  false  "watch keys with prefix"
+// @Param        key     query     string  false  "watch single key, prefix is ignored"
+// @Param        policy  query     string  false  "slow client policy: drop (default) or disconnect"
+// @Success      200     {object}  serviceWatchEvent
+// @Failure      400     {object}  error
+// @Router       /watch [get]
+func (s *serviceHandler) Watch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := ttlstore.WatchConfig[string]{
+			Key:    c.Query("key"),
+			Prefix: c.Query("prefix"),
+		}
+
+		switch c.DefaultQuery("policy", "drop") {
+		case "drop":
+			cfg.Policy = ttlstore.DropEvents
+		case "disconnect":
+			cfg.Policy = ttlstore.Disconnect
+		default:
+			c.AbortWithError(http.StatusBadRequest, errInvalidPolicy)
+			return
+		}
+
+		sub := s.workManager.Watch(cfg)
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(watchHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case e := <-sub.Events():
+				c.SSEvent(e.Type.String(), serviceWatchEvent{
+					Key:     e.Key,
+					Val:     value.Decode(e.Val).Body,
+					Dropped: sub.Dropped(),
+				})
+				return true
+			case <-sub.Done():
+				c.SSEvent("overflow", serviceWatchEvent{
+					Dropped: sub.Dropped(),
+				})
+				return false
+			case <-heartbeat.C:
+				c.SSEvent("heartbeat", "")
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
 		})
 	}
 }
 
-func NewServiceHandler(wM *manager.WorkerManager, logger *logrus.Entry) *serviceHandler {
+// retryAfter - sets Retry-After header in whole seconds, at least one
+func retryAfter(c *gin.Context, d time.Duration) {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(secs))
+}
+
+// abortWithManagerError - aborts request with status, that matches error of manager operation.
+// When manager is overloaded or stopping, client is asked to retry later.
+func (s *serviceHandler) abortWithManagerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, manager.ErrOverloaded):
+		retryAfter(c, s.workManager.RetryAfter())
+		abortWithJSON(c, http.StatusTooManyRequests, err)
+	case errors.Is(err, manager.ErrRevMismatch):
+		abortWithJSON(c, http.StatusPreconditionFailed, err)
+	case errors.Is(err, manager.ErrInvalidWriteConcern):
+		abortWithJSON(c, http.StatusBadRequest, err)
+	case errors.Is(err, manager.ErrValueTooLarge):
+		abortWithJSON(c, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, manager.ErrStopped):
+		retryAfter(c, s.workManager.RetryAfter())
+		abortWithJSON(c, http.StatusServiceUnavailable, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		abortWithJSON(c, http.StatusGatewayTimeout, err)
+	case errors.Is(err, context.Canceled):
+		abortWithJSON(c, statusClientClosed, err)
+	default:
+		abortWithJSON(c, http.StatusInternalServerError, err)
+	}
+}
+
+func newReshardResponse(st manager.ReshardStatus) serviceReshardResponse {
+	return serviceReshardResponse{
+		Workers:   st.Workers,
+		Migrating: st.Migrating,
+		Moved:     st.Moved,
+	}
+}
+
+// @Summary      Reshard
+// @Description  changes number of workers without downtime. Keys, that changed owner, are moved in background,
+// @Description  progress is reported by GET /admin/reshard. New number of workers is not saved,
+// @Description  manager.worker-num from config is applied again on next start
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        input  body      serviceReshardRequest  true  "new number of workers"
+// @Success      202    {object}  serviceReshardResponse
+// @Failure      400    {object}  error
+// @Failure      409    {object}  error
+// @Failure      500    {object}  error
+// @Router       /admin/reshard [post]
+func (s *serviceHandler) Reshard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &serviceReshardRequest{}
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := s.workManager.Reshard(req.Workers); errors.Is(err, manager.ErrReshardInProgress) {
+			c.AbortWithError(http.StatusConflict, err)
+			return
+		} else if errors.Is(err, manager.ErrInvalidWorkerNum) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		} else if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, newReshardResponse(s.workManager.Status()))
+	}
+}
+
+// @Summary      Reshard status
+// @Description  current number of workers and progress of keys migration
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  serviceReshardResponse
+// @Router       /admin/reshard [get]
+func (s *serviceHandler) ReshardStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, newReshardResponse(s.workManager.Status()))
+	}
+}
+
+// @Summary      Workers health
+// @Description  liveness, restarts after panic and queue depth of every worker. Status is 503, when some worker is not healthy:
+// @Description  it is not running, or processes one task longer than manager.stuck-after. Workers are added or removed by POST /admin/reshard
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  serviceWorkersResponse
+// @Failure      503  {object}  serviceWorkersResponse
+// @Router       /admin/workers [get]
+func (s *serviceHandler) Workers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		health := s.workManager.Health()
+
+		status := http.StatusOK
+		resp := serviceWorkersResponse{
+			Workers:  make([]serviceWorkerHealth, len(health)),
+			Rejected: s.workManager.Rejected(),
+		}
+
+		for i, h := range health {
+			resp.Workers[i] = serviceWorkerHealth{
+				Index:      h.Index,
+				Alive:      h.Alive,
+				Healthy:    h.Healthy,
+				Restarts:   h.Restarts,
+				QueueDepth: h.QueueDepth,
+				QueueSize:  h.QueueSize,
+				BusyMs:     h.Busy.Milliseconds(),
+			}
+
+			if !h.Healthy {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		c.JSON(status, resp)
+	}
+}
+
+// @Summary      Hot keys
+// @Description  the most read keys, the hottest first. Reads are counted by count-min sketch and halved every manager.hot-decay.
+// @Description  Keys with at least manager.hot-reads reads are served from read replicas on every worker, write of key invalidates replicas
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  serviceHotKeysResponse
+// @Router       /admin/hotkeys [get]
+func (s *serviceHandler) HotKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := s.workManager.HotKeys()
+
+		resp := serviceHotKeysResponse{
+			Keys: make([]serviceHotKey, len(keys)),
+		}
+
+		for i, k := range keys {
+			resp.Keys[i] = serviceHotKey{
+				Key:   k.Key,
+				Reads: k.Reads,
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+func NewServiceHandler(wM *manager.WorkerManager, shortener *link.Shortener, recorder *stats.Recorder, logger *logrus.Entry) *serviceHandler {
 	return &serviceHandler{
 		logger:      logger,
 		workManager: wM,
+		shortener:   shortener,
+		recorder:    recorder,
+		buffers:     buffpool.NewSyncPool(bodyBufferSize),
 	}
 }
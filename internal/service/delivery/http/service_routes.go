@@ -3,6 +3,22 @@ package http
 import "github.com/gin-gonic/gin"
 
 func NewServiceRoutes(group *gin.RouterGroup, h *serviceHandler) {
+	group.GET("/keys", h.Keys())
+	group.GET("/watch", h.Watch())
 	group.GET("/:key", h.Get())
+	group.HEAD("/:key", h.Head())
+	group.PUT("/:key", h.Put())
+	group.DELETE("/:key", h.Delete())
 	group.POST("/", h.Set())
+	group.POST("/batch", h.Batch())
+	group.POST("/tx", h.Tx())
+	group.GET("/admin/reshard", h.ReshardStatus())
+	group.POST("/admin/reshard", h.Reshard())
+	group.GET("/admin/workers", h.Workers())
+	group.GET("/admin/hotkeys", h.HotKeys())
+}
+
+// NewRedirectRoutes - public routes of short links, they are not versioned
+func NewRedirectRoutes(group *gin.RouterGroup, h *serviceHandler) {
+	group.GET("/:code", h.Redirect())
 }
@@ -0,0 +1,50 @@
+package stats
+
+import (
+	"net/url"
+	"strings"
+)
+
+// agentFamily - family of user agent. Order matters: browsers put names of other browsers in their agents.
+func agentFamily(ua string) string {
+	l := strings.ToLower(ua)
+
+	switch {
+	case l == "":
+		return "Unknown"
+	case strings.Contains(l, "bot"), strings.Contains(l, "crawl"), strings.Contains(l, "spider"), strings.Contains(l, "slurp"):
+		return "Bot"
+	case strings.HasPrefix(l, "curl/"):
+		return "curl"
+	case strings.HasPrefix(l, "wget/"):
+		return "Wget"
+	case strings.Contains(l, "edg/"), strings.Contains(l, "edge/"), strings.Contains(l, "edga/"), strings.Contains(l, "edgios/"):
+		return "Edge"
+	case strings.Contains(l, "opr/"), strings.Contains(l, "opera"):
+		return "Opera"
+	case strings.Contains(l, "samsungbrowser/"):
+		return "Samsung Internet"
+	case strings.Contains(l, "firefox/"), strings.Contains(l, "fxios/"):
+		return "Firefox"
+	case strings.Contains(l, "chrome/"), strings.Contains(l, "crios/"), strings.Contains(l, "chromium/"):
+		return "Chrome"
+	case strings.Contains(l, "safari/"):
+		return "Safari"
+	case strings.Contains(l, "msie "), strings.Contains(l, "trident/"):
+		return "IE"
+	}
+	return "Other"
+}
+
+// referrerHost - host of referrer, direct hits have no referrer
+func referrerHost(ref string) string {
+	if ref == "" {
+		return "direct"
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Hostname() == "" {
+		return "unknown"
+	}
+	return strings.ToLower(u.Hostname())
+}
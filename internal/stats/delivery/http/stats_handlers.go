@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/BON4/timedQ/internal/stats"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type statsResponse struct {
+	Key   string `json:"key"`
+	Total uint64 `json:"total"`
+	// Hours - hits per UTC hour, for example 2006-01-02T15
+	Hours map[string]uint64 `json:"hours"`
+	// Days - hits per UTC day, for example 2006-01-02
+	Days map[string]uint64 `json:"days"`
+	// Referrers - hits per host of referrer, direct for hits without referrer, other for hosts over stats.max-breakdown
+	Referrers map[string]uint64 `json:"referrers"`
+	// Agents - hits per family of user agent
+	Agents map[string]uint64 `json:"agents"`
+	// Countries - hits per country code, empty without geo db
+	Countries map[string]uint64 `json:"countries"`
+	// ASNs - hits per autonomous system, empty without geo db, other for systems over stats.max-breakdown
+	ASNs map[string]uint64 `json:"asns"`
+}
+
+type statsHandler struct {
+	logger   *logrus.Entry
+	recorder *stats.Recorder
+}
+
+// @Summary      Key stats
+// @Description  hits of key by GET /{key} and GET /r/{code}, that is counted for key link:{code}: total, per hour and day, per referrer, user agent family,
+// @Description  country and asn. Hits are aggregated in background, so last stats.flush of hits can be missing.
+// @Description  Hourly counters are kept for stats.hour-retention, other counters for stats.retention
+// @Tags         general
+// @Produce      json
+// @Param        key  path      string  true  "key, link:{code} for short link"
+// @Success      200  {object}  statsResponse
+// @Router       /{key}/stats [get]
+func (h *statsHandler) Stats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Param("key")
+		st := h.recorder.Stats(key)
+
+		c.JSON(http.StatusOK, statsResponse{
+			Key:       key,
+			Total:     st.Total,
+			Hours:     st.Hours,
+			Days:      st.Days,
+			Referrers: st.Referrers,
+			Agents:    st.Agents,
+			Countries: st.Countries,
+			ASNs:      st.ASNs,
+		})
+	}
+}
+
+func NewStatsHandler(recorder *stats.Recorder, logger *logrus.Entry) *statsHandler {
+	return &statsHandler{
+		logger:   logger,
+		recorder: recorder,
+	}
+}
@@ -0,0 +1,7 @@
+package http
+
+import "github.com/gin-gonic/gin"
+
+func NewStatsRoutes(group *gin.RouterGroup, h *statsHandler) {
+	group.GET("/:key/stats", h.Stats())
+}
@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// geoRange - range of addresses, announced by one AS
+type geoRange struct {
+	start   netip.Addr
+	end     netip.Addr
+	asn     string
+	country string
+}
+
+// geoDB - ip to country and asn database, loaded in memory. Ranges are sorted by start and do not overlap.
+type geoDB struct {
+	ranges []geoRange
+}
+
+// loadGeoDB - loads database in iptoasn.com tsv format: range_start, range_end, AS_number, country_code, AS_description.
+// Ranges, that are not routed (AS 0), are skipped.
+func loadGeoDB(path string) (*geoDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readGeoDB(f)
+}
+
+func readGeoDB(r io.Reader) (*geoDB, error) {
+	db := &geoDB{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("geo db line %d: want at least 4 fields, got: %d", line, len(fields))
+		}
+
+		if fields[2] == "0" {
+			continue
+		}
+
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("geo db line %d: %w", line, err)
+		}
+
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("geo db line %d: %w", line, err)
+		}
+
+		country := fields[3]
+		if country == "None" {
+			country = ""
+		}
+
+		db.ranges = append(db.ranges, geoRange{
+			start:   start.Unmap(),
+			end:     end.Unmap(),
+			asn:     "AS" + fields[2],
+			country: country,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+
+	return db, nil
+}
+
+// lookup - country and asn of address, ok is false, when address is not in any range
+func (db *geoDB) lookup(addr netip.Addr) (country string, asn string, ok bool) {
+	addr = addr.Unmap()
+
+	// First range, that starts after addr
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].start)
+	})
+
+	if i == 0 {
+		return "", "", false
+	}
+
+	r := db.ranges[i-1]
+	if r.end.Less(addr) {
+		return "", "", false
+	}
+	return r.country, r.asn, true
+}
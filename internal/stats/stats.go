@@ -0,0 +1,327 @@
+package stats
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	totalName   = "total"
+	hourPrefix  = "hour:"
+	dayPrefix   = "day:"
+	refPrefix   = "ref:"
+	agentPrefix = "ua:"
+	geoPrefix   = "country:"
+	asnPrefix   = "asn:"
+
+	// otherName - breakdown counter of hits, that are over cfg.MaxBreakdown distinct names
+	otherName = "other"
+
+	hourLayout = "2006-01-02T15"
+	dayLayout  = "2006-01-02"
+)
+
+// Hit - access of key. Hit is parsed by aggregator, so Record does not slow down request.
+type Hit struct {
+	Key       string
+	Time      time.Time
+	Referrer  string
+	UserAgent string
+	IP        string
+}
+
+// Stats - counters of key. Hours and Days are keyed by UTC time (2006-01-02T15 and 2006-01-02).
+type Stats struct {
+	Total     uint64
+	Hours     map[string]uint64
+	Days      map[string]uint64
+	Referrers map[string]uint64
+	Agents    map[string]uint64
+	Countries map[string]uint64
+	ASNs      map[string]uint64
+}
+
+// keyPrefix - prefix of counters of key. Length of key is a part of prefix,
+// so counters of key "a" are not found by prefix of key "a:b".
+func keyPrefix(key string) string {
+	return strconv.Itoa(len(key)) + ":" + key + ":"
+}
+
+// counter - delta of counter, that is not written to store yet
+type counter struct {
+	delta uint64
+	ttl   time.Duration
+}
+
+// Recorder - counts hits of keys. Hits are queued without blocking, aggregated in memory
+// and written to store every cfg.Flush, counters expire after retention.
+type Recorder struct {
+	store  *ttlstore.MapStore[string, uint64]
+	cfg    StatsConfig
+	logger *logrus.Entry
+	geo    *geoDB
+
+	hits    chan Hit
+	dropped atomic.Uint64
+	// fresh - number of breakdown names, that are pending, but not in store yet, by breakdown prefix
+	fresh map[string]int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+func NewRecorder(ctx context.Context, store *ttlstore.MapStore[string, uint64], logger *logrus.Entry, cfg StatsConfig) (*Recorder, error) {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = DEFAULT_BUFFER
+	}
+
+	if cfg.Flush <= 0 {
+		cfg.Flush = DEFAULT_FLUSH
+	}
+
+	if cfg.HourRetention <= 0 {
+		cfg.HourRetention = DEFAULT_HOUR_RETENTION
+	}
+
+	if cfg.Retention <= 0 {
+		cfg.Retention = DEFAULT_RETENTION
+	}
+
+	if cfg.MaxBreakdown <= 0 {
+		cfg.MaxBreakdown = DEFAULT_MAX_BREAKDOWN
+	}
+
+	r := &Recorder{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+		hits:   make(chan Hit, cfg.Buffer),
+		fresh:  make(map[string]int),
+		wg:     &sync.WaitGroup{},
+	}
+
+	if cfg.GeoDB != "" {
+		geo, err := loadGeoDB(cfg.GeoDB)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Infof("Loaded geo db with %d ranges.", len(geo.ranges))
+		r.geo = geo
+	}
+
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	return r, nil
+}
+
+// Record - queues hit, returns false, when buffer is full and hit is dropped
+func (r *Recorder) Record(h Hit) bool {
+	select {
+	case r.hits <- h:
+		return true
+	default:
+		r.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped - number of hits, dropped because buffer was full
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// count - adds counters of hit to pending deltas
+func (r *Recorder) count(pending map[string]*counter, h Hit) {
+	add := func(name string, ttl time.Duration) {
+		key := keyPrefix(h.Key) + name
+		if c, ok := pending[key]; ok {
+			c.delta++
+			return
+		}
+		pending[key] = &counter{delta: 1, ttl: ttl}
+	}
+
+	t := h.Time.UTC()
+
+	add(totalName, r.cfg.Retention)
+	add(hourPrefix+t.Format(hourLayout), r.cfg.HourRetention)
+	add(dayPrefix+t.Format(dayLayout), r.cfg.Retention)
+	add(refPrefix+r.breakdown(pending, h.Key, refPrefix, referrerHost(h.Referrer)), r.cfg.Retention)
+	add(agentPrefix+agentFamily(h.UserAgent), r.cfg.Retention)
+
+	if r.geo == nil {
+		return
+	}
+
+	addr, err := netip.ParseAddr(h.IP)
+	if err != nil {
+		return
+	}
+
+	if country, asn, ok := r.geo.lookup(addr); ok {
+		if country != "" {
+			add(geoPrefix+country, r.cfg.Retention)
+		}
+		add(asnPrefix+r.breakdown(pending, h.Key, asnPrefix, asn), r.cfg.Retention)
+	}
+}
+
+// breakdown - name of breakdown counter of key. Referrers and asns are set by clients, so breakdown keeps
+// at most cfg.MaxBreakdown distinct names, hits of new names over limit are counted as otherName.
+func (r *Recorder) breakdown(pending map[string]*counter, key string, group string, name string) string {
+	prefix := keyPrefix(key) + group
+	if _, ok := pending[prefix+name]; ok {
+		return name
+	}
+
+	if _, ok := r.store.Get(r.ctx, prefix+name); ok {
+		return name
+	}
+
+	n := r.fresh[prefix]
+	r.store.RangeBetween(prefix, prefix[:len(prefix)-1]+";", func(k string, _ uint64) bool {
+		if k != prefix+otherName {
+			n++
+		}
+		return n < r.cfg.MaxBreakdown
+	})
+
+	if n >= r.cfg.MaxBreakdown {
+		return otherName
+	}
+
+	r.fresh[prefix]++
+	return name
+}
+
+// flush - writes pending deltas to store in one update
+func (r *Recorder) flush(ctx context.Context, pending map[string]*counter) {
+	if len(pending) == 0 {
+		return
+	}
+
+	err := r.store.Update(ctx, func(get func(key string) (uint64, bool), tx *ttlstore.Tx[string, uint64]) error {
+		for key, c := range pending {
+			cur, _ := get(key)
+			tx.Set(key, cur+c.delta, c.ttl)
+		}
+		return nil
+	})
+
+	if err != nil {
+		r.logger.Errorf("got error while writing stats: %s", err.Error())
+	}
+
+	for key := range pending {
+		delete(pending, key)
+	}
+
+	for prefix := range r.fresh {
+		delete(r.fresh, prefix)
+	}
+}
+
+// Listen - aggregates hits until ctx is done, then writes hits, that are left in buffer
+func (r *Recorder) Listen(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(r.cfg.Flush)
+	defer ticker.Stop()
+
+	pending := make(map[string]*counter)
+	dropped := uint64(0)
+
+	for {
+		select {
+		case h := <-r.hits:
+			r.count(pending, h)
+		case <-ticker.C:
+			r.flush(ctx, pending)
+
+			if d := r.dropped.Load(); d > dropped {
+				r.logger.Warnf("%d hits were dropped, stats buffer is full.", d-dropped)
+				dropped = d
+			}
+		case <-ctx.Done():
+			for {
+				select {
+				case h := <-r.hits:
+					r.count(pending, h)
+				default:
+					// ctx is done, but recorded hits are still written
+					r.flush(context.Background(), pending)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stats - counters of key, written by last flush
+func (r *Recorder) Stats(key string) Stats {
+	st := Stats{
+		Hours:     make(map[string]uint64),
+		Days:      make(map[string]uint64),
+		Referrers: make(map[string]uint64),
+		Agents:    make(map[string]uint64),
+		Countries: make(map[string]uint64),
+		ASNs:      make(map[string]uint64),
+	}
+
+	prefix := keyPrefix(key)
+	// Every counter key of key is less than prefix with last ':' replaced by next symbol
+	end := prefix[:len(prefix)-1] + ";"
+
+	groups := []struct {
+		prefix string
+		to     map[string]uint64
+	}{
+		{hourPrefix, st.Hours},
+		{dayPrefix, st.Days},
+		{refPrefix, st.Referrers},
+		{agentPrefix, st.Agents},
+		{geoPrefix, st.Countries},
+		{asnPrefix, st.ASNs},
+	}
+
+	r.store.RangeBetween(prefix, end, func(key string, val uint64) bool {
+		name := strings.TrimPrefix(key, prefix)
+		if name == totalName {
+			st.Total = val
+			return true
+		}
+
+		for _, g := range groups {
+			if strings.HasPrefix(name, g.prefix) {
+				g.to[strings.TrimPrefix(name, g.prefix)] = val
+				break
+			}
+		}
+		return true
+	})
+
+	return st
+}
+
+func (r *Recorder) Run() {
+	r.wg.Add(1)
+	go r.Listen(r.ctx, r.wg)
+
+	r.logger.Info("Running...")
+}
+
+// Stop - stops aggregation, hits, that were recorded before Stop, are written to store
+func (r *Recorder) Stop() {
+	r.logger.Info("Stoping...")
+	r.cancel()
+	r.wg.Wait()
+}
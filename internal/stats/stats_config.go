@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"time"
+)
+
+const (
+	DEFAULT_BUFFER         = 4096
+	DEFAULT_FLUSH          = time.Second
+	DEFAULT_HOUR_RETENTION = 7 * 24 * time.Hour
+	DEFAULT_RETENTION      = 90 * 24 * time.Hour
+	DEFAULT_MAX_BREAKDOWN  = 100
+)
+
+type StatsConfig struct {
+	// Buffer - max number of hits, waiting for aggregation. Hits are dropped, when buffer is full
+	Buffer int `yaml:"buffer"`
+	// Flush - period, after which aggregated counters are written to store
+	Flush time.Duration `yaml:"flush"`
+	// HourRetention - ttl of hourly counters
+	HourRetention time.Duration `yaml:"hour-retention"`
+	// Retention - ttl of daily counters, total and breakdowns. Ttl of total and breakdowns is refreshed by every hit
+	Retention time.Duration `yaml:"retention"`
+	// MaxBreakdown - max number of distinct referrers and asns of key. Hits of others are counted as "other"
+	MaxBreakdown int `yaml:"max-breakdown"`
+	// GeoDB - path to ip to country and asn database in iptoasn.com tsv format. Empty path disables geo stats
+	GeoDB string `yaml:"geo-db"`
+}
+
+func newStatsConfig(Buffer int, Flush time.Duration, HourRetention time.Duration, Retention time.Duration, MaxBreakdown int, GeoDB string) StatsConfig {
+	return StatsConfig{
+		Buffer:        Buffer,
+		Flush:         Flush,
+		HourRetention: HourRetention,
+		Retention:     Retention,
+		MaxBreakdown:  MaxBreakdown,
+		GeoDB:         GeoDB,
+	}
+}
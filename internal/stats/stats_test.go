@@ -0,0 +1,216 @@
+package stats
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BON4/timedQ/pkg/ttlstore"
+	"github.com/sirupsen/logrus"
+)
+
+var logger = logrus.New()
+
+func TestMain(m *testing.M) {
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(os.Stdout)
+	os.Exit(m.Run())
+}
+
+const testGeoDB = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"2.0.0.0\t2.0.0.255\t0\tNone\tNot routed\n" +
+	"5.1.0.0\t5.1.255.255\t3215\tFR\tOrange\n" +
+	"2001:db8::\t2001:db8::ffff\t64500\tDE\tExample\n"
+
+func TestGeoLookup(t *testing.T) {
+	db, err := readGeoDB(strings.NewReader(testGeoDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][2]string{
+		"1.0.0.1":           {"US", "AS13335"},
+		"::ffff:5.1.2.3":    {"FR", "AS3215"},
+		"2001:db8::1":       {"DE", "AS64500"},
+		"2.0.0.1":           {"", ""},
+		"0.0.0.1":           {"", ""},
+		"5.2.0.1":           {"", ""},
+		"2001:db8::1:0:0:1": {"", ""},
+	}
+
+	for ip, want := range cases {
+		country, asn, _ := db.lookup(netip.MustParseAddr(ip))
+		if country != want[0] || asn != want[1] {
+			t.Errorf("%s: want %v, got: %s %s", ip, want, country, asn)
+		}
+	}
+
+	if _, err := readGeoDB(strings.NewReader("1.0.0.0\t1.0.0.255\n")); err == nil {
+		t.Error("Want error for malformed line")
+	}
+}
+
+func TestAgentFamily(t *testing.T) {
+	cases := map[string]string{
+		"": "Unknown",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36":                   "Chrome",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36 Edg/118.0.2088.46": "Edge",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15":             "Safari",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/118.0":                                                            "Firefox",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                                          "Bot",
+		"curl/8.4.0":         "curl",
+		"Go-http-client/1.1": "Other",
+	}
+
+	for ua, want := range cases {
+		if got := agentFamily(ua); got != want {
+			t.Errorf("%q: want %s, got: %s", ua, want, got)
+		}
+	}
+}
+
+func TestStatsRecord(t *testing.T) {
+	ctx := context.Background()
+
+	geo, err := os.CreateTemp("", "geo*.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(geo.Name())
+
+	geo.WriteString(testGeoDB)
+	geo.Close()
+
+	store := ttlstore.NewMapStore[string, uint64](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	r, err := NewRecorder(ctx, store, logger.WithField("service", "stats"), newStatsConfig(0, time.Millisecond*50, 0, 0, 0, geo.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Run()
+
+	now := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+	hits := []Hit{
+		{Key: "a", Time: now, Referrer: "https://News.example.com/post", UserAgent: "curl/8.4.0", IP: "1.0.0.7"},
+		{Key: "a", Time: now.Add(time.Hour), IP: "5.1.0.1"},
+		{Key: "a", Time: now.Add(24 * time.Hour), Referrer: ":bad", IP: "10.0.0.1"},
+		// Counters of other keys are not mixed with counters of a
+		{Key: "a:b", Time: now},
+		{Key: "ab", Time: now},
+	}
+
+	for _, h := range hits {
+		if !r.Record(h) {
+			t.Fatal("Want hit to be recorded")
+		}
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	st := r.Stats("a")
+	if st.Total != 3 {
+		t.Errorf("Want 3 hits, got: %d", st.Total)
+	}
+
+	want := map[string]map[string]uint64{
+		"hours":     {"2026-10-19T15": 1, "2026-10-19T16": 1, "2026-10-20T15": 1},
+		"days":      {"2026-10-19": 2, "2026-10-20": 1},
+		"referrers": {"news.example.com": 1, "direct": 1, "unknown": 1},
+		"agents":    {"curl": 1, "Unknown": 2},
+		"countries": {"US": 1, "FR": 1},
+		"asns":      {"AS13335": 1, "AS3215": 1},
+	}
+
+	got := map[string]map[string]uint64{
+		"hours":     st.Hours,
+		"days":      st.Days,
+		"referrers": st.Referrers,
+		"agents":    st.Agents,
+		"countries": st.Countries,
+		"asns":      st.ASNs,
+	}
+
+	for name, w := range want {
+		if len(got[name]) != len(w) {
+			t.Errorf("%s: want %v, got: %v", name, w, got[name])
+			continue
+		}
+
+		for k, v := range w {
+			if got[name][k] != v {
+				t.Errorf("%s: want %v, got: %v", name, w, got[name])
+				break
+			}
+		}
+	}
+
+	// Hits, recorded before Stop, are written
+	r.Record(Hit{Key: "a", Time: now})
+	r.Stop()
+
+	if st := r.Stats("a"); st.Total != 4 {
+		t.Errorf("Want 4 hits after stop, got: %d", st.Total)
+	}
+}
+
+func TestStatsMaxBreakdown(t *testing.T) {
+	ctx := context.Background()
+
+	store := ttlstore.NewMapStore[string, uint64](ctx, ttlstore.NewMapStoreConfig(time.Second/3, 1, "", false))
+	if err := store.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	r, err := NewRecorder(ctx, store, logger.WithField("service", "stats"), newStatsConfig(0, time.Millisecond*50, 0, 0, 3, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Run()
+	defer r.Stop()
+
+	now := time.Now()
+	record := func(refs ...string) {
+		for _, ref := range refs {
+			r.Record(Hit{Key: "a", Time: now, Referrer: ref, UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/" + ref})
+		}
+		time.Sleep(time.Millisecond * 200)
+	}
+
+	// Names over limit in one flush and in next flushes are counted as other, known names are still counted
+	record("https://a.com", "https://b.com", "https://c.com", "https://d.com")
+	record("https://e.com", "https://a.com")
+
+	st := r.Stats("a")
+	want := map[string]uint64{"a.com": 2, "b.com": 1, "c.com": 1, otherName: 2}
+	if len(st.Referrers) != len(want) {
+		t.Fatalf("Want referrers %v, got: %v", want, st.Referrers)
+	}
+
+	for k, v := range want {
+		if st.Referrers[k] != v {
+			t.Errorf("Want referrers %v, got: %v", want, st.Referrers)
+			break
+		}
+	}
+
+	// Agents are counted by family, so every version is one counter
+	if len(st.Agents) != 1 || st.Agents["Firefox"] != 6 {
+		t.Errorf("Want 6 Firefox hits, got: %v", st.Agents)
+	}
+
+	// Other keys have their own limit
+	r.Record(Hit{Key: "b", Time: now, Referrer: "https://d.com"})
+	time.Sleep(time.Millisecond * 200)
+
+	if st := r.Stats("b"); st.Referrers["d.com"] != 1 {
+		t.Errorf("Want d.com referrer of b, got: %v", st.Referrers)
+	}
+}
@@ -0,0 +1,96 @@
+package value
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// magic - prefix of value with metadata. It starts with zero byte, so it is not a prefix of text value.
+const magic = "\x00tqv1\x00"
+
+// Value - raw value with metadata of request, that stored it
+type Value struct {
+	ContentType     string
+	ContentEncoding string
+	Body            string
+}
+
+// WriteHeader - writes metadata of value to buf, body MUST be written right after it
+func WriteHeader(buf *bytes.Buffer, contentType string, contentEncoding string) {
+	var n [binary.MaxVarintLen64]byte
+
+	buf.WriteString(magic)
+	for _, s := range []string{contentType, contentEncoding} {
+		buf.Write(n[:binary.PutUvarint(n[:], uint64(len(s)))])
+		buf.WriteString(s)
+	}
+}
+
+// HeaderSize - size of metadata, written by WriteHeader
+func HeaderSize(contentType string, contentEncoding string) int {
+	var n [binary.MaxVarintLen64]byte
+	return len(magic) +
+		binary.PutUvarint(n[:], uint64(len(contentType))) + len(contentType) +
+		binary.PutUvarint(n[:], uint64(len(contentEncoding))) + len(contentEncoding)
+}
+
+// Encode - value with metadata
+func Encode(v Value) string {
+	buf := &bytes.Buffer{}
+	buf.Grow(HeaderSize(v.ContentType, v.ContentEncoding) + len(v.Body))
+	WriteHeader(buf, v.ContentType, v.ContentEncoding)
+	buf.WriteString(v.Body)
+	return buf.String()
+}
+
+// Decode - reverse of Encode. Value without metadata, for example set by batch or link, is returned as body,
+// its Content-Type is detected from body.
+func Decode(val string) Value {
+	if v, ok := cutHeader(val); ok {
+		return v
+	}
+	return Value{
+		ContentType: detect(val),
+		Body:        val,
+	}
+}
+
+func cutHeader(val string) (Value, bool) {
+	if !strings.HasPrefix(val, magic) {
+		return Value{}, false
+	}
+	rest := val[len(magic):]
+
+	var fields [2]string
+	for i := range fields {
+		l, n := binary.Uvarint([]byte(rest[:min(len(rest), binary.MaxVarintLen64)]))
+		if n <= 0 || uint64(len(rest)-n) < l {
+			return Value{}, false
+		}
+		fields[i], rest = rest[n:n+int(l)], rest[n+int(l):]
+	}
+
+	v := Value{
+		ContentType:     fields[0],
+		ContentEncoding: fields[1],
+		Body:            rest,
+	}
+	if v.ContentType == "" {
+		v.ContentType = detect(v.Body)
+	}
+	return v, true
+}
+
+// detect - Content-Type of body, only first 512 bytes are considered, so large body is not copied
+func detect(body string) string {
+	return http.DetectContentType([]byte(body[:min(len(body), 512)]))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,58 @@
+package value
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestValueEncode(t *testing.T) {
+	large := make([]byte, 5<<20)
+	rand.New(rand.NewSource(1)).Read(large)
+
+	for _, want := range []Value{
+		{ContentType: "application/json", Body: `{"a":1}`},
+		{ContentType: "image/png", ContentEncoding: "gzip", Body: "\x00\x01\x02"},
+		{ContentType: "application/octet-stream", ContentEncoding: "br", Body: string(large)},
+		{ContentType: "text/plain", Body: ""},
+	} {
+		got := Decode(Encode(want))
+		if got.ContentType != want.ContentType || got.ContentEncoding != want.ContentEncoding || got.Body != want.Body {
+			t.Errorf("Want %s %s of %d bytes, got: %s %s of %d bytes",
+				want.ContentType, want.ContentEncoding, len(want.Body), got.ContentType, got.ContentEncoding, len(got.Body))
+		}
+	}
+}
+
+func TestValueHeader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	WriteHeader(buf, "text/csv", "gzip")
+	if buf.Len() != HeaderSize("text/csv", "gzip") {
+		t.Errorf("Want header of %d bytes, got: %d", HeaderSize("text/csv", "gzip"), buf.Len())
+	}
+
+	buf.WriteString("a,b")
+	if got := Decode(buf.String()); got.ContentType != "text/csv" || got.ContentEncoding != "gzip" || got.Body != "a,b" {
+		t.Errorf("Want text/csv gzip a,b, got: %+v", got)
+	}
+}
+
+func TestValuePlain(t *testing.T) {
+	for val, contentType := range map[string]string{
+		"https://example.com": "text/plain; charset=utf-8",
+		"<html></html>":       "text/html; charset=utf-8",
+		"\x00tqv1":            "application/octet-stream",
+		// Broken header is not metadata
+		magic + "\x05a": "application/octet-stream",
+	} {
+		got := Decode(val)
+		if got.Body != val || got.ContentType != contentType || got.ContentEncoding != "" {
+			t.Errorf("Want plain %q of %s, got: %+v", val, contentType, got)
+		}
+	}
+
+	// Content-Type of value without it is detected from body
+	if got := Decode(Encode(Value{Body: "plain text"})); got.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("Want detected content type, got: %s", got.ContentType)
+	}
+}
@@ -1,6 +1,12 @@
 package coder
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math/rand"
 	"testing"
 )
 
@@ -11,3 +17,128 @@ type MapEntity[K string, V any] struct {
 
 // coder_test.go:25: 6d6170456e746974795b737472696e672c737472696e675d
 func TestCoder(t *testing.T) {}
+
+func TestCoderLargeValues(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	sizes := []int{10, 3 << 20, 100, SCAN_BUFFER_CAP, 8 << 20, 10, 10}
+	want := make([]MapEntity[string, []byte], len(sizes))
+	for i, size := range sizes {
+		val := make([]byte, size)
+		rnd.Read(val)
+		want[i] = MapEntity[string, []byte]{Key: fmt.Sprintf("key:%d", i), Val: val}
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := NewEncoder[MapEntity[string, []byte]](buf)
+	for i := range want {
+		if err := encoder.Encode(&want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []MapEntity[string, []byte]
+	if err := NewDecoder[MapEntity[string, []byte]](buf).Decode(func(ent *MapEntity[string, []byte]) {
+		got = append(got, *ent)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d records, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i].Key != want[i].Key || !bytes.Equal(got[i].Val, want[i].Val) {
+			t.Errorf("record %d does not match", i)
+		}
+	}
+}
+
+func TestCoderSeparatorInValue(t *testing.T) {
+	// Values contain name of type, which separated segments of previous version, and bytes around it
+	sep := []byte(getType(MapEntity[string, []byte]{}))
+	vals := [][]byte{
+		sep,
+		append(append([]byte{0, 1, 2, 3, 4, 5, 6}, sep...), sep...),
+		bytes.Repeat(sep, 1000),
+		{},
+		append([]byte("tail"), sep...),
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := NewEncoder[MapEntity[string, []byte]](buf)
+	for i, val := range vals {
+		if err := encoder.Encode(&MapEntity[string, []byte]{Key: string(sep) + fmt.Sprint(i), Val: val}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stream of other encoder is appended, as save daemon appends to file, written by Load
+	if err := NewEncoder[MapEntity[string, []byte]](buf).Encode(&MapEntity[string, []byte]{Key: "next", Val: sep}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []MapEntity[string, []byte]
+	if err := NewDecoder[MapEntity[string, []byte]](buf).Decode(func(ent *MapEntity[string, []byte]) {
+		got = append(got, *ent)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(vals)+1 {
+		t.Fatalf("decoded %d records, want %d", len(got), len(vals)+1)
+	}
+
+	for i, val := range vals {
+		if got[i].Key != string(sep)+fmt.Sprint(i) || !bytes.Equal(got[i].Val, val) {
+			t.Errorf("record %d does not match: %q", i, got[i].Val)
+		}
+	}
+
+	if got[len(vals)].Key != "next" || !bytes.Equal(got[len(vals)].Val, sep) {
+		t.Errorf("record of appended stream does not match: %+v", got[len(vals)])
+	}
+}
+
+func TestCoderCorrupted(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewEncoder[MapEntity[string, []byte]](buf).Encode(&MapEntity[string, []byte]{Key: "a", Val: []byte("val")}); err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if err := NewDecoder[MapEntity[string, []byte]](bytes.NewReader(truncated)).Decode(func(*MapEntity[string, []byte]) {}); err != io.ErrUnexpectedEOF {
+		t.Errorf("Want io.ErrUnexpectedEOF for truncated record, got: %v", err)
+	}
+
+	huge := binary.AppendUvarint([]byte{0}, SCAN_TOKEN_MAX+1)
+	if err := NewDecoder[MapEntity[string, []byte]](bytes.NewReader(huge)).Decode(func(*MapEntity[string, []byte]) {}); err != ErrRecordTooLarge {
+		t.Errorf("Want ErrRecordTooLarge, got: %v", err)
+	}
+}
+
+func TestCoderUnframed(t *testing.T) {
+	// Dump of previous version is gob stream without frames
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(MapEntity[string, []byte]{Key: fmt.Sprint(i), Val: []byte("val")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := 0
+	if err := NewDecoder[MapEntity[string, []byte]](buf).Decode(func(ent *MapEntity[string, []byte]) {
+		if ent.Key != fmt.Sprint(count) || string(ent.Val) != "val" {
+			t.Errorf("record %d does not match: %+v", count, ent)
+		}
+		count++
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("decoded %d records, want 3", count)
+	}
+}
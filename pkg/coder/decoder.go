@@ -3,12 +3,17 @@ package coder
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"io"
 	"reflect"
 )
 
+// ErrRecordTooLarge - length of record in stream exceeds SCAN_TOKEN_MAX, stream is corrupted
+var ErrRecordTooLarge = errors.New("record is too large")
+
 func getType[T any](myvar T) string {
 	if t := reflect.TypeOf(myvar); t.Kind() == reflect.Ptr {
 		return t.Elem().Name()
@@ -39,38 +44,103 @@ func getSlpitFunc(sep []byte, sep_len int) bufio.SplitFunc {
 }
 
 type Decoder[T any] struct {
-	decoder *gob.Decoder
-	buffer  *bufio.Scanner
-	entity  T
-	sep     []byte
+	r *bufio.Reader
 }
 
 // NewDecoder - creats new gob decoder wrapper.
-// separator - is hex encoded name-string of T
 func NewDecoder[T any](r io.Reader) *Decoder[T] {
-	buf := bufio.NewScanner(r)
+	return &Decoder[T]{
+		r: bufio.NewReader(r),
+	}
+}
 
-	var t T
+// Decode - calls callback for every record in stream. Stream of Encoder starts with zero length frame,
+// gob message never has zero length, so stream, that does not start with it, was written by previous
+// version of Encoder without frames.
+func (d *Decoder[T]) Decode(callback func(*T)) error {
+	first, err := d.r.Peek(1)
+	if err == io.EOF {
+		return nil
+	}
 
-	separator, _ := hex.DecodeString(hex.EncodeToString([]byte(getType(t))))
+	if err != nil {
+		return err
+	}
 
-	buf.Split(getSlpitFunc(separator, len(separator)))
+	if first[0] != 0 {
+		return d.decodeSeparated(callback)
+	}
 
-	return &Decoder[T]{
-		buffer: buf,
-		sep:    separator,
+	var (
+		dec     *gob.Decoder
+		segment = &bytes.Buffer{}
+	)
+
+	for {
+		size, err := binary.ReadUvarint(d.r)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if size == 0 {
+			segment.Reset()
+			dec = gob.NewDecoder(segment)
+			continue
+		}
+
+		if size > SCAN_TOKEN_MAX {
+			return ErrRecordTooLarge
+		}
+
+		if dec == nil {
+			return errors.New("record before start of segment")
+		}
+
+		segment.Reset()
+		if _, err := io.CopyN(segment, d.r, int64(size)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		// gob leaves fields that are zero in stream untouched, so every record is decoded to new entity
+		var entity T
+		if err := dec.Decode(&entity); err != nil {
+			return err
+		}
+		callback(&entity)
 	}
 }
 
-func (d *Decoder[T]) Decode(callback func(*T)) error {
+// decodeSeparated - decodes stream of previous version of Encoder, where segments are separated by name of T
+func (d *Decoder[T]) decodeSeparated(callback func(*T)) error {
+	buf := bufio.NewScanner(d.r)
+	// Scanner buffer grows up to SCAN_TOKEN_MAX, so records larger than SCAN_BUFFER_CAP are not lost
+	buf.Buffer(make([]byte, 0, SCAN_BUFFER_CAP), SCAN_TOKEN_MAX)
+
+	var t T
+	// separator - is hex encoded name-string of T
+	sep, _ := hex.DecodeString(hex.EncodeToString([]byte(getType(t))))
+	buf.Split(getSlpitFunc(sep, len(sep)))
+
 	var entity T
 	var pre_separator []byte
-	for d.buffer.Scan() {
-		b := d.buffer.Bytes()
+	for buf.Scan() {
+		b := buf.Bytes()
 		if len(b) > 8 {
 			encoded := append(pre_separator, bytes.TrimRight(b, string(pre_separator))...)
 			dec := gob.NewDecoder(bytes.NewReader(encoded))
 			for {
+				// gob leaves fields that are zero in stream untouched, reset entity
+				// so values of previous record do not leak into the next one
+				var zero T
+				entity = zero
+
 				if err := dec.Decode(&entity); err != nil {
 					if err == io.EOF {
 						break
@@ -85,9 +155,9 @@ func (d *Decoder[T]) Decode(callback func(*T)) error {
 			// Store pre_separator value
 			pre_separator = make([]byte, len(b))
 			copy(pre_separator, b)
-			pre_separator = append(pre_separator, d.sep...)
+			pre_separator = append(pre_separator, sep...)
 		}
 	}
 
-	return nil
+	return buf.Err()
 }
@@ -2,16 +2,24 @@ package coder
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
 	"io"
 )
 
-// Can be changed, by increceing scanner buffer size manualy
+// SCAN_BUFFER_CAP - size of encoder segment, gob encoder is reset, when it is exceeded.
+// Segment with single record may be larger, decoder accepts records up to SCAN_TOKEN_MAX
 const SCAN_BUFFER_CAP = 64 * 1024
 
+// SCAN_TOKEN_MAX - max size of record accepted by decoder
+const SCAN_TOKEN_MAX = 1 << 30
+
+// Encoder - writes gob records framed by uvarint length, so value of record can contain any bytes.
+// Zero length frame starts new segment: gob encoder is reset, and type of T is sent again.
 type Encoder[T any] struct {
 	encoder    *gob.Encoder
 	sizeWriter *bytes.Buffer
+	frame      []byte
 	w          io.Writer
 
 	bytesCounter uint64
@@ -19,33 +27,44 @@ type Encoder[T any] struct {
 }
 
 func NewEncoder[T any](w io.Writer) *Encoder[T] {
-	b := bytes.NewBuffer([]byte{})
 	return &Encoder[T]{
-		sizeWriter: b,
+		sizeWriter: bytes.NewBuffer([]byte{}),
 		w:          w,
-		encoder:    gob.NewEncoder(io.MultiWriter(w, b)),
 	}
 }
 
 func (c *Encoder[T]) Encode(data *T) error {
 	// Reset Encoder
-	if c.bytesCounter+c.objectSize >= SCAN_BUFFER_CAP {
-		// fmt.Println("RESET")
-		c.encoder = gob.NewEncoder(io.MultiWriter(c.w, c.sizeWriter))
+	if c.encoder == nil || c.bytesCounter+c.objectSize >= SCAN_BUFFER_CAP {
+		c.encoder = gob.NewEncoder(c.sizeWriter)
 		c.bytesCounter = 0
+		c.frame = append(c.frame[:0], 0)
+	} else {
+		c.frame = c.frame[:0]
 	}
 
+	defer c.sizeWriter.Reset()
+
 	if err := c.encoder.Encode(*data); err != nil {
-		//TODO: Log err
+		// Type can be sent by failed record only, next record starts new segment
+		c.encoder = nil
 		return err
 	}
 
-	c.bytesCounter += uint64(c.sizeWriter.Len())
+	size := c.sizeWriter.Len()
+	c.frame = binary.AppendUvarint(c.frame, uint64(size))
+	c.frame = append(c.frame, c.sizeWriter.Bytes()...)
+
+	// Frame is written at once, so record is either written or failed
+	if _, err := c.w.Write(c.frame); err != nil {
+		c.encoder = nil
+		return err
+	}
+
+	c.bytesCounter += uint64(size)
 
 	if c.objectSize == 0 {
 		c.objectSize = c.bytesCounter
 	}
-
-	c.sizeWriter.Reset()
 	return nil
 }
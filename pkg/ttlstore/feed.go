@@ -0,0 +1,214 @@
+package ttlstore
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType uint8
+
+const (
+	EventSet EventType = iota
+	EventRefresh
+	EventDelete
+	EventExpire
+)
+
+func (et EventType) String() string {
+	switch et {
+	case EventSet:
+		return "set"
+	case EventRefresh:
+		return "refresh"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+// Event - change of single key. Val is empty for delete events, expire events carry last value.
+type Event[K string, V any] struct {
+	Type EventType
+	Key  K
+	Val  V
+	Time time.Time
+}
+
+// OverflowPolicy - what happens with subscription, when its buffer is full
+type OverflowPolicy uint8
+
+const (
+	// DropEvents - new events are dropped and counted, until subscriber frees buffer
+	DropEvents OverflowPolicy = iota
+	// Disconnect - subscription is closed, subscriber is notified with Done
+	Disconnect
+)
+
+const DEFAULT_WATCH_BUFFER = 64
+
+type WatchConfig[K string] struct {
+	// Key - if not empty, only events of this key are delivered, Prefix is ignored
+	Key    K
+	Prefix K
+	Buffer int
+	Policy OverflowPolicy
+}
+
+// Subscription - bounded stream of events, that match config. One subscription can watch many stores.
+type Subscription[K string, V any] struct {
+	cfg     WatchConfig[K]
+	events  chan Event[K, V]
+	done    chan struct{}
+	once    *sync.Once
+	dropped atomic.Uint64
+
+	mu    *sync.Mutex
+	feeds []*feed[K, V]
+}
+
+func NewSubscription[K string, V any](cfg WatchConfig[K]) *Subscription[K, V] {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = DEFAULT_WATCH_BUFFER
+	}
+
+	return &Subscription[K, V]{
+		cfg:    cfg,
+		events: make(chan Event[K, V], cfg.Buffer),
+		done:   make(chan struct{}),
+		once:   &sync.Once{},
+		mu:     &sync.Mutex{},
+	}
+}
+
+// Events - stream of events. Channel is never closed, use Done to detect end of subscription.
+func (s *Subscription[K, V]) Events() <-chan Event[K, V] {
+	return s.events
+}
+
+// Done - closed after Close was called, or subscriber was disconnected by Disconnect policy
+func (s *Subscription[K, V]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped - count of events, that were dropped by DropEvents policy
+func (s *Subscription[K, V]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close - unsubscribes from every store. Events that are already in buffer can still be read.
+func (s *Subscription[K, V]) Close() {
+	s.mu.Lock()
+	feeds := s.feeds
+	s.feeds = nil
+	s.mu.Unlock()
+
+	for _, f := range feeds {
+		f.remove(s)
+	}
+
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *Subscription[K, V]) match(key K) bool {
+	if len(s.cfg.Key) > 0 {
+		return key == s.cfg.Key
+	}
+	return strings.HasPrefix(string(key), string(s.cfg.Prefix))
+}
+
+// publish - never blocks, applies overflow policy when buffer is full
+func (s *Subscription[K, V]) publish(e Event[K, V]) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	select {
+	case s.events <- e:
+	default:
+		switch s.cfg.Policy {
+		case Disconnect:
+			s.once.Do(func() {
+				close(s.done)
+			})
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// feed - subscriptions of one store
+type feed[K string, V any] struct {
+	mu   *sync.RWMutex
+	subs map[*Subscription[K, V]]struct{}
+}
+
+func newFeed[K string, V any]() *feed[K, V] {
+	return &feed[K, V]{
+		mu:   &sync.RWMutex{},
+		subs: make(map[*Subscription[K, V]]struct{}),
+	}
+}
+
+func (f *feed[K, V]) add(s *Subscription[K, V]) {
+	f.mu.Lock()
+	f.subs[s] = struct{}{}
+	f.mu.Unlock()
+}
+
+func (f *feed[K, V]) remove(s *Subscription[K, V]) {
+	f.mu.Lock()
+	delete(f.subs, s)
+	f.mu.Unlock()
+}
+
+func (f *feed[K, V]) publish(et EventType, key K, val V) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if len(f.subs) == 0 {
+		return
+	}
+
+	e := Event[K, V]{
+		Type: et,
+		Key:  key,
+		Val:  val,
+		Time: time.Now(),
+	}
+
+	for s := range f.subs {
+		if s.match(key) {
+			s.publish(e)
+		}
+	}
+}
+
+// Subscribe - starts delivering events of this store to subscription
+func (ms *MapStore[K, V]) Subscribe(s *Subscription[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	s.feeds = append(s.feeds, ms.feed)
+	ms.feed.add(s)
+}
+
+// Watch - creates subscription to changes of this store
+func (ms *MapStore[K, V]) Watch(cfg WatchConfig[K]) *Subscription[K, V] {
+	s := NewSubscription[K, V](cfg)
+	ms.Subscribe(s)
+	return s
+}
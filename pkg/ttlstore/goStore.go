@@ -14,30 +14,67 @@ import (
 
 const DEFAULT_DUMP_NAME = ".temp.db"
 
+type OpType uint8
+
+const (
+	OpSet OpType = iota
+	OpDelete
+	// OpTouch - same as OpSet, but only ttl of existing entity has been changed
+	OpTouch
+	// OpRev - highest revision, that has been assigned by store. It is written by Load, when it is higher
+	// than revisions of kept keys, so revisions of deleted and expired keys are not assigned again after restart.
+	OpRev
+)
+
+// MapEntity - record of dump file
 type MapEntity[K string, V any] struct {
 	Key K
 	Val V
+	Op  OpType
+
+	// Tx - writes of transaction. If not empty, record is applied as a whole and Key, Val, Op are ignored
+	Tx []TxEntity[K, V]
+}
+
+// TxEntity - single write of transaction
+type TxEntity[K string, V any] struct {
+	Key K
+	Val V
+	Op  OpType
 }
 
 type MapStore[K string, V any] struct {
 	wg       *sync.WaitGroup
 	cancel   context.CancelFunc
 	store    *sync.Map
+	index    *keyIndex[K, V]
+	feed     *feed[K, V]
+	mu       *sync.Mutex
 	ctx      context.Context
 	save     chan MapEntity[K, TTLStoreEntity[V]]
+	syncs    chan chan error
 	cfg      TTLStoreConfig
 	dump     *os.File
 	dumpPath string
+	// rev - the highest revision, that has been assigned, also to keys, that are deleted already. Guarded by mu
+	rev uint64
 }
 
-// runSaveDaemon - saves data to file, stops after closed channel encountered
-func runSaveDaemon[K string, V any](kv chan MapEntity[K, TTLStoreEntity[V]], wg *sync.WaitGroup, file io.Writer) {
-	wg.Add(1)
-
+// runSaveDaemon - saves data to file, stops after closed channel encountered.
+// Sync request is answered after every record, queued before it, is written and file is synced.
+// WARNING: wg.Add shoud be called before daemon is started
+func runSaveDaemon[K string, V any](kv chan MapEntity[K, TTLStoreEntity[V]], syncs chan chan error, wg *sync.WaitGroup, file io.Writer) {
 	defer wg.Done()
 
 	encoder := coder.NewEncoder[MapEntity[K, TTLStoreEntity[V]]](file)
 
+	encode := func(data MapEntity[K, TTLStoreEntity[V]]) {
+		if err := encoder.Encode(&data); err != nil {
+			//TODO: Log here
+			panic(err)
+		}
+	}
+
 	for {
 		select {
 		case data, ok := <-kv:
@@ -45,28 +82,44 @@ func runSaveDaemon[K string, V any](kv chan MapEntity[K, TTLStoreEntity[V]], wg
 				return
 			}
 
-			if err := encoder.Encode(&data); err != nil {
-				//TODO: Log here
-				panic(err)
+			encode(data)
+		case done := <-syncs:
+			// Records, committed before sync was requested, are already in channel
+		drain:
+			for {
+				select {
+				case data, ok := <-kv:
+					if !ok {
+						done <- ErrStoreClosed
+						return
+					}
+					encode(data)
+				default:
+					break drain
+				}
 			}
+
+			var err error
+			if f, ok := file.(interface{ Sync() error }); ok {
+				err = f.Sync()
+			}
+			done <- err
 		}
 	}
 }
 
-func runGcDaemon[K string, V any](ctx context.Context, store *sync.Map, wg *sync.WaitGroup, dRt time.Duration) {
-	wg.Add(1)
-
+// WARNING: wg.Add shoud be called before daemon is started
+func runGcDaemon[K string, V any](ctx context.Context, ms *MapStore[K, V], wg *sync.WaitGroup, dRt time.Duration) {
 	defer wg.Done()
 
 	tiker := time.NewTicker(dRt)
 	for {
 		select {
 		case <-tiker.C:
-			store.Range(func(k, v any) bool {
+			ms.store.Range(func(k, v any) bool {
 				if val, ok := v.(TTLStoreEntity[V]); ok {
-					eTime := val.GetTTL()
-					if !(eTime <= 0) && eTime < time.Now().Unix() {
-						store.Delete(k)
+					if val.expired(time.Now().Unix()) {
+						ms.deleteExpired(k.(K))
 					}
 				}
 				// else {
@@ -88,13 +141,17 @@ func NewMapStore[K string, V any](ctx context.Context, cfg TTLStoreConfig) *MapS
 
 	ms := &MapStore[K, V]{
 		store:  &sync.Map{},
+		index:  newKeyIndex[K, V](),
+		feed:   newFeed[K, V](),
+		mu:     &sync.Mutex{},
 		ctx:    msctx,
 		cancel: cancel,
 		//TODO: CHANEL SIZE?
-		save: make(chan MapEntity[K, TTLStoreEntity[V]], 100),
-		cfg:  cfg,
-		dump: nil,
-		wg:   &sync.WaitGroup{},
+		save:  make(chan MapEntity[K, TTLStoreEntity[V]], 100),
+		syncs: make(chan chan error),
+		cfg:   cfg,
+		dump:  nil,
+		wg:    &sync.WaitGroup{},
 	}
 
 	dir, fname := filepath.Split(cfg.SavePath)
@@ -104,7 +161,8 @@ func NewMapStore[K string, V any](ctx context.Context, cfg TTLStoreConfig) *MapS
 		ms.dumpPath = cfg.SavePath
 	}
 
-	go runGcDaemon[K, V](ms.ctx, ms.store, ms.wg, ms.cfg.GCRefresh)
+	ms.wg.Add(1)
+	go runGcDaemon[K, V](ms.ctx, ms, ms.wg, ms.cfg.GCRefresh)
 	return ms
 }
 
@@ -124,7 +182,8 @@ func (ms *MapStore[K, V]) Run() error {
 			return err
 		}
 
-		go runSaveDaemon[K, V](ms.save, ms.wg, ms.dump)
+		ms.wg.Add(1)
+		go runSaveDaemon[K, V](ms.save, ms.syncs, ms.wg, ms.dump)
 	}
 
 	return nil
@@ -137,8 +196,11 @@ func (ms *MapStore[K, V]) Close() error {
 	//and prevents Set method
 	ms.cancel()
 
-	//this will stop save daemon
+	//this will stop save daemon,
+	//lock guarantees that no one is sending to save chan
+	ms.mu.Lock()
 	close(ms.save)
+	ms.mu.Unlock()
 
 	//wait for daemons
 	ms.wg.Wait()
@@ -152,14 +214,8 @@ func (ms *MapStore[K, V]) Close() error {
 // Load - loads all contents from file to internal map, then clears a file and dump all contents to fresh file
 // WRRNING: Load shoud be called before Run
 func (ms *MapStore[K, V]) Load() error {
-	// Using custom split function we will get output in bytes where:
-	// FIRST SCAN:
-	// *******@mapEntity
-	// ^_____^ - this is pre_separator (always len of 7)
-	//
-	// SECOND SCAN:
-	// separator ... pre_sepator
-	// ..............^__________ - now pre_separator will be at the end, we need to trim it from end and append it to start.
+	// Records are framed by length, see coder.Encoder. Dump of previous version is decoded too,
+	// and it is rewritten in framed format below.
 	if ms.cfg.Save {
 		var err error
 		reader, err := os.OpenFile(ms.dumpPath, os.O_CREATE|os.O_RDONLY, 0666)
@@ -167,11 +223,16 @@ func (ms *MapStore[K, V]) Load() error {
 			return err
 		}
 
+		// Decoding stops at first broken record, usually tail of write, torn by crash. Records before it are kept,
+		// and file is rewritten below, so new records are not appended after broken one.
 		decoder := coder.NewDecoder[MapEntity[K, TTLStoreEntity[V]]](reader)
 		if err := decoder.Decode(func(ent *MapEntity[K, TTLStoreEntity[V]]) {
-			ms.store.Store(ent.Key, ent.Val)
+			ms.mu.Lock()
+			ms.apply(*ent, true)
+			ms.mu.Unlock()
 		}); err != nil {
-			return err
+			//TODO: Propper logger
+			fmt.Printf("Storage file %s is broken, records after first broken one are dropped: %s\n", ms.dumpPath, err.Error())
 		}
 
 		if err := reader.Close(); err != nil {
@@ -186,18 +247,19 @@ func (ms *MapStore[K, V]) Load() error {
 		defer writer.Close()
 		encoder := coder.NewEncoder[MapEntity[K, TTLStoreEntity[V]]](writer)
 
-		ms.store.Range(func(key any, val any) bool {
-			if okKey, ok := key.(K); ok {
-				if okVal, ok := val.(TTLStoreEntity[V]); ok {
-					if err = encoder.Encode(&MapEntity[K, TTLStoreEntity[V]]{
-						Key: okKey,
-						Val: okVal,
-					}); err != nil {
-						//TODO: Propper logger
-						fmt.Printf("Error while updating storage file: %s\n", err.Error())
-						return false
-					}
-				}
+		var written uint64
+		ms.Snapshot().rangeEntities(func(key K, val TTLStoreEntity[V]) bool {
+			if err = encoder.Encode(&MapEntity[K, TTLStoreEntity[V]]{
+				Key: key,
+				Val: val,
+			}); err != nil {
+				//TODO: Propper logger
+				fmt.Printf("Error while updating storage file: %s\n", err.Error())
+				return false
+			}
+
+			if val.Rev > written {
+				written = val.Rev
 			}
 			return true
 		})
@@ -205,59 +267,279 @@ func (ms *MapStore[K, V]) Load() error {
 		if err != nil {
 			return err
 		}
+
+		ms.mu.Lock()
+		rev := ms.rev
+		ms.mu.Unlock()
+
+		// Deleted or expired key had the highest revision, so it is kept by separate record
+		if rev > written {
+			if err := encoder.Encode(&MapEntity[K, TTLStoreEntity[V]]{
+				Val: TTLStoreEntity[V]{Rev: rev},
+				Op:  OpRev,
+			}); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 func (ms *MapStore[K, V]) Set(_ context.Context, key K, val V, ttl time.Duration) error {
-	var t int64 = -1
 	if ttl == 0 {
 		return nil
-	} else if ttl > 0 {
-		t = time.Now().Add(ttl).Unix()
 	}
 
-	se := TTLStoreEntity[V]{
-		Entity: val,
+	return ms.commit(MapEntity[K, TTLStoreEntity[V]]{
+		Key: key,
+		Val: newTTLStoreEntity(val, ttl),
+		Op:  OpSet,
+	})
+}
+
+// SetFixed - same as Set, but ttl of key is not refreshed by Touch
+func (ms *MapStore[K, V]) SetFixed(_ context.Context, key K, val V, ttl time.Duration) error {
+	if ttl == 0 {
+		return nil
+	}
+
+	ent := newTTLStoreEntity(val, ttl)
+	ent.Fixed = true
+
+	return ms.commit(MapEntity[K, TTLStoreEntity[V]]{
+		Key: key,
+		Val: ent,
+		Op:  OpSet,
+	})
+}
+
+func (ms *MapStore[K, V]) Delete(_ context.Context, key K) error {
+	return ms.commit(MapEntity[K, TTLStoreEntity[V]]{
+		Key: key,
+		Op:  OpDelete,
+	})
+}
+
+// Touch - updates ttl of existing key, without changing its value. Returns false if key is not present,
+// or its ttl is fixed by SetFixed.
+func (ms *MapStore[K, V]) Touch(_ context.Context, key K, ttl time.Duration) (bool, error) {
+	if ttl == 0 {
+		return false, nil
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	val, ok := ms.store.Load(key)
+	if !ok {
+		return false, nil
+	}
+
+	ent, ok := val.(TTLStoreEntity[V])
+	if !ok || ent.expired(time.Now().Unix()) || ent.Fixed {
+		return false, nil
+	}
+
+	touched := newTTLStoreEntity(ent.Entity, ttl)
+	// Value is not changed, so revision is kept
+	touched.Rev = ent.Rev
+
+	if err := ms.commitLocked(MapEntity[K, TTLStoreEntity[V]]{
+		Key: key,
+		Val: touched,
+		Op:  OpTouch,
+	}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Persistent - writes are saved to file, so they can be synced
+func (ms *MapStore[K, V]) Persistent() bool {
+	return ms.cfg.Save
+}
+
+// Sync - waits until every write, made before call, is written to file and synced to disk.
+// Returns at once, when store is not saved to file.
+func (ms *MapStore[K, V]) Sync(ctx context.Context) error {
+	if !ms.cfg.Save {
+		return nil
 	}
 
-	se.SetTTL(t)
-	ms.store.Store(key, se)
+	done := make(chan error, 1)
 
-	if ms.cfg.Save && ms.ctx.Err() == nil {
-		ms.save <- MapEntity[K, TTLStoreEntity[V]]{Key: key, Val: se}
+	select {
+	case ms.syncs <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ms.ctx.Done():
+		return ErrStoreClosed
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// commit - applies record to memory and passes it to save daemon.
+// Both happens under one lock, so records in file are in the same order as writes.
+func (ms *MapStore[K, V]) commit(rec MapEntity[K, TTLStoreEntity[V]]) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.commitLocked(rec)
+}
+
+// commitLocked - same as commit, MUST be called under ms.mu
+func (ms *MapStore[K, V]) commitLocked(rec MapEntity[K, TTLStoreEntity[V]]) error {
+	return ms.commitRecord(rec, true)
+}
+
+// commitRecord - same as commitLocked, subscribers are notified only if notify is true.
+// Records, that move keys between stores, do not change keys for readers, so they are not published.
+// MUST be called under ms.mu
+func (ms *MapStore[K, V]) commitRecord(rec MapEntity[K, TTLStoreEntity[V]], notify bool) error {
+	if ms.ctx.Err() != nil {
+		return ErrStoreClosed
+	}
+
+	// Revisions are assigned before record is saved, so they are the same after Load
+	if len(rec.Tx) == 0 {
+		ms.assignRev(&rec.Val, rec.Op)
+	}
+	for i := range rec.Tx {
+		ms.assignRev(&rec.Tx[i].Val, rec.Tx[i].Op)
+	}
+
+	ms.apply(rec, notify)
+
+	if ms.cfg.Save {
+		ms.save <- rec
 	}
 
 	return nil
 }
 
-func (ms *MapStore[K, V]) Get(_ context.Context, key K) (V, bool) {
+// assignRev - assigns next revision to written entity without revision, MUST be called under ms.mu.
+// Moved and touched entities keep their revision.
+func (ms *MapStore[K, V]) assignRev(ent *TTLStoreEntity[V], op OpType) {
+	if op != OpDelete && ent.Rev == 0 {
+		ms.rev++
+		ent.Rev = ms.rev
+	}
+}
+
+// apply - applies record to memory and notifies subscribers, if notify is true. MUST be called under ms.mu.
+// New version of index is published once per record, so snapshots never observe part of transaction.
+func (ms *MapStore[K, V]) apply(rec MapEntity[K, TTLStoreEntity[V]], notify bool) {
+	ops := rec.Tx
+	if len(ops) == 0 {
+		ops = []TxEntity[K, TTLStoreEntity[V]]{{Key: rec.Key, Val: rec.Val, Op: rec.Op}}
+	}
+
+	root := ms.index.root.Load()
+	for _, op := range ops {
+		switch op.Op {
+		case OpRev:
+			if op.Val.Rev > ms.rev {
+				ms.rev = op.Val.Rev
+			}
+		case OpDelete:
+			ms.store.Delete(op.Key)
+			root = ms.index.without(root, op.Key)
+		default:
+			ms.store.Store(op.Key, op.Val)
+			root = ms.index.with(root, op.Key, op.Val)
+			// Entities, loaded from file or moved from other store, can have higher revision
+			if op.Val.Rev > ms.rev {
+				ms.rev = op.Val.Rev
+			}
+		}
+	}
+
+	ms.index.root.Store(root)
+
+	if !notify {
+		return
+	}
+
+	for _, op := range ops {
+		switch op.Op {
+		case OpRev:
+			// Revision is not a key, readers are not notified
+		case OpDelete:
+			ms.feed.publish(EventDelete, op.Key, op.Val.Entity)
+		case OpTouch:
+			ms.feed.publish(EventRefresh, op.Key, op.Val.Entity)
+		default:
+			ms.feed.publish(EventSet, op.Key, op.Val.Entity)
+		}
+	}
+}
+
+func (ms *MapStore[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	val, _, ok := ms.GetRev(ctx, key)
+	return val, ok
+}
+
+// GetRev - same as Get, also returns revision of key
+func (ms *MapStore[K, V]) GetRev(_ context.Context, key K) (V, uint64, bool) {
 	var ent TTLStoreEntity[V]
 	if val, ok := ms.store.Load(key); ok {
 		if ent, ok := val.(TTLStoreEntity[V]); ok {
-			eTime := ent.GetTTL()
-			// 0 | 0 -> 0
-			// 1 | 0 -> 1
-			// 0 | 1 -> 1
-			// 1 | 1 -> 1
-			//fmt.Printf("Found with Get: %v\n", ent.Entity)
-			if eTime > time.Now().Unix() || (eTime <= 0) {
-				return ent.Entity, true
-			} else {
-				panic(fmt.Sprintf("Cant assert, got: %+v", val))
+			// Entity can be expired, but not yet collected by gc daemon
+			if !ent.expired(time.Now().Unix()) {
+				return ent.Entity, ent.Rev, true
 			}
 		}
 	}
-	return ent.Entity, false
+	return ent.Entity, 0, false
 }
 
-func (ms *MapStore[K, V]) Range(f func(key K, val V) bool) {
-	ms.store.Range(func(key any, value any) bool {
-		if okKey, ok := key.(K); ok {
-			if okVal, ok := value.(TTLStoreEntity[V]); ok {
-				return f(okKey, okVal.Entity)
+// Expires - time, when key expires, zero time when key never expires. Returns false if key is not present.
+func (ms *MapStore[K, V]) Expires(_ context.Context, key K) (time.Time, bool) {
+	if val, ok := ms.store.Load(key); ok {
+		if ent, ok := val.(TTLStoreEntity[V]); ok && !ent.expired(time.Now().Unix()) {
+			if ent.TTL <= 0 {
+				return time.Time{}, true
 			}
+			return time.Unix(ent.TTL, 0), true
 		}
-		return true
-	})
+	}
+	return time.Time{}, false
+}
+
+// deleteExpired - removes key from store, only if it is still expired at the moment of deletion
+func (ms *MapStore[K, V]) deleteExpired(key K) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if val, ok := ms.store.Load(key); ok {
+		if ent, ok := val.(TTLStoreEntity[V]); ok && ent.expired(time.Now().Unix()) {
+			ms.store.Delete(key)
+			ms.index.root.Store(ms.index.without(ms.index.root.Load(), key))
+			ms.feed.publish(EventExpire, key, ent.Entity)
+		}
+	}
+}
+
+// Range - calls f for every key in ascending order, until f returns false. Iterates over snapshot of store.
+func (ms *MapStore[K, V]) Range(f func(key K, val V) bool) {
+	ms.Snapshot().Range(f)
+}
+
+// Scan - returns up to limit keys with provided prefix in ascending order, starting after cursor.
+// Empty cursor means start from the first key. Returned cursor is empty when there is no more keys.
+func (ms *MapStore[K, V]) Scan(prefix K, cursor K, limit int) ([]K, K) {
+	return ms.Snapshot().Scan(prefix, cursor, limit)
+}
+
+// RangeBetween - calls f for every key in [start, end) in ascending order, until f returns false.
+// Empty end means there is no upper bound.
+func (ms *MapStore[K, V]) RangeBetween(start, end K, f func(key K, val V) bool) {
+	ms.Snapshot().RangeBetween(start, end, f)
 }
@@ -2,15 +2,18 @@ package ttlstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"golang.org/x/exp/constraints"
 
 	models "github.com/BON4/timedQ/internal/models"
+	"github.com/BON4/timedQ/pkg/coder"
 )
 
 type ListStruct[T constraints.Ordered] struct {
@@ -367,6 +370,313 @@ func TestMapLoad(t *testing.T) {
 
 }
 
+func TestMapLoadLargeValue(t *testing.T) {
+	filename := "#temp_large.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := NewMapStoreConfig(time.Second/3, 1, filename, true)
+
+	rnd := rand.New(rand.NewSource(1))
+	want := map[string]string{}
+	for i, size := range []int{5 << 20, 100, 3 << 20} {
+		val := make([]byte, size)
+		rnd.Read(val)
+		want[fmt.Sprintf("%d", i)] = string(val)
+	}
+
+	ms := NewMapStore[string, string](context.Background(), cfg)
+	if err := ms.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, val := range want {
+		if err := ms.Set(context.Background(), key, val, -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(time.Second / 2)
+	ms.Close()
+
+	newMs := NewMapStore[string, string](context.Background(), cfg)
+	if err := newMs.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer newMs.Close()
+
+	if err := newMs.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, val := range want {
+		got, ok := newMs.Get(context.Background(), key)
+		if !ok {
+			t.Errorf("key %s was not loaded", key)
+			continue
+		}
+		if got != val {
+			t.Errorf("value of key %s does not match, got %d bytes, want %d", key, len(got), len(val))
+		}
+	}
+}
+
+func TestMapScan(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewMapStoreConfig(time.Second/3, 1, "#temp.db", false)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	defer ms.Close()
+
+	for i := 0; i < 25; i++ {
+		if err := ms.Set(ctx, fmt.Sprintf("user:42:%02d", i), "v", -1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	for _, k := range []string{"user:41:00", "user:43:00", "a", "z"} {
+		if err := ms.Set(ctx, k, "v", -1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for {
+		keys, next := ms.Scan("user:42:", cursor, 10)
+		got = append(got, keys...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(got) != 25 {
+		t.Errorf("Want 25 keys, got: %d", len(got))
+		return
+	}
+
+	for i, k := range got {
+		if want := fmt.Sprintf("user:42:%02d", i); k != want {
+			t.Errorf("Want %s, got: %s", want, k)
+		}
+	}
+
+	var between []string
+	ms.RangeBetween("user:41:", "user:42:05", func(key, _ string) bool {
+		between = append(between, key)
+		return true
+	})
+
+	if len(between) != 6 || between[0] != "user:41:00" || between[5] != "user:42:04" {
+		t.Errorf("Unexpected range: %v", between)
+	}
+}
+
+func TestMapScanExpired(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewMapStoreConfig(time.Second/3, 1, "#temp.db", false)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	defer ms.Close()
+
+	ms.Set(ctx, "a", "v", time.Second)
+	ms.Set(ctx, "b", "v", -1)
+
+	time.Sleep(time.Second * 2)
+
+	if keys, _ := ms.Scan("", "", 10); len(keys) != 1 || keys[0] != "b" {
+		t.Errorf("Want only [b], got: %v", keys)
+	}
+}
+
+func TestMapSnapshot(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewMapStoreConfig(time.Second/3, 1, "#temp.db", false)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	defer ms.Close()
+
+	for i := 0; i < 10; i++ {
+		ms.Set(ctx, fmt.Sprintf("%02d", i), "old", -1)
+	}
+
+	snap := ms.Snapshot()
+
+	// Write concurrently with iteration, none of this shoud be visible in snapshot
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			ms.Set(ctx, fmt.Sprintf("%02d", i), "new", -1)
+		}
+	}()
+
+	n := 0
+	snap.Range(func(key, val string) bool {
+		if val != "old" {
+			t.Errorf("Key %s: want old, got: %s", key, val)
+		}
+		n++
+		return true
+	})
+
+	<-done
+
+	if n != 10 {
+		t.Errorf("Want 10 keys in snapshot, got: %d", n)
+	}
+
+	if _, ok := snap.Get("15"); ok {
+		t.Error("Key written after snapshot is visible")
+	}
+
+	if val, _ := ms.Get(ctx, "05"); val != "new" {
+		t.Errorf("Want new in store, got: %s", val)
+	}
+}
+
+func TestMapTx(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#temp.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := NewMapStoreConfig(time.Second/3, 1, filename, true)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	if err := ms.Load(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := ms.Run(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := ms.MSet(ctx, []string{"a", "b", "c"}, []string{"1", "2", "3"}, -1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	tx := ms.Multi().Set("d", "4", -1).Delete("a").Set("b", "", -1)
+	if err := tx.Exec(ctx); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := tx.Exec(ctx); err != ErrTxDone {
+		t.Errorf("Want ErrTxDone, got: %v", err)
+	}
+
+	if err := ms.MDelete(ctx, []string{"c"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	ms.Close()
+
+	// Replay transactions from file
+	ms = NewMapStore[string, string](ctx, cfg)
+	if err := ms.Load(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := ms.Run(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	vals, found := ms.MGet(ctx, []string{"a", "b", "c", "d"})
+	want := []bool{false, true, false, true}
+	for i := range want {
+		if found[i] != want[i] {
+			t.Errorf("Key %d: want found %v, got: %v", i, want[i], found[i])
+		}
+	}
+
+	if vals[1] != "" || vals[3] != "4" {
+		t.Errorf("Unexpected values: %v", vals)
+	}
+
+	if err := ms.Multi().Set("e", "5", -1).Exec(ctx); err != nil {
+		t.Error(err)
+	}
+
+	ms.Close()
+
+	if err := ms.Multi().Set("f", "6", -1).Exec(ctx); err != ErrStoreClosed {
+		t.Errorf("Want ErrStoreClosed, got: %v", err)
+	}
+}
+
+func TestMapWatch(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewMapStoreConfig(time.Second/3, 1, "#temp.db", false)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	defer ms.Close()
+
+	sub := ms.Watch(WatchConfig[string]{Prefix: "user:"})
+	defer sub.Close()
+
+	ms.Set(ctx, "other", "v", -1)
+	ms.Set(ctx, "user:1", "a", time.Second)
+	ms.Touch(ctx, "user:1", time.Second)
+	ms.Set(ctx, "user:2", "b", -1)
+	ms.Delete(ctx, "user:2")
+
+	want := []EventType{EventSet, EventRefresh, EventSet, EventDelete, EventExpire}
+	for i, et := range want {
+		select {
+		case e := <-sub.Events():
+			if e.Type != et || e.Key == "other" {
+				t.Errorf("Event %d: want %s, got: %s %s", i, et, e.Type, e.Key)
+			}
+		case <-time.After(time.Second * 3):
+			t.Errorf("Event %d: want %s, got nothing", i, et)
+			return
+		}
+	}
+}
+
+func TestMapWatchOverflow(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewMapStoreConfig(time.Second/3, 1, "#temp.db", false)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	defer ms.Close()
+
+	drop := ms.Watch(WatchConfig[string]{Buffer: 2, Policy: DropEvents})
+	defer drop.Close()
+
+	disconnect := ms.Watch(WatchConfig[string]{Key: "a", Buffer: 2, Policy: Disconnect})
+	defer disconnect.Close()
+
+	for i := 0; i < 5; i++ {
+		ms.Set(ctx, "a", "v", -1)
+	}
+
+	if drop.Dropped() != 3 {
+		t.Errorf("Want 3 dropped events, got: %d", drop.Dropped())
+	}
+
+	select {
+	case <-disconnect.Done():
+	default:
+		t.Error("Slow subscriber was not disconnected")
+	}
+}
+
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func randSeq(n int) string {
@@ -413,3 +723,458 @@ func BenchmarkMapSet(b *testing.B) {
 		ms.Set(context.Background(), key, ety, -1)
 	}
 }
+
+func TestMapUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	ms := NewMapStore[string, int](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	if err := ms.Run(); err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Close()
+
+	incr := func(get func(key string) (int, bool), tx *Tx[string, int]) error {
+		n, _ := get("counter")
+		tx.Set("counter", n+1, -1)
+		return nil
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ms.Update(ctx, incr); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := ms.Get(ctx, "counter"); n != 100 {
+		t.Errorf("Want 100, got: %d", n)
+	}
+
+	errAbort := errors.New("abort")
+	err := ms.Update(ctx, func(get func(key string) (int, bool), tx *Tx[string, int]) error {
+		tx.Set("counter", 0, -1)
+		if err := tx.Exec(ctx); err != ErrTxInUpdate {
+			t.Errorf("Want ErrTxInUpdate, got: %v", err)
+		}
+		return errAbort
+	})
+
+	if err != errAbort {
+		t.Errorf("Want errAbort, got: %v", err)
+	}
+
+	if n, _ := ms.Get(ctx, "counter"); n != 100 {
+		t.Errorf("Aborted update was applied, got: %d", n)
+	}
+}
+
+func TestMapMoveTo(t *testing.T) {
+	ctx := context.Background()
+
+	src := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	dst := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	defer src.Close()
+	defer dst.Close()
+
+	if err := src.MSet(ctx, []string{"a", "b"}, []string{"1", "2"}, time.Minute); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := dst.Set(ctx, "b", "kept", -1); err != nil {
+		t.Error(err)
+		return
+	}
+
+	sub := src.Watch(WatchConfig[string]{})
+	defer sub.Close()
+	dst.Subscribe(sub)
+
+	if err := src.MoveTo(ctx, dst, []string{"a", "b", "missing"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Moved keys do not change for readers
+	select {
+	case e := <-sub.Events():
+		t.Errorf("Want no events, got: %+v", e)
+	default:
+	}
+
+	if _, found := src.MGet(ctx, []string{"a", "b"}); found[0] || found[1] {
+		t.Errorf("Want keys removed from source, got: %v", found)
+	}
+
+	vals, found := dst.MGet(ctx, []string{"a", "b", "missing"})
+	if vals[0] != "1" || vals[1] != "kept" || found[2] {
+		t.Errorf("Unexpected destination: %v, %v", vals, found)
+	}
+
+	// Expiration time moves together with value
+	ent, _ := dst.store.Load("a")
+	if ttl := ent.(TTLStoreEntity[string]).TTL; ttl <= 0 || ttl > time.Now().Add(time.Minute).Unix() {
+		t.Errorf("Unexpected ttl: %d", ttl)
+	}
+}
+
+func TestMapSync(t *testing.T) {
+	ctx := context.Background()
+
+	filename := "#sync.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	ms := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, filename, true))
+	if err := ms.Load(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := ms.Run(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for i := 0; i < 150; i++ {
+		if err := ms.Set(ctx, fmt.Sprintf("%d", i), "val", -1); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if err := ms.Sync(ctx); err != nil {
+		t.Error(err)
+	}
+
+	// Every record is in file without waiting for save daemon
+	reader, err := os.Open(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	count := 0
+	if err := coder.NewDecoder[MapEntity[string, TTLStoreEntity[string]]](reader).Decode(func(*MapEntity[string, TTLStoreEntity[string]]) {
+		count++
+	}); err != nil {
+		t.Error(err)
+	}
+	reader.Close()
+
+	if count != 150 {
+		t.Errorf("Want 150 records in file, got: %d", count)
+	}
+
+	ms.Close()
+
+	if err := ms.Sync(ctx); err != ErrStoreClosed {
+		t.Errorf("Want ErrStoreClosed, got: %v", err)
+	}
+
+	// Store without file is always synced
+	mem := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	defer mem.Close()
+
+	if err := mem.Sync(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapRevision(t *testing.T) {
+	ctx := context.Background()
+	filename := "#temp_rev.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := NewMapStoreConfig(time.Second/3, 1, filename, true)
+
+	ms := NewMapStore[string, string](ctx, cfg)
+	if err := ms.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	rev := func(s *MapStore[string, string], key string) uint64 {
+		_, r, ok := s.GetRev(ctx, key)
+		if !ok {
+			t.Fatalf("key %s is missing", key)
+		}
+		return r
+	}
+
+	if err := ms.Set(ctx, "a", "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	first := rev(ms, "a")
+	if first == 0 {
+		t.Fatal("Want revision to be assigned")
+	}
+
+	if err := ms.Set(ctx, "a", "2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	second := rev(ms, "a")
+	if second <= first {
+		t.Errorf("Want revision to grow, got: %d after %d", second, first)
+	}
+
+	// Touch does not change value, so revision is kept
+	if _, err := ms.Touch(ctx, "a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if r := rev(ms, "a"); r != second {
+		t.Errorf("Want revision %d after touch, got: %d", second, r)
+	}
+
+	if err := ms.MSet(ctx, []string{"b", "c"}, []string{"1", "2"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if b, c := rev(ms, "b"), rev(ms, "c"); b <= second || c <= b {
+		t.Errorf("Want revisions of transaction to grow, got: %d, %d", b, c)
+	}
+	last := rev(ms, "c")
+
+	if err := ms.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ms.Close()
+
+	newMs := NewMapStore[string, string](ctx, cfg)
+	if err := newMs.Load(); err != nil {
+		t.Fatal(err)
+	}
+	defer newMs.Close()
+
+	if err := newMs.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := rev(newMs, "a"); r != second {
+		t.Errorf("Want revision %d after load, got: %d", second, r)
+	}
+
+	// Revisions after load continue after the highest loaded one
+	if err := newMs.Set(ctx, "d", "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if r := rev(newMs, "d"); r <= last {
+		t.Errorf("Want revision greater than %d, got: %d", last, r)
+	}
+
+	// Moved key keeps its revision
+	dst := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	defer dst.Close()
+
+	if err := newMs.MoveTo(ctx, dst, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if r := rev(dst, "a"); r != second {
+		t.Errorf("Want revision %d after move, got: %d", second, r)
+	}
+
+	if err := dst.Set(ctx, "e", "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if r := rev(dst, "e"); r <= second {
+		t.Errorf("Want revision greater than moved %d, got: %d", second, r)
+	}
+}
+
+func TestMapLoadTornTail(t *testing.T) {
+	ctx := context.Background()
+	filename := "#temp_torn.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := NewMapStoreConfig(time.Second/3, 1, filename, true)
+
+	open := func() *MapStore[string, string] {
+		ms := NewMapStore[string, string](ctx, cfg)
+		if err := ms.Load(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ms.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return ms
+	}
+
+	ms := open()
+	if err := ms.Set(ctx, "a", "1", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ms.Close()
+
+	// Crash in the middle of write leaves frame, that is shorter than its length
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{100, 1, 2, 3})
+	f.Close()
+
+	ms = open()
+	if val, ok := ms.Get(ctx, "a"); !ok || val != "1" {
+		t.Errorf("Want record before torn one, got: %s, %v", val, ok)
+	}
+
+	if err := ms.Set(ctx, "b", "2", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ms.Close()
+
+	// Records, written after restart, are not lost behind torn one
+	ms = open()
+	defer ms.Close()
+
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if val, ok := ms.Get(ctx, key); !ok || val != want {
+			t.Errorf("Want %s of key %s, got: %s, %v", want, key, val, ok)
+		}
+	}
+}
+
+func TestMapSetFixed(t *testing.T) {
+	ctx := context.Background()
+
+	ms := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	defer ms.Close()
+
+	if err := ms.SetFixed(ctx, "fixed", "1", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Set(ctx, "sliding", "1", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := ms.Touch(ctx, "fixed", time.Second); err != nil || ok {
+		t.Errorf("Want fixed ttl not to be touched, got: %v, %v", ok, err)
+	}
+
+	if ok, err := ms.Touch(ctx, "sliding", time.Second); err != nil || !ok {
+		t.Errorf("Want ttl to be touched, got: %v, %v", ok, err)
+	}
+
+	time.Sleep(2500 * time.Millisecond)
+
+	if _, ok := ms.Get(ctx, "fixed"); !ok {
+		t.Error("Want key with fixed ttl to be kept")
+	}
+
+	if _, ok := ms.Get(ctx, "sliding"); ok {
+		t.Error("Want touched key to expire")
+	}
+
+	// Next write sets ttl again
+	if err := ms.Set(ctx, "fixed", "2", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := ms.Touch(ctx, "fixed", time.Second); !ok {
+		t.Error("Want written key to be touched")
+	}
+}
+
+func TestMapExpires(t *testing.T) {
+	ctx := context.Background()
+
+	ms := NewMapStore[string, string](ctx, NewMapStoreConfig(time.Second/3, 1, "", false))
+	defer ms.Close()
+
+	if err := ms.Set(ctx, "a", "1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Set(ctx, "forever", "1", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if expires, ok := ms.Expires(ctx, "a"); !ok || time.Until(expires) < 59*time.Minute || time.Until(expires) > time.Hour+time.Second {
+		t.Errorf("Want key to expire in an hour, got: %v, %v", expires, ok)
+	}
+
+	if expires, ok := ms.Expires(ctx, "forever"); !ok || !expires.IsZero() {
+		t.Errorf("Want zero time for key, that never expires, got: %v, %v", expires, ok)
+	}
+
+	if _, ok := ms.Expires(ctx, "missing"); ok {
+		t.Error("Want missing key")
+	}
+}
+
+func TestMapRevAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	filename := "#temp_rev.db"
+	os.Remove(filename)
+	defer os.Remove(filename)
+
+	cfg := NewMapStoreConfig(time.Second/3, 1, filename, true)
+
+	open := func() *MapStore[string, string] {
+		ms := NewMapStore[string, string](ctx, cfg)
+		if err := ms.Load(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ms.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return ms
+	}
+
+	ms := open()
+	for _, key := range []string{"a", "b"} {
+		if err := ms.Set(ctx, key, "1", -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, last, _ := ms.GetRev(ctx, "b")
+	if err := ms.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ms.Close()
+
+	// Rewrite by first Load drops deleted key, revision of it is still not assigned again after next restart
+	ms = open()
+	ms.Close()
+
+	ms = open()
+	defer ms.Close()
+
+	if err := ms.Set(ctx, "c", "1", -1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, rev, _ := ms.GetRev(ctx, "c"); rev <= last {
+		t.Errorf("Want revision greater than %d of deleted key, got: %d", last, rev)
+	}
+
+	if keys, _ := ms.Scan("", "", 10); len(keys) != 2 {
+		t.Errorf("Want revision record not to be a key, got: %v", keys)
+	}
+}
@@ -0,0 +1,160 @@
+package ttlstore
+
+import (
+	"hash/maphash"
+	"strings"
+	"sync/atomic"
+)
+
+// indexNode - node of persistent treap. Nodes are never changed after they were published,
+// every write copies the path from root to changed node, so any root is a consistent version of index.
+type indexNode[K string, V any] struct {
+	key   K
+	val   TTLStoreEntity[V]
+	prio  uint64
+	left  *indexNode[K, V]
+	right *indexNode[K, V]
+}
+
+// keyIndex - ordered index of keys, maintained beside the hash map.
+// Writes builds new version of tree and publishes it with one store to root,
+// so they MUST be serialized by caller. Reads are lock free.
+type keyIndex[K string, V any] struct {
+	seed maphash.Seed
+	root atomic.Pointer[indexNode[K, V]]
+}
+
+func newKeyIndex[K string, V any]() *keyIndex[K, V] {
+	return &keyIndex[K, V]{
+		seed: maphash.MakeSeed(),
+	}
+}
+
+// split - splits tree in to nodes with keys less then k, node with key == k and nodes with keys greater then k
+func split[K string, V any](n *indexNode[K, V], k K) (l, m, r *indexNode[K, V]) {
+	if n == nil {
+		return nil, nil, nil
+	}
+
+	switch {
+	case k < n.key:
+		l, m, rl := split(n.left, k)
+		c := *n
+		c.left = rl
+		return l, m, &c
+	case k > n.key:
+		lr, m, r := split(n.right, k)
+		c := *n
+		c.right = lr
+		return &c, m, r
+	default:
+		return n.left, n, n.right
+	}
+}
+
+// merge - merges two trees, all keys in l MUST be less then keys in r
+func merge[K string, V any](l, r *indexNode[K, V]) *indexNode[K, V] {
+	if l == nil {
+		return r
+	}
+
+	if r == nil {
+		return l
+	}
+
+	if l.prio > r.prio {
+		c := *l
+		c.right = merge(l.right, r)
+		return &c
+	}
+
+	c := *r
+	c.left = merge(l, r.left)
+	return &c
+}
+
+// with - returns version of tree rooted at root, where key is set to val
+func (ki *keyIndex[K, V]) with(root *indexNode[K, V], key K, val TTLStoreEntity[V]) *indexNode[K, V] {
+	n := &indexNode[K, V]{
+		key:  key,
+		val:  val,
+		prio: maphash.String(ki.seed, string(key)),
+	}
+
+	l, _, r := split(root, key)
+	return merge(merge(l, n), r)
+}
+
+// without - returns version of tree rooted at root, without key
+func (ki *keyIndex[K, V]) without(root *indexNode[K, V], key K) *indexNode[K, V] {
+	l, m, r := split(root, key)
+	if m == nil {
+		return root
+	}
+
+	return merge(l, r)
+}
+
+// ascend - calls f for every node with key >= from in ascending order, until f returns false.
+// Skips node with key == from if inclusive is false.
+func ascend[K string, V any](root *indexNode[K, V], from K, inclusive bool, f func(n *indexNode[K, V]) bool) {
+	stack := make([]*indexNode[K, V], 0, 32)
+
+	for n := root; n != nil; {
+		if n.key > from || (inclusive && n.key == from) {
+			stack = append(stack, n)
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !f(n) {
+			return
+		}
+
+		for c := n.right; c != nil; c = c.left {
+			stack = append(stack, c)
+		}
+	}
+}
+
+// scan - collects up to limit keys with prefix, that are greater then cursor.
+// Returns cursor for next page, or empty cursor if there is nothing left.
+func scan[K string, V any](root *indexNode[K, V], prefix, cursor K, limit int, now int64) ([]K, K) {
+	var next K
+	if limit <= 0 {
+		return nil, next
+	}
+
+	keys := make([]K, 0, limit)
+
+	from, inclusive := prefix, true
+	if len(cursor) > 0 && cursor >= prefix {
+		from, inclusive = cursor, false
+	}
+
+	ascend(root, from, inclusive, func(n *indexNode[K, V]) bool {
+		if !strings.HasPrefix(string(n.key), string(prefix)) {
+			return false
+		}
+
+		if n.val.expired(now) {
+			return true
+		}
+
+		if len(keys) == limit {
+			next = keys[len(keys)-1]
+			return false
+		}
+
+		keys = append(keys, n.key)
+		return true
+	})
+
+	return keys, next
+}
@@ -0,0 +1,79 @@
+package ttlstore
+
+import "time"
+
+// Snapshot - consistent read-only view of MapStore at one instant.
+// Taking a snapshot does not copy data and does not block writers,
+// writes made after snapshot was taken are not visible through it.
+type Snapshot[K string, V any] struct {
+	root *indexNode[K, V]
+	now  int64
+}
+
+// Snapshot - returns view of store at this instant. Entities are considered expired relative to snapshot time.
+func (ms *MapStore[K, V]) Snapshot() *Snapshot[K, V] {
+	return &Snapshot[K, V]{
+		root: ms.index.root.Load(),
+		now:  time.Now().Unix(),
+	}
+}
+
+func (s *Snapshot[K, V]) Get(key K) (V, bool) {
+	for n := s.root; n != nil; {
+		switch {
+		case key < n.key:
+			n = n.left
+		case key > n.key:
+			n = n.right
+		default:
+			if !n.val.expired(s.now) {
+				return n.val.Entity, true
+			}
+			n = nil
+		}
+	}
+
+	var v V
+	return v, false
+}
+
+// Range - calls f for every key in ascending order, until f returns false.
+func (s *Snapshot[K, V]) Range(f func(key K, val V) bool) {
+	s.rangeEntities(func(key K, ent TTLStoreEntity[V]) bool {
+		return f(key, ent.Entity)
+	})
+}
+
+// Scan - returns up to limit keys with provided prefix in ascending order, starting after cursor.
+// Empty cursor means start from the first key. Returned cursor is empty when there is no more keys.
+func (s *Snapshot[K, V]) Scan(prefix K, cursor K, limit int) ([]K, K) {
+	return scan(s.root, prefix, cursor, limit, s.now)
+}
+
+// RangeBetween - calls f for every key in [start, end) in ascending order, until f returns false.
+// Empty end means there is no upper bound.
+func (s *Snapshot[K, V]) RangeBetween(start, end K, f func(key K, val V) bool) {
+	ascend(s.root, start, true, func(n *indexNode[K, V]) bool {
+		if len(end) > 0 && n.key >= end {
+			return false
+		}
+
+		if n.val.expired(s.now) {
+			return true
+		}
+
+		return f(n.key, n.val.Entity)
+	})
+}
+
+// rangeEntities - same as Range, but passes entities with ttl, used to dump snapshot to file
+func (s *Snapshot[K, V]) rangeEntities(f func(key K, ent TTLStoreEntity[V]) bool) {
+	var from K
+	ascend(s.root, from, true, func(n *indexNode[K, V]) bool {
+		if n.val.expired(s.now) {
+			return true
+		}
+
+		return f(n.key, n.val)
+	})
+}
@@ -1,14 +1,41 @@
 package ttlstore
 
+import "time"
+
 type TTLStoreEntity[T any] struct {
 	Entity T
-	ttl    int64
+	// TTL - unix time of expiration, exported so it is saved to file together with entity
+	TTL int64
+	// Rev - revision of entity, grows with every write of key. Zero revision is assigned, when entity is committed.
+	Rev uint64
+	// Fixed - ttl is not changed by Touch, only by next write of key
+	Fixed bool
+}
+
+// newTTLStoreEntity - wraps val with expiration time. Negative ttl means entity never expires.
+func newTTLStoreEntity[T any](val T, ttl time.Duration) TTLStoreEntity[T] {
+	var t int64 = -1
+	if ttl > 0 {
+		t = time.Now().Add(ttl).Unix()
+	}
+
+	te := TTLStoreEntity[T]{
+		Entity: val,
+	}
+
+	te.SetTTL(t)
+	return te
 }
 
 func (te TTLStoreEntity[T]) GetTTL() int64 {
-	return te.ttl
+	return te.TTL
 }
 
 func (te *TTLStoreEntity[T]) SetTTL(ttl int64) {
-	te.ttl = ttl
+	te.TTL = ttl
+}
+
+// expired - reports whether entity ttl has passed at unix time now. Entities with ttl <= 0 never expire.
+func (te TTLStoreEntity[T]) expired(now int64) bool {
+	return te.TTL > 0 && te.TTL < now
 }
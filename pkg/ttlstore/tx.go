@@ -0,0 +1,250 @@
+package ttlstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrStoreClosed   = errors.New("store is closed")
+	ErrTxDone        = errors.New("transaction has already been executed")
+	ErrBatchMismatch = errors.New("keys and values have different length")
+	ErrTxInUpdate    = errors.New("transaction is executed by Update")
+)
+
+// Tx - group of writes, that are applied all-or-nothing and saved to file as one record.
+// Tx is not safe for concurrent use.
+type Tx[K string, V any] struct {
+	ms   *MapStore[K, V]
+	ops  []TxEntity[K, TTLStoreEntity[V]]
+	done bool
+	// inUpdate - tx is applied by Update, Exec would deadlock
+	inUpdate bool
+}
+
+// Multi - starts new transaction, writes are not visible until Exec is called
+func (ms *MapStore[K, V]) Multi() *Tx[K, V] {
+	return &Tx[K, V]{
+		ms: ms,
+	}
+}
+
+// Set - queues write of key with ttl, same as MapStore.Set zero ttl is ignored
+func (tx *Tx[K, V]) Set(key K, val V, ttl time.Duration) *Tx[K, V] {
+	if ttl == 0 {
+		return tx
+	}
+
+	tx.ops = append(tx.ops, TxEntity[K, TTLStoreEntity[V]]{
+		Key: key,
+		Val: newTTLStoreEntity(val, ttl),
+		Op:  OpSet,
+	})
+	return tx
+}
+
+// Delete - queues deletion of key
+func (tx *Tx[K, V]) Delete(key K) *Tx[K, V] {
+	tx.ops = append(tx.ops, TxEntity[K, TTLStoreEntity[V]]{
+		Key: key,
+		Op:  OpDelete,
+	})
+	return tx
+}
+
+// Exec - applies every queued write. Either all writes are applied, or none of them if error is returned.
+// Snapshots and file dump observe transaction as a whole.
+func (tx *Tx[K, V]) Exec(_ context.Context) error {
+	if tx.inUpdate {
+		return ErrTxInUpdate
+	}
+
+	if tx.done {
+		return ErrTxDone
+	}
+
+	tx.done = true
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return tx.ms.commit(MapEntity[K, TTLStoreEntity[V]]{
+		Tx: tx.ops,
+	})
+}
+
+// Update - atomic read-modify-write. f runs under write lock of store, so values read with get
+// can not change until writes queued to tx are applied. If f returns error, nothing is applied.
+// f MUST NOT call other writing methods of store.
+func (ms *MapStore[K, V]) Update(ctx context.Context, f func(get func(key K) (V, bool), tx *Tx[K, V]) error) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	tx := ms.Multi()
+	tx.inUpdate = true
+
+	get := func(key K) (V, bool) {
+		return ms.Get(ctx, key)
+	}
+
+	if err := f(get, tx); err != nil {
+		return err
+	}
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return ms.commitLocked(MapEntity[K, TTLStoreEntity[V]]{
+		Tx: tx.ops,
+	})
+}
+
+// MGet - gets values of every key, found[i] reports whether keys[i] is present
+func (ms *MapStore[K, V]) MGet(ctx context.Context, keys []K) (vals []V, found []bool) {
+	vals = make([]V, len(keys))
+	found = make([]bool, len(keys))
+
+	for i, k := range keys {
+		vals[i], found[i] = ms.Get(ctx, k)
+	}
+
+	return vals, found
+}
+
+// MSet - sets every key to value with the same index, in one transaction
+func (ms *MapStore[K, V]) MSet(ctx context.Context, keys []K, vals []V, ttl time.Duration) error {
+	if len(keys) != len(vals) {
+		return ErrBatchMismatch
+	}
+
+	tx := ms.Multi()
+	for i := range keys {
+		tx.Set(keys[i], vals[i], ttl)
+	}
+
+	return tx.Exec(ctx)
+}
+
+// MDelete - deletes every key, in one transaction
+func (ms *MapStore[K, V]) MDelete(ctx context.Context, keys []K) error {
+	tx := ms.Multi()
+	for _, k := range keys {
+		tx.Delete(k)
+	}
+
+	return tx.Exec(ctx)
+}
+
+// Drop - deletes every key in one transaction, without notifying subscribers. It removes stale copies of keys,
+// that are owned by other store, so watchers of both stores do not see deletes of keys, that still exist.
+func (ms *MapStore[K, V]) Drop(ctx context.Context, keys []K) error {
+	ops := make([]TxEntity[K, TTLStoreEntity[V]], len(keys))
+	for i, k := range keys {
+		ops[i] = TxEntity[K, TTLStoreEntity[V]]{
+			Key: k,
+			Op:  OpDelete,
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.commitRecord(MapEntity[K, TTLStoreEntity[V]]{
+		Tx: ops,
+	}, false)
+}
+
+// MoveTo - moves keys with their expiration time to dst store. Keys, that are already present in dst, are kept there
+// and only removed from this store, expired keys are dropped. Writes to dst and deletes from this store are separate
+// transactions, if process crashes between them, keys are present in both stores and MoveTo can be repeated.
+// Key, that is written to this store while it is moved, is not removed, so its new value is moved by next MoveTo.
+// Subscribers of both stores are not notified, because values of keys do not change.
+func (ms *MapStore[K, V]) MoveTo(ctx context.Context, dst *MapStore[K, V], keys []K) error {
+	now := time.Now().Unix()
+
+	ms.mu.Lock()
+	ops := make([]TxEntity[K, TTLStoreEntity[V]], 0, len(keys))
+	for _, k := range keys {
+		val, ok := ms.store.Load(k)
+		if !ok {
+			continue
+		}
+
+		if ent, ok := val.(TTLStoreEntity[V]); ok && !ent.expired(now) {
+			ops = append(ops, TxEntity[K, TTLStoreEntity[V]]{
+				Key: k,
+				Val: ent,
+				Op:  OpSet,
+			})
+		}
+	}
+	ms.mu.Unlock()
+
+	// Locks of stores are not held together, moves in opposite directions would deadlock
+	if err := dst.moveIn(ctx, ops); err != nil {
+		return err
+	}
+
+	moved := make(map[K]uint64, len(ops))
+	for _, op := range ops {
+		moved[op.Key] = op.Val.Rev
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	drop := make([]TxEntity[K, TTLStoreEntity[V]], 0, len(keys))
+	for _, k := range keys {
+		val, ok := ms.store.Load(k)
+		if !ok {
+			continue
+		}
+
+		// Key is dropped, only if it was not written since it was read, or it has expired
+		ent, ok := val.(TTLStoreEntity[V])
+		if rev, found := moved[k]; ok && !ent.expired(time.Now().Unix()) && (!found || rev != ent.Rev) {
+			continue
+		}
+
+		drop = append(drop, TxEntity[K, TTLStoreEntity[V]]{
+			Key: k,
+			Op:  OpDelete,
+		})
+	}
+
+	if len(drop) == 0 {
+		return nil
+	}
+
+	return ms.commitRecord(MapEntity[K, TTLStoreEntity[V]]{
+		Tx: drop,
+	}, false)
+}
+
+// moveIn - applies writes of keys, that are not present in store, in one transaction
+func (ms *MapStore[K, V]) moveIn(ctx context.Context, ops []TxEntity[K, TTLStoreEntity[V]]) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	missing := make([]TxEntity[K, TTLStoreEntity[V]], 0, len(ops))
+	for _, op := range ops {
+		if _, ok := ms.Get(ctx, op.Key); !ok {
+			missing = append(missing, op)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return ms.commitRecord(MapEntity[K, TTLStoreEntity[V]]{
+		Tx: missing,
+	}, false)
+}